@@ -118,10 +118,8 @@ func (ca *RedisCache) Delete(key string) error {
 	return err
 }
 
-// ClearAll will delete all item in redis cache.
-// never error
+// ClearAll will delete all item in the database of redis cache.
 func (ca *RedisCache) ClearAll() error {
 	redisClient := redisutil.GetRedisClient(ca.serverURL)
-	redisClient.FlushDB()
-	return nil
+	return redisClient.FlushDB()
 }
@@ -0,0 +1,156 @@
+package redisutil
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrWriterClosed is returned by the Set of a closed BufferedWriter
+var ErrWriterClosed = errors.New("redisutil: buffered writer closed")
+
+// logFlush logs the errors of the flushes done in background
+var logFlush = log.Printf
+
+// BufferedWriter buffers SETs in memory and sends them to redis in batches,
+// for high volume writes which may be delayed or lost, like counters of
+// analytics. a key set several times between two flushes is sent once with
+// its latest value. the values of a failed flush are dropped
+type BufferedWriter struct {
+	rc        *RedisClient
+	maxBuffer int
+
+	mu      sync.Mutex
+	pending map[string]interface{}
+	closed  bool
+
+	flushMu   sync.Mutex // keeps the batches in order
+	full      chan struct{}
+	done      chan struct{}
+	loopDone  chan struct{}
+	closeOnce sync.Once
+}
+
+// NewBufferedWriter returns a BufferedWriter sending the buffered SETs to rc
+// every flushInterval, and as soon as maxBuffer keys are buffered.
+// flushInterval 0 only flushes when the buffer is full or on Flush, maxBuffer
+// 0 never flushes because of the buffer size
+func NewBufferedWriter(rc *RedisClient, flushInterval time.Duration, maxBuffer int) *BufferedWriter {
+	w := &BufferedWriter{
+		rc:        rc,
+		maxBuffer: maxBuffer,
+		pending:   make(map[string]interface{}),
+		full:      make(chan struct{}, 1),
+		done:      make(chan struct{}),
+		loopDone:  make(chan struct{}),
+	}
+	go w.flushLoop(flushInterval)
+	return w
+}
+
+// Set buffers the SET of key to val, replacing a value of key not flushed yet
+func (w *BufferedWriter) Set(key string, val interface{}) error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWriterClosed
+	}
+	w.pending[key] = val
+	full := w.maxBuffer > 0 && len(w.pending) >= w.maxBuffer
+	w.mu.Unlock()
+	if full {
+		select {
+		case w.full <- struct{}{}:
+		default: // a flush is already requested
+		}
+	}
+	return nil
+}
+
+// Len returns the count of keys buffered and not flushed yet
+func (w *BufferedWriter) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.pending)
+}
+
+// Flush sends the buffered SETs to redis with a single pipeline, the errors
+// of the keys which failed are returned together
+func (w *BufferedWriter) Flush() error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+	w.mu.Lock()
+	items := w.pending
+	w.pending = make(map[string]interface{})
+	w.mu.Unlock()
+	if len(items) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	p := w.rc.Pipeline()
+	defer p.Close()
+	for _, key := range keys {
+		p.Send("SET", key, items[key])
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for i, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			errs = append(errs, keys[i]+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("redisutil: BufferedWriter flush failed for %d of %d keys: %s", len(errs), len(keys), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Close stops the background flushes and flushes the buffered SETs,
+// the Set called afterwards return ErrWriterClosed
+func (w *BufferedWriter) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.done)
+		<-w.loopDone
+		err = w.Flush()
+	})
+	return err
+}
+
+func (w *BufferedWriter) flushLoop(interval time.Duration) {
+	defer close(w.loopDone)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-tick:
+		case <-w.full:
+		}
+		if err := w.Flush(); err != nil {
+			logFlush("%v", err)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package redisutil
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestBufferedWriter(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:buffered:1", "dotweb:test:buffered:2"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+
+	w := NewBufferedWriter(redisClient, time.Hour, 100)
+	defer w.Close()
+	test.Nil(t, w.Set(keys[0], 1))
+	test.Nil(t, w.Set(keys[1], 2))
+	test.Nil(t, w.Set(keys[0], 3))
+	test.Equal(t, 2, w.Len())
+	ok, err := redisClient.Exists(keys[0])
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+
+	// the latest value of each key is written
+	test.Nil(t, w.Flush())
+	test.Equal(t, 0, w.Len())
+	val, err := redisClient.Get(keys[0])
+	test.Nil(t, err)
+	test.Equal(t, "3", val)
+	val, err = redisClient.Get(keys[1])
+	test.Nil(t, err)
+	test.Equal(t, "2", val)
+
+	// Close flushes the buffer
+	test.Nil(t, w.Set(keys[1], 4))
+	test.Nil(t, w.Close())
+	val, err = redisClient.Get(keys[1])
+	test.Nil(t, err)
+	test.Equal(t, "4", val)
+	test.Equal(t, ErrWriterClosed, w.Set(keys[1], 5))
+	test.Nil(t, w.Close())
+}
+
+func TestBufferedWriter_AutoFlush(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "OK"
+	})
+	defer server.Close()
+	redisClient := GetRedisClient(server.URL())
+
+	// the buffer is flushed once full
+	w := NewBufferedWriter(redisClient, 0, 3)
+	defer w.Close()
+	for i := 0; i < 3; i++ {
+		test.Nil(t, w.Set(fmt.Sprintf("key%d", i), i))
+	}
+	waitSets(t, server, 3)
+
+	// the buffer is flushed every interval
+	w = NewBufferedWriter(redisClient, 20*time.Millisecond, 0)
+	defer w.Close()
+	test.Nil(t, w.Set("key", "value"))
+	waitSets(t, server, 4)
+}
+
+func TestBufferedWriter_FlushError(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		if len(args) > 1 && args[1] == "readonly" {
+			return errors.New("READONLY You can't write against a read only replica.")
+		}
+		return "OK"
+	})
+	defer server.Close()
+
+	logged := make(chan string, 1)
+	logFlush = func(format string, args ...interface{}) {
+		select {
+		case logged <- fmt.Sprintf(format, args...):
+		default:
+		}
+	}
+	defer func() { logFlush = log.Printf }()
+
+	w := NewBufferedWriter(GetRedisClient(server.URL()), time.Hour, 0)
+	test.Nil(t, w.Set("key", "value"))
+	test.Nil(t, w.Set("readonly", "value"))
+	err := w.Flush()
+	test.NotNil(t, err)
+	test.Contains(t, "failed for 1 of 2 keys: readonly: READONLY", err.Error())
+	test.Nil(t, w.Close())
+
+	// the errors of the background flushes are logged
+	w = NewBufferedWriter(GetRedisClient(server.URL()), 20*time.Millisecond, 0)
+	defer w.Close()
+	test.Nil(t, w.Set("readonly", "value"))
+	select {
+	case msg := <-logged:
+		test.Contains(t, "readonly: READONLY", msg)
+	case <-time.After(time.Second):
+		t.Fatal("flush error not logged")
+	}
+}
+
+// waitSets waits until server received n SET
+func waitSets(t *testing.T, server *fakeServer, n int) {
+	deadline := time.Now().Add(time.Second)
+	for {
+		sets := 0
+		for _, args := range server.Commands() {
+			if strings.ToUpper(args[0]) == "SET" {
+				sets++
+			}
+		}
+		if sets == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("server received %d SET, want %d", sets, n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -0,0 +1,162 @@
+package redisutil
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// flightGroup deduplicates concurrent calls with the same key,
+// the zero value is ready for use
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done chan struct{} // closed once val and err are set
+	val  string
+	err  error
+}
+
+// the flight keys of each cache helper, so the helpers do not join the
+// loads of one another for the same key
+const (
+	swrFlight      = "swr:"
+	getOrSetFlight = "getorset:"
+)
+
+// do executes fn once for all concurrent callers of the same key
+func (g *flightGroup) do(key string, fn func() (string, error)) (string, error) {
+	c, started := g.start(key)
+	if !started {
+		<-c.done
+		return c.val, c.err
+	}
+	g.finish(key, c, fn)
+	return c.val, c.err
+}
+
+// doCtx is like do but stops waiting once ctx is done and returns ctx.Err(),
+// fn goes on in background for the other callers
+func (g *flightGroup) doCtx(ctx context.Context, key string, fn func() (string, error)) (string, error) {
+	c, started := g.start(key)
+	if started {
+		go g.finish(key, c, fn)
+	}
+	select {
+	case <-c.done:
+		return c.val, c.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// doAsync executes fn in background unless a call with the same key is
+// already in flight
+func (g *flightGroup) doAsync(key string, fn func() (string, error)) {
+	if c, started := g.start(key); started {
+		go g.finish(key, c, fn)
+	}
+}
+
+// start registers a call for key, started is false if one was already in flight
+func (g *flightGroup) start(key string) (c *flightCall, started bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		return c, false
+	}
+	c = &flightCall{done: make(chan struct{})}
+	g.calls[key] = c
+	return c, true
+}
+
+func (g *flightGroup) finish(key string, c *flightCall, fn func() (string, error)) {
+	c.val, c.err = fn()
+	close(c.done)
+
+	g.mu.Lock()
+	delete(g.calls, key)
+	g.mu.Unlock()
+}
+
+// GetSWR returns the value of key with stale-while-revalidate semantics.
+// a value older than ttl but younger than ttl+staleFor is returned immediately
+// and refreshed in background by loader, only one refresh runs at a time.
+// on a miss, loader is called synchronously and its error is returned.
+// the value is stored with an expiry of ttl+staleFor, its age is derived from
+// the remaining lifetime so it should only be written by GetSWR
+func (rc *RedisClient) GetSWR(key string, ttl, staleFor time.Duration, loader func() (string, error)) (value string, err error) {
+	load := func() (string, error) {
+		val, err := loader()
+		if err != nil {
+			return "", err
+		}
+		conn := rc.getConn()
+		defer conn.Close()
+		_, err = conn.Do("SET", key, val, "PX", int64((ttl+staleFor)/time.Millisecond))
+		return val, err
+	}
+
+	conn := rc.getConn()
+	conn.Send("GET", key)
+	conn.Send("PTTL", key)
+	conn.Flush()
+	reply, errGet := conn.Receive()
+	pttl, errTTL := redis.Int64(conn.Receive())
+	conn.Close()
+	if errGet == nil && reply == nil {
+		return rc.flights.do(swrFlight+key, load)
+	}
+	value, err = redis.String(reply, errGet)
+	if err != nil {
+		return "", err
+	}
+	if errTTL == nil && pttl >= 0 && time.Duration(pttl)*time.Millisecond < staleFor {
+		rc.flights.doAsync(swrFlight+key, load)
+	}
+	return value, nil
+}
+
+// GetOrSet returns the value of key, on a miss it calls loader and stores
+// its value with an expire of ttl seconds, no expire if ttl is not positive.
+// concurrent misses of the same key share a single call of loader.
+// the error of loader is returned and nothing is stored
+func (rc *RedisClient) GetOrSet(key string, ttl int64, loader func() (string, error)) (string, error) {
+	return rc.GetOrSetCtx(context.Background(), key, ttl, loader)
+}
+
+// GetOrSetCtx is like GetOrSet but returns ctx.Err() once ctx is done,
+// without waiting for loader. loader and the store of its value go on for
+// the other callers sharing the call
+func (rc *RedisClient) GetOrSetCtx(ctx context.Context, key string, ttl int64, loader func() (string, error)) (string, error) {
+	conn := rc.getConnContext(ctx)
+	reply, err := conn.Do("GET", key)
+	conn.Close()
+	if err != nil {
+		return "", err
+	}
+	if reply != nil {
+		return redis.String(reply, nil)
+	}
+	return rc.flights.doCtx(ctx, getOrSetFlight+key, func() (string, error) {
+		val, err := loader()
+		if err != nil {
+			return "", err
+		}
+		args := []interface{}{key, val}
+		if ttl > 0 {
+			args = append(args, "EX", ttl)
+		}
+		conn := rc.getConn()
+		defer conn.Close()
+		_, err = conn.Do("SET", args...)
+		return val, err
+	})
+}
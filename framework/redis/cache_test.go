@@ -0,0 +1,181 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_GetSWR(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:swr"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func() (string, error) {
+		if atomic.AddInt32(&loads, 1) == 1 {
+			return "v1", nil
+		}
+		<-release
+		return "v2", nil
+	}
+
+	// full miss loads synchronously
+	val, err := redisClient.GetSWR(key, time.Second, time.Minute, loader)
+	test.Nil(t, err)
+	test.Equal(t, "v1", val)
+
+	// fresh value does not trigger a refresh
+	val, err = redisClient.GetSWR(key, time.Second, time.Minute, loader)
+	test.Nil(t, err)
+	test.Equal(t, "v1", val)
+	test.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// age the value past ttl, the stale value is served and refreshed once
+	conn := redisClient.GetConn()
+	_, err = conn.Do("PEXPIRE", key, 30000)
+	conn.Close()
+	test.Nil(t, err)
+	for i := 0; i < 5; i++ {
+		val, err = redisClient.GetSWR(key, time.Second, time.Minute, loader)
+		test.Nil(t, err)
+		test.Equal(t, "v1", val)
+	}
+	close(release)
+	time.Sleep(100 * time.Millisecond)
+	test.Equal(t, int32(2), atomic.LoadInt32(&loads))
+
+	val, err = redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "v2", val)
+}
+
+func TestRedisClient_GetSWR_LoaderError(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:swr:error"
+	redisClient.Del(key)
+
+	loadErr := errors.New("load failed")
+	_, err := redisClient.GetSWR(key, time.Second, time.Minute, func() (string, error) {
+		return "", loadErr
+	})
+	test.Equal(t, loadErr, err)
+	exists, _ := redisClient.Exists(key)
+	test.Equal(t, false, exists)
+}
+
+func TestRedisClient_GetOrSet(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:getorset"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	var loads int32
+	release := make(chan struct{})
+	loader := func() (string, error) {
+		atomic.AddInt32(&loads, 1)
+		<-release
+		return "value", nil
+	}
+
+	// concurrent misses call loader once
+	var wg sync.WaitGroup
+	values := make([]string, 10)
+	errs := make([]error, 10)
+	for i := range values {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			values[i], errs[i] = redisClient.GetOrSet(key, 100, loader)
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	for i := range values {
+		test.Nil(t, errs[i])
+		test.Equal(t, "value", values[i])
+	}
+	test.Equal(t, int32(1), atomic.LoadInt32(&loads))
+
+	// the value is cached afterwards
+	val, err := redisClient.GetOrSet(key, 100, loader)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	test.Equal(t, int32(1), atomic.LoadInt32(&loads))
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, true, ttl > 90 && ttl <= 100)
+}
+
+func TestRedisClient_GetOrSet_LoaderError(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:getorset:error"
+	redisClient.Del(key)
+
+	loadErr := errors.New("load failed")
+	_, err := redisClient.GetOrSet(key, 100, func() (string, error) {
+		return "", loadErr
+	})
+	test.Equal(t, loadErr, err)
+	exists, _ := redisClient.Exists(key)
+	test.Equal(t, false, exists)
+}
+
+func TestRedisClient_GetOrSetCtx(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:getorset:ctx"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	// the caller stops waiting, the load goes on for the others
+	release := make(chan struct{})
+	loader := func() (string, error) {
+		<-release
+		return "value", nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	done := make(chan string)
+	go func() {
+		val, _ := redisClient.GetOrSet(key, 100, loader)
+		done <- val
+	}()
+	_, err := redisClient.GetOrSetCtx(ctx, key, 100, loader)
+	test.Equal(t, context.DeadlineExceeded, err)
+	close(release)
+	test.Equal(t, "value", <-done)
+}
+
+func TestRedisClient_GetOrSetGetSWR(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:getorset:swr"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	// concurrent misses of GetSWR and GetOrSet do not share their loads
+	release := make(chan struct{})
+	done := make(chan string)
+	go func() {
+		val, _ := redisClient.GetSWR(key, time.Second, time.Minute, func() (string, error) {
+			<-release
+			return "swr", nil
+		})
+		done <- val
+	}()
+	time.Sleep(20 * time.Millisecond)
+	val, err := redisClient.GetOrSet(key, 100, func() (string, error) {
+		return "getorset", nil
+	})
+	test.Nil(t, err)
+	test.Equal(t, "getorset", val)
+	close(release)
+	test.Equal(t, "swr", <-done)
+}
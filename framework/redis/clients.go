@@ -0,0 +1,133 @@
+package redisutil
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ClientInfo is a connection listed by CLIENT LIST
+type ClientInfo struct {
+	ID   int64
+	Addr string
+	Name string
+	Age  int64 // seconds since the connection was created
+	Idle int64 // seconds since the last command
+	Cmd  string
+}
+
+// ClientKillFilter selects the connections killed by ClientKill,
+// at least one of the fields must be set
+type ClientKillFilter struct {
+	ID   int64  // connection id, ignored when 0
+	Addr string // connection address like "10.0.1.11:52340", ignored when empty
+}
+
+// ClientList returns the connections of the server
+func (rc *RedisClient) ClientList() ([]ClientInfo, error) {
+	conn := rc.getConn()
+	defer conn.Close()
+	val, err := redis.String(conn.Do("CLIENT", "LIST"))
+	if err != nil {
+		return nil, err
+	}
+	return parseClientList(val), nil
+}
+
+// ClientKill closes the connections matching filter,
+// returns the number of connections killed
+func (rc *RedisClient) ClientKill(filter ClientKillFilter) (int64, error) {
+	args := []interface{}{"KILL"}
+	if filter.ID != 0 {
+		args = append(args, "ID", filter.ID)
+	}
+	if filter.Addr != "" {
+		args = append(args, "ADDR", filter.Addr)
+	}
+	if len(args) == 1 {
+		return 0, errors.New("redisutil: empty ClientKillFilter")
+	}
+	conn := rc.getConn()
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("CLIENT", args...))
+	return val, err
+}
+
+// SetClientName names each new connection of rc with name followed by a
+// sequence number, like "worker-1", so the connections are identified in
+// CLIENT LIST. the connections already created are not renamed, an empty
+// name stops naming the new connections
+func (rc *RedisClient) SetClientName(name string) error {
+	if strings.ContainsAny(name, " \n") {
+		return errors.New("redisutil: client name must not contain spaces or newlines")
+	}
+	if rc.parent != nil {
+		return rc.parent.SetClientName(name)
+	}
+	rc.hookMutex.Lock()
+	rc.connName = name
+	rc.hookMutex.Unlock()
+	return nil
+}
+
+// GetClientName returns the name of a connection of rc, set by
+// SetClientName when it was created. returns ErrNil if it has no name
+func (rc *RedisClient) GetClientName() (string, error) {
+	conn := rc.getConn()
+	defer conn.Close()
+	val, err := redis.String(conn.Do("CLIENT", "GETNAME"))
+	return val, err
+}
+
+// nameConn names the new connection conn if SetClientName was called
+func (rc *RedisClient) nameConn(conn redis.Conn) error {
+	rc.hookMutex.Lock()
+	name := rc.connName
+	if name != "" {
+		rc.connSeq++
+		name += "-" + strconv.Itoa(rc.connSeq)
+	}
+	rc.hookMutex.Unlock()
+	if name == "" {
+		return nil
+	}
+	_, err := conn.Do("CLIENT", "SETNAME", name)
+	return err
+}
+
+// parseClientList parses the CLIENT LIST reply, one connection per line
+// described by space separated key=value fields
+func parseClientList(reply string) []ClientInfo {
+	var clients []ClientInfo
+	for _, line := range strings.Split(reply, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		var client ClientInfo
+		for _, field := range strings.Fields(line) {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			switch kv[0] {
+			case "id":
+				client.ID, _ = strconv.ParseInt(kv[1], 10, 64)
+			case "addr":
+				client.Addr = kv[1]
+			case "name":
+				client.Name = kv[1]
+			case "age":
+				client.Age, _ = strconv.ParseInt(kv[1], 10, 64)
+			case "idle":
+				client.Idle, _ = strconv.ParseInt(kv[1], 10, 64)
+			case "cmd":
+				client.Cmd = kv[1]
+			}
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
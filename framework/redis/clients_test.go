@@ -0,0 +1,73 @@
+package redisutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+const fakeClientList = "id=3 addr=127.0.0.1:52340 laddr=127.0.0.1:6379 fd=8 name=worker age=120 idle=5 flags=N db=0 sub=0 psub=0 multi=-1 qbuf=26 qbuf-free=32742 obl=0 oll=0 omem=0 events=r cmd=client|list user=default\n" +
+	"id=4 addr=10.0.1.11:41000 laddr=127.0.0.1:6379 fd=9 name= age=7 idle=7 flags=N db=1 sub=0 psub=0 multi=-1 qbuf=0 qbuf-free=0 obl=0 oll=0 omem=0 events=r cmd=get user=default\n"
+
+func TestParseClientList(t *testing.T) {
+	clients := parseClientList(fakeClientList)
+	test.Equal(t, []ClientInfo{
+		{ID: 3, Addr: "127.0.0.1:52340", Name: "worker", Age: 120, Idle: 5, Cmd: "client|list"},
+		{ID: 4, Addr: "10.0.1.11:41000", Name: "", Age: 7, Idle: 7, Cmd: "get"},
+	}, clients)
+	test.Equal(t, 0, len(parseClientList("")))
+}
+
+func TestRedisClient_ClientListKill(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[1]) {
+		case "LIST":
+			return []byte(fakeClientList)
+		case "KILL":
+			return int64(1)
+		}
+		return errors.New("ERR unknown subcommand")
+	})
+	defer server.Close()
+	redisClient := GetRedisClient(server.URL())
+
+	clients, err := redisClient.ClientList()
+	test.Nil(t, err)
+	test.Equal(t, 2, len(clients))
+	test.Equal(t, "worker", clients[0].Name)
+
+	killed, err := redisClient.ClientKill(ClientKillFilter{ID: 4, Addr: "10.0.1.11:41000"})
+	test.Nil(t, err)
+	test.Equal(t, int64(1), killed)
+	commands := server.Commands()
+	test.Equal(t, []string{"CLIENT", "KILL", "ID", "4", "ADDR", "10.0.1.11:41000"}, commands[len(commands)-1])
+
+	_, err = redisClient.ClientKill(ClientKillFilter{})
+	test.NotNil(t, err)
+}
+
+func TestRedisClient_SetClientName(t *testing.T) {
+	newTestClient(t)
+	// dedicated client, so its connections are created by the test
+	redisClient := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 2, MaxActive: 3})
+	defer redisClient.Close()
+
+	test.NotNil(t, redisClient.SetClientName("dotweb test"))
+	test.Nil(t, redisClient.SetClientName("dotweb-test"))
+	name, err := redisClient.GetClientName()
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, "dotweb-test-1", name)
+
+	// every new connection is named
+	conn1 := redisClient.GetConn()
+	defer conn1.Close()
+	conn2 := redisClient.GetConn()
+	defer conn2.Close()
+	name, err = redis.String(conn2.Do("CLIENT", "GETNAME"))
+	test.Nil(t, err)
+	test.Equal(t, "dotweb-test-2", name)
+}
@@ -0,0 +1,127 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	defaultConsumePollTimeout  = time.Second
+	defaultConsumeRetryBackoff = time.Second
+	requeueAttempts            = 3 // attempts of the LPUSH of a failed item
+)
+
+// RequeueError is returned by ConsumeBlockingWithOptions when an item whose
+// handler failed could not be pushed back to the list, so the caller can
+// recover the item which is no longer in the list
+type RequeueError struct {
+	Item string // the item popped from the list
+	Err  error  // the error of the last LPUSH
+}
+
+func (e *RequeueError) Error() string {
+	return fmt.Sprintf("redisutil: requeue of item %q failed: %v", e.Item, e.Err)
+}
+
+// ConsumeOptions configures ConsumeBlockingWithOptions
+type ConsumeOptions struct {
+	// RequeueOnError pushes an item back to the list when its handler fails
+	RequeueOnError bool
+	// PollTimeout is the timeout of each BRPOP, it bounds how long a cancelled
+	// context takes to stop the loop. defaults to 1 second
+	PollTimeout time.Duration
+	// RetryBackoff is the wait before reconnecting after a connection error,
+	// of BRPOP or of the LPUSH requeuing an item, defaults to 1 second
+	RetryBackoff time.Duration
+}
+
+// ConsumeBlocking pops the items of the list specified by key with BRPOP and
+// calls handler for each of them until ctx is done, then returns ctx.Err(),
+// or until rc is closed, then returns ErrClientClosed.
+// connection errors are retried on a new connection, handler errors are ignored
+func (rc *RedisClient) ConsumeBlocking(ctx context.Context, key string, handler func(string) error) error {
+	return rc.ConsumeBlockingWithOptions(ctx, key, handler, ConsumeOptions{})
+}
+
+// ConsumeBlockingWithOptions is like ConsumeBlocking configured by opts
+func (rc *RedisClient) ConsumeBlockingWithOptions(ctx context.Context, key string, handler func(string) error, opts ConsumeOptions) error {
+	if opts.PollTimeout < time.Second {
+		opts.PollTimeout = defaultConsumePollTimeout
+	}
+	if opts.RetryBackoff <= 0 {
+		opts.RetryBackoff = defaultConsumeRetryBackoff
+	}
+
+	var conn redis.Conn
+	defer func() {
+		if conn != nil {
+			conn.Close()
+		}
+	}()
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// the connection in use keeps working after the pool is closed
+		if rc.isClosed() {
+			return ErrClientClosed
+		}
+		if conn == nil {
+			conn = rc.getConn()
+		}
+		reply, err := redis.Strings(conn.Do("BRPOP", key, int64(opts.PollTimeout/time.Second)))
+		if err == redis.ErrNil {
+			continue
+		}
+		if err != nil {
+			if conn.Err() == nil || err == ErrClientClosed {
+				// an application error like WRONGTYPE can not be fixed by retrying
+				return err
+			}
+			conn.Close()
+			conn = nil
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.RetryBackoff):
+			}
+			continue
+		}
+
+		item := reply[1]
+		if err := handler(item); err != nil && opts.RequeueOnError {
+			if conn, err = rc.requeue(ctx, conn, key, item, opts.RetryBackoff); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// requeue pushes item back to the list key, a connection error is retried on
+// a new connection after backoff. returns the connection to use afterwards,
+// and a *RequeueError if the item is not requeued
+func (rc *RedisClient) requeue(ctx context.Context, conn redis.Conn, key string, item string, backoff time.Duration) (redis.Conn, error) {
+	var err error
+	for attempt := 1; ; attempt++ {
+		if conn == nil {
+			conn = rc.getConn()
+		}
+		if _, err = conn.Do("LPUSH", key, item); err == nil {
+			return conn, nil
+		}
+		if conn.Err() == nil || err == ErrClientClosed || attempt == requeueAttempts {
+			break
+		}
+		conn.Close()
+		conn = nil
+		select {
+		case <-ctx.Done():
+			return nil, &RequeueError{Item: item, Err: err}
+		case <-time.After(backoff):
+		}
+	}
+	return conn, &RequeueError{Item: item, Err: err}
+}
@@ -0,0 +1,173 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_ConsumeBlocking(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:consume"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var items []string
+	done := make(chan error)
+	go func() {
+		done <- redisClient.ConsumeBlocking(ctx, key, func(item string) error {
+			items = append(items, item)
+			if len(items) == 3 {
+				cancel()
+			}
+			return errors.New("ignored")
+		})
+	}()
+	_, err := redisClient.RPush(key, "3", "2", "1")
+	test.Nil(t, err)
+
+	select {
+	case err := <-done:
+		test.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer not stopped")
+	}
+	test.Equal(t, []string{"1", "2", "3"}, items)
+}
+
+func TestRedisClient_ConsumeBlocking_Requeue(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:consume:requeue"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	attempts := make(map[string]int)
+	processed := make(chan string, 4)
+	go redisClient.ConsumeBlockingWithOptions(ctx, key, func(item string) error {
+		mu.Lock()
+		attempts[item]++
+		first := attempts[item] == 1
+		mu.Unlock()
+		if item == "flaky" && first {
+			return errors.New("temporary failure")
+		}
+		processed <- item
+		return nil
+	}, ConsumeOptions{RequeueOnError: true})
+
+	_, err := redisClient.RPush(key, "flaky", "ok")
+	test.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-processed:
+		case <-time.After(5 * time.Second):
+			t.Fatal("item not processed")
+		}
+	}
+	mu.Lock()
+	test.Equal(t, 2, attempts["flaky"])
+	test.Equal(t, 1, attempts["ok"])
+	mu.Unlock()
+}
+
+func TestRedisClient_ConsumeBlocking_Cancel(t *testing.T) {
+	redisClient := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := redisClient.ConsumeBlocking(ctx, "dotweb:test:consume:empty", func(string) error {
+		return nil
+	})
+	test.Equal(t, context.DeadlineExceeded, err)
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("consumer stopped after %v", elapsed)
+	}
+}
+
+func TestRedisClient_ConsumeBlocking_RequeueReconnect(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:consume:reconnect"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+	u, err := url.Parse(redisServerURL)
+	test.Nil(t, err)
+	proxy := newTCPProxy(t, u.Host)
+	defer proxy.Close()
+	u.Host = proxy.ln.Addr().String()
+	consumer := GetRedisClient(u.String())
+	defer consumer.Close()
+
+	// the connection drops while the item is handled, it is requeued on a
+	// new connection
+	ctx, cancel := context.WithCancel(context.Background())
+	var items []string
+	done := make(chan error)
+	go func() {
+		done <- consumer.ConsumeBlockingWithOptions(ctx, key, func(item string) error {
+			items = append(items, item)
+			if len(items) == 1 {
+				proxy.Break()
+				return errors.New("temporary failure")
+			}
+			cancel()
+			return nil
+		}, ConsumeOptions{RequeueOnError: true, RetryBackoff: 10 * time.Millisecond})
+	}()
+	_, err = redisClient.RPush(key, "item")
+	test.Nil(t, err)
+	select {
+	case err := <-done:
+		test.Equal(t, context.Canceled, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer not stopped")
+	}
+	test.Equal(t, []string{"item", "item"}, items)
+
+	// the server can not be reached anymore, the item is returned
+	redisClient.Del(key)
+	go func() {
+		done <- consumer.ConsumeBlockingWithOptions(context.Background(), key, func(item string) error {
+			proxy.Close()
+			return errors.New("temporary failure")
+		}, ConsumeOptions{RequeueOnError: true, RetryBackoff: 10 * time.Millisecond})
+	}()
+	_, err = redisClient.RPush(key, "lost")
+	test.Nil(t, err)
+	select {
+	case err := <-done:
+		requeueErr, ok := err.(*RequeueError)
+		test.Equal(t, true, ok)
+		test.Equal(t, "lost", requeueErr.Item)
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer not stopped")
+	}
+}
+
+func TestRedisClient_ConsumeBlocking_ClientClosed(t *testing.T) {
+	// a client of its own, closing the shared one would break other tests
+	consumer := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 7})
+	done := make(chan error)
+	go func() {
+		done <- consumer.ConsumeBlocking(context.Background(), "dotweb:test:consume:closed", func(string) error {
+			return nil
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	test.Nil(t, consumer.Close())
+	select {
+	case err := <-done:
+		test.Equal(t, ErrClientClosed, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("consumer not stopped after the client was closed")
+	}
+}
@@ -0,0 +1,168 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// blockingPollTimeout is the timeout in seconds of each blocking command
+// issued with a cancelable context, so a cancellation is noticed in time
+const blockingPollTimeout = 1
+
+// blockingDuration returns how long the command blocks if it is a blocking
+// command, 0 if it blocks indefinitely
+func blockingDuration(commandName string, args []interface{}) (time.Duration, bool) {
+	switch strings.ToUpper(commandName) {
+	case "BLPOP", "BRPOP", "BRPOPLPUSH", "BLMOVE", "BZPOPMIN", "BZPOPMAX":
+		if len(args) == 0 {
+			return 0, false
+		}
+		seconds, err := strconv.ParseFloat(fmt.Sprint(args[len(args)-1]), 64)
+		if err != nil {
+			return 0, false
+		}
+		return time.Duration(seconds * float64(time.Second)), true
+	case "XREAD", "XREADGROUP":
+		for i := 0; i < len(args)-1; i++ {
+			if strings.EqualFold(fmt.Sprint(args[i]), "BLOCK") {
+				ms, err := strconv.ParseInt(fmt.Sprint(args[i+1]), 10, 64)
+				if err != nil {
+					return 0, false
+				}
+				return time.Duration(ms) * time.Millisecond, true
+			}
+		}
+	case "WAIT":
+		if len(args) == 2 {
+			ms, err := strconv.ParseInt(fmt.Sprint(args[1]), 10, 64)
+			if err != nil {
+				return 0, false
+			}
+			return time.Duration(ms) * time.Millisecond, true
+		}
+	}
+	return 0, false
+}
+
+// getConn returns a connection from the pool, see getConnContext
+func (rc *RedisClient) getConn() redis.Conn {
+	return rc.getConnContext(context.Background())
+}
+
+// getConnContext returns a connection from the pool issuing the commands at
+// most until ctx is done, retried according to the retry policy and wrapped
+// to check the value types when CheckType mode is enabled
+func (rc *RedisClient) getConnContext(ctx context.Context) redis.Conn {
+	if rc.parent != nil {
+		return rc.prefixedConn(ctx)
+	}
+	conn := rc.commandConn(ctx)
+	if policy := rc.retryPolicy(); policy.maxRetries > 0 {
+		conn = &retryConn{Conn: conn, rc: rc, ctx: ctx, policy: policy}
+	}
+	return rc.typeCheckedConn(conn)
+}
+
+// poolConn returns a connection from the pool whose commands honor the
+// deadline and cancellation of ctx. if ctx is done before a connection is
+// available, the returned connection fails every command with ctx.Err()
+func (rc *RedisClient) poolConn(ctx context.Context) redis.Conn {
+	if atomic.LoadInt32(&rc.closed) == 1 {
+		return errorConn{ErrClientClosed}
+	}
+	if err := ctx.Err(); err != nil {
+		return errorConn{err}
+	}
+	if rc.saturated() {
+		defer rc.recordWait(time.Now())
+	}
+	if ctx.Done() == nil {
+		return rc.pool.Get()
+	}
+	conn, err := rc.pool.GetContext(ctx)
+	if err != nil {
+		return errorConn{err}
+	}
+	return &contextConn{Conn: conn, ctx: ctx}
+}
+
+// contextConn issues the commands with the deadline of ctx as timeout
+type contextConn struct {
+	redis.Conn
+	ctx context.Context
+}
+
+func (c *contextConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return c.Conn.Do(commandName, args...)
+	}
+	reply, err := redis.DoWithTimeout(c.Conn, time.Until(deadline), commandName, args...)
+	if err != nil && c.ctx.Err() != nil {
+		return nil, c.ctx.Err()
+	}
+	return reply, err
+}
+
+func (c *contextConn) Receive() (interface{}, error) {
+	if err := c.ctx.Err(); err != nil {
+		return nil, err
+	}
+	deadline, ok := c.ctx.Deadline()
+	if !ok {
+		return c.Conn.Receive()
+	}
+	reply, err := redis.ReceiveWithTimeout(c.Conn, time.Until(deadline))
+	if err != nil && c.ctx.Err() != nil {
+		return nil, c.ctx.Err()
+	}
+	return reply, err
+}
+
+// errorConn is returned instead of a connection which cannot be obtained
+type errorConn struct{ err error }
+
+func (c errorConn) Do(string, ...interface{}) (interface{}, error) { return nil, c.err }
+func (c errorConn) Send(string, ...interface{}) error              { return c.err }
+func (c errorConn) Err() error                                     { return c.err }
+func (c errorConn) Close() error                                   { return nil }
+func (c errorConn) Flush() error                                   { return c.err }
+func (c errorConn) Receive() (interface{}, error)                  { return nil, c.err }
+
+// blockingDo issues the blocking command cmd with args followed by a
+// timeout of defaultTimeout seconds, see blockingDoTimeout
+func (rc *RedisClient) blockingDo(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	return rc.blockingDoTimeout(ctx, defaultTimeout, cmd, args...)
+}
+
+// blockingDoTimeout issues the blocking command cmd with args followed by
+// timeout in seconds, 0 blocks indefinitely. if ctx can be canceled, the
+// command is issued repeatedly with a short timeout until it replies, ctx is
+// done or timeout is reached, so a cancellation aborts the wait
+func (rc *RedisClient) blockingDoTimeout(ctx context.Context, timeout int64, cmd string, args ...interface{}) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	if ctx.Done() == nil {
+		return conn.Do(cmd, append(args, timeout)...)
+	}
+	args = append(args, blockingPollTimeout)
+	for waited := int64(0); timeout == 0 || waited < timeout; waited += blockingPollTimeout {
+		reply, err := conn.Do(cmd, args...)
+		if err != nil || reply != nil {
+			return reply, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,59 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_BRPopCtxCancel(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:brpop:ctx"
+	redisClient.Del(key)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := redisClient.BRPopCtx(ctx, key)
+	test.Equal(t, context.Canceled, err)
+	if elapsed := time.Since(start); elapsed > 3*blockingPollTimeout*time.Second {
+		t.Errorf("BRPopCtx returned after %v", elapsed)
+	}
+
+	// the connection is still usable afterwards
+	_, err = redisClient.RPush(key, "item")
+	test.Nil(t, err)
+	val, err := redisClient.BRPopCtx(context.Background(), key)
+	test.Nil(t, err)
+	test.Equal(t, map[string]string{key: "item"}, val)
+}
+
+func TestRedisClient_GetCtxPoolDeadline(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []byte("value")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	redisClient.pool.MaxActive = 1
+	redisClient.pool.Wait = true
+
+	// the only connection is in use, so the deadline expires while waiting
+	conn := redisClient.GetConn()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := redisClient.GetCtx(ctx, "key")
+	test.Equal(t, context.DeadlineExceeded, err)
+	conn.Close()
+
+	val, err := redisClient.GetCtx(context.Background(), "key")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = redisClient.GetCtx(canceled, "key")
+	test.Equal(t, context.Canceled, err)
+}
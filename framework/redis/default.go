@@ -0,0 +1,79 @@
+package redisutil
+
+import "sync"
+
+var (
+	defaultMutex  sync.RWMutex
+	defaultClient *RedisClient
+)
+
+// SetDefault sets the client of address as the default client, which is
+// used by the package level functions like Get and Set
+func SetDefault(address string) {
+	client := GetRedisClient(address)
+	defaultMutex.Lock()
+	defaultClient = client
+	defaultMutex.Unlock()
+}
+
+// Default returns the default client set by SetDefault,
+// it panics if no default client is set
+func Default() *RedisClient {
+	defaultMutex.RLock()
+	client := defaultClient
+	defaultMutex.RUnlock()
+	if client == nil {
+		panic("redisutil: no default client, call SetDefault first")
+	}
+	return client
+}
+
+// Get calls Get of the default client
+func Get(key string) (string, error) {
+	return Default().Get(key)
+}
+
+// Set calls Set of the default client
+func Set(key string, val interface{}) (interface{}, error) {
+	return Default().Set(key, val)
+}
+
+// SetWithExpire calls SetWithExpire of the default client
+func SetWithExpire(key string, val interface{}, timeOutSeconds int64) (interface{}, error) {
+	return Default().SetWithExpire(key, val, timeOutSeconds)
+}
+
+// Del calls Del of the default client
+func Del(keys ...string) (int64, error) {
+	return Default().Del(keys...)
+}
+
+// Exists calls Exists of the default client
+func Exists(key string) (bool, error) {
+	return Default().Exists(key)
+}
+
+// Expire calls Expire of the default client
+func Expire(key string, timeOutSeconds int64) (int64, error) {
+	return Default().Expire(key, timeOutSeconds)
+}
+
+// TTL calls TTL of the default client
+func TTL(key string) (int64, error) {
+	return Default().TTL(key)
+}
+
+// INCR calls INCR of the default client
+func INCR(key string) (int, error) {
+	return Default().INCR(key)
+}
+
+// HGet calls HGet of the default client
+func HGet(hashID string, field string) (string, error) {
+	return Default().HGet(hashID, field)
+}
+
+// HSet calls HSet of the default client
+func HSet(hashID string, field string, val string) error {
+	return Default().HSet(hashID, field, val)
+}
@@ -0,0 +1,46 @@
+package redisutil
+
+import (
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestDefault(t *testing.T) {
+	defaultMutex.Lock()
+	defaultClient = nil
+	defaultMutex.Unlock()
+	func() {
+		defer func() {
+			test.Equal(t, "redisutil: no default client, call SetDefault first", recover())
+		}()
+		Get("key")
+	}()
+
+	redisClient := newTestClient(t)
+	SetDefault(redisServerURL)
+	test.Equal(t, redisClient, Default())
+	key := "dotweb:test:default"
+	defer Del(key)
+
+	_, err := Set(key, "value")
+	test.Nil(t, err)
+	val, err := Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	val, err = redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	_, err = SetWithExpire(key, "expiring", 100)
+	test.Nil(t, err)
+	ttl, err := TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, true, ttl > 90 && ttl <= 100)
+	deleted, err := Del(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), deleted)
+	exists, err := Exists(key)
+	test.Nil(t, err)
+	test.Equal(t, false, exists)
+}
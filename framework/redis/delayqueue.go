@@ -0,0 +1,55 @@
+package redisutil
+
+import (
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// pollDueScript removes and returns at most ARGV[2] members of the sorted set
+// KEYS[1] with a score <= ARGV[1]
+var pollDueScript = redis.NewScript(1, `
+local jobs = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[2])
+if #jobs > 0 then
+	redis.call('ZREM', KEYS[1], unpack(jobs))
+end
+return jobs
+`)
+
+// DelayedQueue is a queue of unique jobs executed at a scheduled time,
+// jobs are stored in a sorted set scored by their execute time in unix millis
+type DelayedQueue struct {
+	rc   *RedisClient
+	name string
+}
+
+// DelayedQueue returns the DelayedQueue stored at key name
+func (rc *RedisClient) DelayedQueue(name string) *DelayedQueue {
+	return &DelayedQueue{rc: rc, name: name}
+}
+
+// Schedule adds job to be executed at runAt,
+// scheduling a job already in the queue updates its execute time
+func (q *DelayedQueue) Schedule(job string, runAt time.Time) error {
+	conn := q.rc.getConn()
+	defer conn.Close()
+	_, err := conn.Do("ZADD", q.name, unixMillis(runAt), job)
+	return err
+}
+
+// PollDue atomically removes and returns at most max jobs due at now,
+// in execute time order. max <= 0 returns all due jobs
+func (q *DelayedQueue) PollDue(now time.Time, max int64) ([]string, error) {
+	if max <= 0 {
+		max = -1
+	}
+	conn := q.rc.getConn()
+	defer conn.Close()
+	jobs, err := redis.Strings(pollDueScript.Do(conn, q.name, unixMillis(now), max))
+	return jobs, err
+}
+
+// unixMillis returns t as milliseconds elapsed since January 1, 1970 UTC
+func unixMillis(t time.Time) int64 {
+	return t.UnixNano() / int64(time.Millisecond)
+}
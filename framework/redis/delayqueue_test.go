@@ -0,0 +1,41 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestDelayedQueue(t *testing.T) {
+	redisClient := newTestClient(t)
+	name := "dotweb:test:delayed"
+	redisClient.Del(name)
+	defer redisClient.Del(name)
+
+	queue := redisClient.DelayedQueue(name)
+	now := time.Now()
+	test.Nil(t, queue.Schedule("past-2", now.Add(-time.Minute)))
+	test.Nil(t, queue.Schedule("past-1", now.Add(-2*time.Minute)))
+	test.Nil(t, queue.Schedule("now", now))
+	test.Nil(t, queue.Schedule("future", now.Add(time.Hour)))
+	// scheduling again moves the job instead of duplicating it
+	test.Nil(t, queue.Schedule("moved", now.Add(time.Hour)))
+	test.Nil(t, queue.Schedule("moved", now.Add(-time.Hour)))
+
+	jobs, err := queue.PollDue(now, 2)
+	test.Nil(t, err)
+	test.Equal(t, []string{"moved", "past-1"}, jobs)
+
+	jobs, err = queue.PollDue(now, 0)
+	test.Nil(t, err)
+	test.Equal(t, []string{"past-2", "now"}, jobs)
+
+	jobs, err = queue.PollDue(now, 0)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(jobs))
+
+	jobs, err = queue.PollDue(now.Add(2*time.Hour), 0)
+	test.Nil(t, err)
+	test.Equal(t, []string{"future"}, jobs)
+}
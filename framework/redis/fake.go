@@ -0,0 +1,244 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// RedisStore is the common key/value and hash methods of RedisClient,
+// so code using them can be tested with a FakeClient instead of a server
+type RedisStore interface {
+	Get(key string) (string, error)
+	Set(key string, val interface{}) (interface{}, error)
+	SetWithExpire(key string, val interface{}, timeOutSeconds int64) (interface{}, error)
+	Del(keys ...string) (int64, error)
+	Exists(key string) (bool, error)
+	Expire(key string, timeOutSeconds int64) (int64, error)
+	TTL(key string) (int64, error)
+	HGet(hashID string, field string) (string, error)
+	HGetAll(hashID string) (map[string]string, error)
+	HSet(hashID string, field string, val string) error
+}
+
+var (
+	_ RedisStore = (*RedisClient)(nil)
+	_ RedisStore = (*FakeClient)(nil)
+)
+
+// wrongTypeReply is the redis.Error replied by a real server for a command on
+// a key of another type, FakeClient returns it instead of the *ErrWrongType
+// of the CheckType mode, so callers handle it like the reply of a server
+var wrongTypeReply = redis.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+
+// FakeClient is an in-memory RedisStore for tests which do not have a redis
+// server. the expired keys are removed when they are accessed
+type FakeClient struct {
+	now func() time.Time
+
+	mu   sync.Mutex
+	keys map[string]*fakeEntry
+}
+
+type fakeEntry struct {
+	value   string
+	hash    map[string]string // nil for a string
+	expires time.Time         // zero if no expire
+}
+
+// NewFakeClient returns an empty FakeClient whose expires are evaluated with
+// now, time.Now if now is nil, so tests can move the clock forward
+func NewFakeClient(now func() time.Time) *FakeClient {
+	if now == nil {
+		now = time.Now
+	}
+	return &FakeClient{now: now, keys: make(map[string]*fakeEntry)}
+}
+
+// Get returns the content of key, returns ErrNil if key does not exists
+func (c *FakeClient) Get(key string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.lookup(key)
+	if entry == nil {
+		return "", ErrNil
+	}
+	if entry.hash != nil {
+		return "", wrongTypeReply
+	}
+	return entry.value, nil
+}
+
+// Set sets key to val without expire
+func (c *FakeClient) Set(key string, val interface{}) (interface{}, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys[key] = &fakeEntry{value: fakeString(val)}
+	return "OK", nil
+}
+
+// SetWithExpire sets key to val with an expire of timeOutSeconds
+func (c *FakeClient) SetWithExpire(key string, val interface{}, timeOutSeconds int64) (interface{}, error) {
+	if timeOutSeconds <= 0 {
+		return nil, redis.Error("ERR invalid expire time in 'set' command")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys[key] = &fakeEntry{value: fakeString(val), expires: c.expiresAt(timeOutSeconds)}
+	return "OK", nil
+}
+
+// Del deletes the specified keys, returns the count of keys which existed
+func (c *FakeClient) Del(keys ...string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var n int64
+	for _, key := range keys {
+		if c.lookup(key) != nil {
+			delete(c.keys, key)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Exists returns whether key exists
+func (c *FakeClient) Exists(key string) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lookup(key) != nil, nil
+}
+
+// Expire sets the expire of key to timeOutSeconds, a key expiring now is
+// deleted. returns 0 if key does not exists
+func (c *FakeClient) Expire(key string, timeOutSeconds int64) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.lookup(key)
+	if entry == nil {
+		return 0, nil
+	}
+	if timeOutSeconds <= 0 {
+		delete(c.keys, key)
+		return 1, nil
+	}
+	entry.expires = c.expiresAt(timeOutSeconds)
+	return 1, nil
+}
+
+// TTL returns the remaining time to live of key in seconds,
+// returns -2 if key does not exists and -1 if key has no expire
+func (c *FakeClient) TTL(key string) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.lookup(key)
+	if entry == nil {
+		return -2, nil
+	}
+	if entry.expires.IsZero() {
+		return -1, nil
+	}
+	// rounded like redis
+	return int64((entry.expires.Sub(c.now()) + 500*time.Millisecond) / time.Second), nil
+}
+
+// HGet returns the content of field of hashID,
+// returns ErrNil if hashID or field does not exists
+func (c *FakeClient) HGet(hashID string, field string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash, err := c.lookupHash(hashID)
+	if err != nil {
+		return "", err
+	}
+	val, ok := hash[field]
+	if !ok {
+		return "", ErrNil
+	}
+	return val, nil
+}
+
+// HGetAll returns all fields of hashID, returns empty if hashID does not exists
+func (c *FakeClient) HGetAll(hashID string) (map[string]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	hash, err := c.lookupHash(hashID)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(hash))
+	for field, val := range hash {
+		result[field] = val
+	}
+	return result, nil
+}
+
+// HSet sets field of hashID to val, creating the hash if it does not exists
+func (c *FakeClient) HSet(hashID string, field string, val string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.lookup(hashID)
+	if entry == nil {
+		entry = &fakeEntry{hash: make(map[string]string)}
+		c.keys[hashID] = entry
+	}
+	if entry.hash == nil {
+		return wrongTypeReply
+	}
+	entry.hash[field] = val
+	return nil
+}
+
+// lookup returns the entry of key, nil if it does not exists.
+// the entry is removed if it is expired
+func (c *FakeClient) lookup(key string) *fakeEntry {
+	entry, ok := c.keys[key]
+	if !ok {
+		return nil
+	}
+	if !entry.expires.IsZero() && !c.now().Before(entry.expires) {
+		delete(c.keys, key)
+		return nil
+	}
+	return entry
+}
+
+// lookupHash returns the fields of the hash hashID, nil if it does not exists
+func (c *FakeClient) lookupHash(hashID string) (map[string]string, error) {
+	entry := c.lookup(hashID)
+	if entry == nil {
+		return nil, nil
+	}
+	if entry.hash == nil {
+		return nil, wrongTypeReply
+	}
+	return entry.hash, nil
+}
+
+func (c *FakeClient) expiresAt(seconds int64) time.Time {
+	return c.now().Add(time.Duration(seconds) * time.Second)
+}
+
+// fakeString converts val to the content stored by redis for it
+func fakeString(val interface{}) string {
+	switch v := val.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,98 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestFakeClient(t *testing.T) {
+	var store RedisStore = NewFakeClient(nil)
+	_, err := store.Get("key")
+	test.Equal(t, ErrNil, err)
+	_, err = store.Set("key", 42)
+	test.Nil(t, err)
+	val, err := store.Get("key")
+	test.Nil(t, err)
+	test.Equal(t, "42", val)
+	ok, err := store.Exists("key")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ttl, err := store.TTL("key")
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), ttl)
+
+	test.Nil(t, store.HSet("hash", "name", "dotweb"))
+	field, err := store.HGet("hash", "name")
+	test.Nil(t, err)
+	test.Equal(t, "dotweb", field)
+	_, err = store.HGet("hash", "missing")
+	test.Equal(t, ErrNil, err)
+	fields, err := store.HGetAll("hash")
+	test.Nil(t, err)
+	test.Equal(t, map[string]string{"name": "dotweb"}, fields)
+	fields, err = store.HGetAll("missing")
+	test.Nil(t, err)
+	test.Equal(t, map[string]string{}, fields)
+
+	// the types are checked like redis, with the reply of a server
+	_, err = store.Get("hash")
+	_, ok = err.(redis.Error)
+	test.Equal(t, true, ok)
+	test.Contains(t, "WRONGTYPE", err.Error())
+	test.Equal(t, wrongTypeReply, store.HSet("key", "name", "dotweb"))
+
+	n, err := store.Del("key", "hash", "missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(2), n)
+	ttl, err = store.TTL("key")
+	test.Nil(t, err)
+	test.Equal(t, int64(-2), ttl)
+}
+
+func TestFakeClient_Expire(t *testing.T) {
+	now := time.Now()
+	store := NewFakeClient(func() time.Time { return now })
+	_, err := store.SetWithExpire("key", "value", 10)
+	test.Nil(t, err)
+	test.Nil(t, store.HSet("hash", "name", "dotweb"))
+	n, err := store.Expire("hash", 20)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), n)
+	n, err = store.Expire("missing", 20)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), n)
+
+	now = now.Add(5 * time.Second)
+	ttl, err := store.TTL("key")
+	test.Nil(t, err)
+	test.Equal(t, int64(5), ttl)
+	val, err := store.Get("key")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	// the key is gone once the clock reaches its expire
+	now = now.Add(5 * time.Second)
+	_, err = store.Get("key")
+	test.Equal(t, ErrNil, err)
+	ok, err := store.Exists("hash")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	now = now.Add(10 * time.Second)
+	_, err = store.HGet("hash", "name")
+	test.Equal(t, ErrNil, err)
+
+	// Set removes the expire
+	_, err = store.SetWithExpire("key", "value", 10)
+	test.Nil(t, err)
+	_, err = store.Set("key", "value")
+	test.Nil(t, err)
+	now = now.Add(time.Minute)
+	val, err = store.Get("key")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	_, err = store.SetWithExpire("key", "value", 0)
+	test.NotNil(t, err)
+}
@@ -0,0 +1,144 @@
+package redisutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Hook is called around the commands of a RedisClient, e.g. to record the
+// latency and the errors per command
+type Hook interface {
+	// Before is called before cmd is issued
+	Before(cmd string, args []interface{})
+	// After is called once the reply of cmd is read, err is the error of the
+	// connection or the error replied by the server, dur is the time since Before
+	After(cmd string, err error, dur time.Duration)
+}
+
+// AddHook adds hook which is called around each command issued by rc,
+// including the commands of pipelines and transactions. hooks are called in
+// the order they were added, connections already in use are not affected
+func (rc *RedisClient) AddHook(hook Hook) *RedisClient {
+	if rc.parent != nil {
+		rc.parent.AddHook(hook)
+		return rc
+	}
+	rc.hookMutex.Lock()
+	rc.cmdHooks = append(rc.cmdHooks, hook)
+	rc.hookMutex.Unlock()
+	return rc
+}
+
+// SetHook replaces all the hooks added before with hook, nil removes them
+func (rc *RedisClient) SetHook(hook Hook) *RedisClient {
+	if rc.parent != nil {
+		rc.parent.SetHook(hook)
+		return rc
+	}
+	rc.hookMutex.Lock()
+	rc.cmdHooks = nil
+	if hook != nil {
+		rc.cmdHooks = []Hook{hook}
+	}
+	rc.hookMutex.Unlock()
+	return rc
+}
+
+// commandConn returns a connection from the pool like poolConn, whose
+// commands are reported to the hooks
+func (rc *RedisClient) commandConn(ctx context.Context) redis.Conn {
+	conn := rc.poolConn(ctx)
+	rc.hookMutex.RLock()
+	hooks := rc.cmdHooks
+	if rc.logHook != nil {
+		hooks = append(hooks[:len(hooks):len(hooks)], rc.logHook)
+	}
+	rc.hookMutex.RUnlock()
+	if len(hooks) == 0 {
+		return conn
+	}
+	return &hookConn{Conn: conn, hooks: hooks}
+}
+
+// argsHook is implemented by the hooks which are told the count of
+// arguments of the commands in After, like the hook of SetLogger
+type argsHook interface {
+	afterArgs(cmd string, args int, err error, dur time.Duration)
+}
+
+// sentCommand is a command sent whose reply is not read yet
+type sentCommand struct {
+	name  string
+	args  int
+	start time.Time
+}
+
+// hookConn calls the hooks around each command
+type hookConn struct {
+	redis.Conn
+	hooks   []Hook
+	pending []sentCommand
+}
+
+func (c *hookConn) before(commandName string, args []interface{}) sentCommand {
+	for _, hook := range c.hooks {
+		hook.Before(commandName, args)
+	}
+	return sentCommand{name: commandName, args: len(args), start: time.Now()}
+}
+
+func (c *hookConn) after(cmd sentCommand, err error) {
+	dur := time.Since(cmd.start)
+	for _, hook := range c.hooks {
+		if h, ok := hook.(argsHook); ok {
+			h.afterArgs(cmd.name, cmd.args, err, dur)
+			continue
+		}
+		hook.After(cmd.name, err, dur)
+	}
+}
+
+func (c *hookConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		// flushes and reads the replies of the commands sent
+		reply, err := c.Conn.Do(commandName, args...)
+		c.afterPending(err)
+		return reply, err
+	}
+	cmd := c.before(commandName, args)
+	reply, err := c.Conn.Do(commandName, args...)
+	c.afterPending(err)
+	c.after(cmd, err)
+	return reply, err
+}
+
+func (c *hookConn) Send(commandName string, args ...interface{}) error {
+	cmd := c.before(commandName, args)
+	err := c.Conn.Send(commandName, args...)
+	if err != nil {
+		c.after(cmd, err)
+		return err
+	}
+	c.pending = append(c.pending, cmd)
+	return nil
+}
+
+func (c *hookConn) Receive() (interface{}, error) {
+	reply, err := c.Conn.Receive()
+	if len(c.pending) > 0 {
+		cmd := c.pending[0]
+		c.pending = c.pending[1:]
+		c.after(cmd, err)
+	}
+	return reply, err
+}
+
+// afterPending calls the hooks for the commands sent whose replies were read by Do
+func (c *hookConn) afterPending(err error) {
+	for _, cmd := range c.pending {
+		c.after(cmd, err)
+	}
+	c.pending = nil
+}
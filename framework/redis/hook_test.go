@@ -0,0 +1,122 @@
+package redisutil
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+type hookCall struct {
+	hook string
+	cmd  string
+	err  error
+	dur  time.Duration
+}
+
+// recordingHook records the calls of After, and checks Before was called first
+type recordingHook struct {
+	name   string
+	mu     *sync.Mutex
+	calls  *[]hookCall
+	before map[string]int
+}
+
+func (h *recordingHook) Before(cmd string, args []interface{}) {
+	h.mu.Lock()
+	h.before[cmd]++
+	h.mu.Unlock()
+}
+
+func (h *recordingHook) After(cmd string, err error, dur time.Duration) {
+	h.mu.Lock()
+	if h.before[cmd] == 0 {
+		panic("After called without Before for " + cmd)
+	}
+	h.before[cmd]--
+	*h.calls = append(*h.calls, hookCall{hook: h.name, cmd: cmd, err: err, dur: dur})
+	h.mu.Unlock()
+}
+
+func newHookServer(t *testing.T) *fakeServer {
+	return newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "SET":
+			time.Sleep(10 * time.Millisecond)
+			return "OK"
+		case "INCR":
+			return int64(1)
+		}
+		return errors.New("ERR unknown command")
+	})
+}
+
+func TestRedisClient_AddHook(t *testing.T) {
+	server := newHookServer(t)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var calls []hookCall
+	redisClient := GetRedisClient(server.URL()).
+		AddHook(&recordingHook{name: "first", mu: &mu, calls: &calls, before: map[string]int{}}).
+		AddHook(&recordingHook{name: "second", mu: &mu, calls: &calls, before: map[string]int{}})
+
+	_, err := redisClient.Set("dotweb:test:hook", "value")
+	test.Nil(t, err)
+	_, err = redisClient.Get("dotweb:test:hook")
+	test.NotNil(t, err)
+
+	test.Equal(t, 4, len(calls))
+	test.Equal(t, "first", calls[0].hook)
+	test.Equal(t, "second", calls[1].hook)
+	test.Equal(t, "SET", calls[0].cmd)
+	test.Nil(t, calls[0].err)
+	test.Equal(t, true, calls[0].dur >= 10*time.Millisecond)
+	test.Equal(t, "GET", calls[2].cmd)
+	test.Equal(t, err, calls[2].err)
+	test.Equal(t, "GET", calls[3].cmd)
+}
+
+func TestRedisClient_SetHookPipeline(t *testing.T) {
+	server := newHookServer(t)
+	defer server.Close()
+
+	var mu sync.Mutex
+	var calls []hookCall
+	redisClient := GetRedisClient(server.URL()).
+		AddHook(&recordingHook{name: "removed", mu: &mu, calls: &calls, before: map[string]int{}}).
+		SetHook(&recordingHook{name: "hook", mu: &mu, calls: &calls, before: map[string]int{}})
+
+	p := redisClient.Pipeline()
+	defer p.Close()
+	p.Send("INCR", "dotweb:test:hook")
+	p.Send("GET", "dotweb:test:hook")
+	_, err := p.Exec()
+	test.Nil(t, err)
+	test.Equal(t, 2, len(calls))
+	test.Equal(t, "hook", calls[0].hook)
+	test.Equal(t, "INCR", calls[0].cmd)
+	test.Nil(t, calls[0].err)
+	test.Equal(t, "GET", calls[1].cmd)
+	test.NotNil(t, calls[1].err)
+
+	// commands of a transaction are read by EXEC
+	calls = nil
+	tx, err := redisClient.Begin()
+	test.Nil(t, err)
+	test.Nil(t, tx.Send("INCR", "dotweb:test:hook"))
+	tx.Exec()
+	cmds := make([]string, len(calls))
+	for i, call := range calls {
+		cmds[i] = call.cmd
+	}
+	test.Equal(t, []string{"MULTI", "INCR", "EXEC"}, cmds)
+
+	redisClient.SetHook(nil)
+	calls = nil
+	redisClient.Set("dotweb:test:hook", "value")
+	test.Equal(t, 0, len(calls))
+}
@@ -0,0 +1,78 @@
+package redisutil
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ServerStats are common statistics of the INFO of the server
+type ServerStats struct {
+	UsedMemory             int64 // bytes allocated by redis
+	ConnectedClients       int64 // count of client connections
+	InstantaneousOpsPerSec int64 // count of commands processed per second
+}
+
+// Info returns the fields of INFO, optionally only of the specified sections
+// like "memory" or "stats", by name
+func (rc *RedisClient) Info(section ...string) (map[string]string, error) {
+	return rc.InfoCtx(context.Background(), section...)
+}
+
+// InfoCtx is like Info but honors the deadline and cancellation of ctx
+func (rc *RedisClient) InfoCtx(ctx context.Context, section ...string) (map[string]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	text, err := redis.String(conn.Do("INFO", redis.Args{}.AddFlat(section)...))
+	if err != nil {
+		return nil, err
+	}
+	return parseInfo(text), nil
+}
+
+// InfoStats returns the common statistics of INFO, the fields missing in
+// INFO are left zero
+func (rc *RedisClient) InfoStats() (ServerStats, error) {
+	return rc.InfoStatsCtx(context.Background())
+}
+
+// InfoStatsCtx is like InfoStats but honors the deadline and cancellation of ctx
+func (rc *RedisClient) InfoStatsCtx(ctx context.Context) (ServerStats, error) {
+	var stats ServerStats
+	info, err := rc.InfoCtx(ctx)
+	if err != nil {
+		return stats, err
+	}
+	for name, field := range map[string]*int64{
+		"used_memory":               &stats.UsedMemory,
+		"connected_clients":         &stats.ConnectedClients,
+		"instantaneous_ops_per_sec": &stats.InstantaneousOpsPerSec,
+	} {
+		value, ok := info[name]
+		if !ok {
+			continue
+		}
+		if *field, err = strconv.ParseInt(value, 10, 64); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// parseInfo parses the "name:value" lines of INFO,
+// the "# Section" headers and the blank lines are skipped
+func parseInfo(text string) map[string]string {
+	info := make(map[string]string)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			info[line[:i]] = line[i+1:]
+		}
+	}
+	return info
+}
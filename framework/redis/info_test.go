@@ -0,0 +1,55 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+const infoPayload = "# Server\r\n" +
+	"redis_version:7.2.4\r\n" +
+	"redis_mode:standalone\r\n" +
+	"\r\n" +
+	"# Clients\r\n" +
+	"connected_clients:12\r\n" +
+	"\r\n" +
+	"# Memory\r\n" +
+	"used_memory:1048576\r\n" +
+	"used_memory_human:1.00M\r\n" +
+	"\r\n" +
+	"# Stats\r\n" +
+	"instantaneous_ops_per_sec:345\r\n" +
+	"\r\n" +
+	"# Keyspace\r\n" +
+	"db0:keys=10,expires=2,avg_ttl=0\r\n"
+
+func TestRedisClient_Info(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []byte(infoPayload)
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	info, err := redisClient.Info()
+	test.Nil(t, err)
+	test.Equal(t, 7, len(info))
+	test.Equal(t, "7.2.4", info["redis_version"])
+	test.Equal(t, "1.00M", info["used_memory_human"])
+	test.Equal(t, "keys=10,expires=2,avg_ttl=0", info["db0"])
+
+	stats, err := redisClient.InfoStats()
+	test.Nil(t, err)
+	test.Equal(t, ServerStats{UsedMemory: 1048576, ConnectedClients: 12, InstantaneousOpsPerSec: 345}, stats)
+
+	_, err = redisClient.Info("memory")
+	test.Nil(t, err)
+	test.Equal(t, []string{"INFO", "memory"}, server.Commands()[2])
+
+	// a canceled context issues no command
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = redisClient.InfoStatsCtx(ctx)
+	test.Equal(t, context.Canceled, err)
+	test.Equal(t, 3, len(server.Commands()))
+}
@@ -0,0 +1,57 @@
+package redisutil
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// SetJSON stores v encoded as JSON at key
+func (rc *RedisClient) SetJSON(key string, v interface{}) error {
+	return rc.SetJSONCtx(context.Background(), key, v)
+}
+
+// SetJSONCtx is like SetJSON but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetJSONCtx(ctx context.Context, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return rc.SetBytesCtx(ctx, key, data)
+}
+
+// SetJSONWithExpire stores v encoded as JSON at key with the expire duration
+// of timeOutSeconds, no expire if timeOutSeconds is not positive
+func (rc *RedisClient) SetJSONWithExpire(key string, v interface{}, timeOutSeconds int64) error {
+	return rc.SetJSONWithExpireCtx(context.Background(), key, v, timeOutSeconds)
+}
+
+// SetJSONWithExpireCtx is like SetJSONWithExpire but honors the deadline and
+// cancellation of ctx
+func (rc *RedisClient) SetJSONWithExpireCtx(ctx context.Context, key string, v interface{}, timeOutSeconds int64) error {
+	if timeOutSeconds <= 0 {
+		return rc.SetJSONCtx(ctx, key, v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err = conn.Do("SET", key, data, "EX", timeOutSeconds)
+	return err
+}
+
+// GetJSON decodes the JSON stored at key into dest,
+// returns ErrNil without changing dest if key does not exists
+func (rc *RedisClient) GetJSON(key string, dest interface{}) error {
+	return rc.GetJSONCtx(context.Background(), key, dest)
+}
+
+// GetJSONCtx is like GetJSON but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetJSONCtx(ctx context.Context, key string, dest interface{}) error {
+	data, err := rc.GetBytesCtx(ctx, key)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, dest)
+}
@@ -0,0 +1,54 @@
+package redisutil
+
+import (
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+type jsonTestUser struct {
+	Name    string
+	Tags    []string
+	Address struct {
+		City string
+		Zip  int
+	}
+	Attrs map[string]interface{}
+}
+
+func TestRedisClient_JSON(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:json"
+	defer redisClient.Del(key)
+
+	user := jsonTestUser{Name: "dotweb \xe4\xb8\xad", Tags: []string{"go", "web"}}
+	user.Address.City = "Hangzhou"
+	user.Address.Zip = 310000
+	user.Attrs = map[string]interface{}{"admin": true}
+	test.Nil(t, redisClient.SetJSON(key, user))
+
+	var got jsonTestUser
+	test.Nil(t, redisClient.GetJSON(key, &got))
+	test.Equal(t, user, got)
+
+	test.Nil(t, redisClient.SetJSONWithExpire(key, user, 100))
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 0 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+
+	// a TTL which is not positive stores without expire
+	test.Nil(t, redisClient.SetJSONWithExpire(key, user, 0))
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), ttl)
+	got = jsonTestUser{}
+	test.Nil(t, redisClient.GetJSON(key, &got))
+	test.Equal(t, user, got)
+
+	// dest is left untouched for a missing key
+	untouched := jsonTestUser{Name: "untouched"}
+	test.Equal(t, ErrNil, redisClient.GetJSON("dotweb:test:json:missing", &untouched))
+	test.Equal(t, "untouched", untouched.Name)
+}
@@ -0,0 +1,57 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keyEvents are the events of the keyspace notifications
+var keyEvents = map[string]bool{
+	"del": true, "rename_from": true, "rename_to": true, "copy_to": true, "move_from": true, "move_to": true,
+	"restore": true, "expire": true, "persist": true, "expired": true, "evicted": true, "new": true,
+	"sortstore": true, "append": true, "set": true, "setrange": true, "incrby": true, "incrbyfloat": true,
+	"lpush": true, "rpush": true, "lpop": true, "rpop": true, "linsert": true, "lset": true, "lrem": true, "ltrim": true,
+	"hset": true, "hincrby": true, "hincrbyfloat": true, "hdel": true,
+	"sadd": true, "srem": true, "spop": true, "sinterstore": true, "sunionstore": true, "sdiffstore": true,
+	"zincr": true, "zadd": true, "zrem": true, "zrembyscore": true, "zrembyrank": true,
+	"zdiffstore": true, "zinterstore": true, "zunionstore": true,
+	"xadd": true, "xdel": true, "xtrim": true, "xsetid": true, "xgroup-create": true, "xgroup-createconsumer": true,
+	"xgroup-delconsumer": true, "xgroup-destroy": true, "xgroup-setid": true,
+}
+
+// SubscribeKeyEvents subscribes to the keyspace notifications of the events
+// eventTypes, like "expired" or "evicted", of the database db, all databases
+// if db is negative. every event if eventTypes is empty. the server sends the
+// notifications only if enabled by its notify-keyspace-events setting, like
+// "Exe" for expired and evicted events. use ReceiveKeyEvent to read them
+func (rc *RedisClient) SubscribeKeyEvents(db int, eventTypes []string) (*Subscription, error) {
+	prefix := "__keyevent@*__:"
+	if db >= 0 {
+		prefix = "__keyevent@" + strconv.Itoa(db) + "__:"
+	}
+	if len(eventTypes) == 0 {
+		return rc.PSubscribe(prefix + "*")
+	}
+	patterns := make([]string, len(eventTypes))
+	for i, event := range eventTypes {
+		if !keyEvents[event] {
+			return nil, fmt.Errorf("redisutil: unknown keyspace event %q", event)
+		}
+		patterns[i] = prefix + event
+	}
+	return rc.PSubscribe(patterns...)
+}
+
+// ReceiveKeyEvent is like Receive for a subscription of SubscribeKeyEvents,
+// it returns the event and the key of the next notification
+func (s *Subscription) ReceiveKeyEvent() (event string, key string, err error) {
+	msg, err := s.ReceiveMessage()
+	if err != nil {
+		return "", "", err
+	}
+	if i := strings.Index(msg.Channel, "__:"); i >= 0 {
+		event = msg.Channel[i+len("__:"):]
+	}
+	return event, msg.Payload, nil
+}
@@ -0,0 +1,77 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRedisClient_SubscribeKeyEvents(t *testing.T) {
+	redisClient := newTestClient(t)
+	s, err := redisClient.SubscribeKeyEvents(0, []string{"expired", "evicted"})
+	test.Nil(t, err)
+	defer s.Close()
+	test.Equal(t, 2, s.Count())
+
+	// the notifications are published like the server does
+	publish(t, redisClient, "__keyevent@1__:expired", "other db")
+	publish(t, redisClient, "__keyevent@0__:del", "not subscribed")
+	publish(t, redisClient, "__keyevent@0__:evicted", "dotweb:test:evicted")
+	event, key, err := s.ReceiveKeyEvent()
+	test.Nil(t, err)
+	test.Equal(t, "evicted", event)
+	test.Equal(t, "dotweb:test:evicted", key)
+
+	_, err = redisClient.SubscribeKeyEvents(0, []string{"expired", "expird"})
+	test.NotNil(t, err)
+	test.Equal(t, `redisutil: unknown keyspace event "expird"`, err.Error())
+}
+
+func TestRedisClient_SubscribeKeyEventsExpired(t *testing.T) {
+	redisClient := newTestClient(t)
+	conn := redisClient.GetConn()
+	defer conn.Close()
+	config, err := redis.StringMap(conn.Do("CONFIG", "GET", "notify-keyspace-events"))
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	_, err = conn.Do("CONFIG", "SET", "notify-keyspace-events", "Ex")
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	defer conn.Do("CONFIG", "SET", "notify-keyspace-events", config["notify-keyspace-events"])
+
+	s, err := redisClient.SubscribeKeyEvents(-1, []string{"expired"})
+	test.Nil(t, err)
+	defer s.Close()
+	key := "dotweb:test:keyevent:expired"
+	_, err = conn.Do("SET", key, "value", "PX", 50)
+	test.Nil(t, err)
+
+	events := make(chan [2]string, 16)
+	go func() {
+		for {
+			event, key, err := s.ReceiveKeyEvent()
+			if err != nil {
+				return
+			}
+			select {
+			case events <- [2]string{event, key}:
+			default:
+			}
+		}
+	}()
+	// expired keys are notified once the server notices the expiration
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case event := <-events:
+			if event[1] == key {
+				test.Equal(t, "expired", event[0])
+				return
+			}
+		case <-timeout:
+			t.Skip("no expired notification, keyspace notifications not supported by the server")
+		}
+	}
+}
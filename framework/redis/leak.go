@@ -0,0 +1,50 @@
+package redisutil
+
+import (
+	"log"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// logLeak logs the warnings of the leak detection
+var logLeak = log.Printf
+
+// EnableLeakDetection logs a warning with the stack of the caller of GetConn
+// if the connection is not closed within threshold, to find a forgotten
+// Close. capturing the stacks is costly, so it is meant for debugging.
+// threshold 0 disables the detection, which is the default
+func (rc *RedisClient) EnableLeakDetection(threshold time.Duration) {
+	if rc.parent != nil {
+		rc.parent.EnableLeakDetection(threshold)
+		return
+	}
+	atomic.StoreInt64(&rc.leakNanos, int64(threshold))
+}
+
+func (rc *RedisClient) leakThreshold() time.Duration {
+	if rc.parent != nil {
+		return rc.parent.leakThreshold()
+	}
+	return time.Duration(atomic.LoadInt64(&rc.leakNanos))
+}
+
+// leakConn warns if it is not closed before its timer fires
+type leakConn struct {
+	redis.Conn
+	timer *time.Timer
+}
+
+func newLeakConn(conn redis.Conn, threshold time.Duration) *leakConn {
+	stack := debug.Stack()
+	return &leakConn{Conn: conn, timer: time.AfterFunc(threshold, func() {
+		logLeak("redisutil: connection not closed %s after it was borrowed by:\n%s", threshold, stack)
+	})}
+}
+
+func (c *leakConn) Close() error {
+	c.timer.Stop()
+	return c.Conn.Close()
+}
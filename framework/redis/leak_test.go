@@ -0,0 +1,54 @@
+package redisutil
+
+import (
+	"fmt"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_EnableLeakDetection(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	warnings := make(chan string, 4)
+	logLeak = func(format string, args ...interface{}) {
+		warnings <- fmt.Sprintf(format, args...)
+	}
+	defer func() { logLeak = log.Printf }()
+
+	redisClient := GetRedisClient(server.URL())
+	redisClient.WithPrefix("app:").EnableLeakDetection(50 * time.Millisecond)
+	test.Equal(t, 50*time.Millisecond, redisClient.leakThreshold())
+
+	// a connection closed in time is not reported
+	conn := redisClient.GetConn()
+	_, err := conn.Do("PING")
+	test.Nil(t, err)
+	test.Nil(t, conn.Close())
+
+	leaked := redisClient.GetConn()
+	defer leaked.Close()
+	select {
+	case warning := <-warnings:
+		test.Contains(t, "redisutil: connection not closed 50ms after it was borrowed by:", warning)
+		test.Contains(t, "TestRedisClient_EnableLeakDetection", warning)
+	case <-time.After(time.Second):
+		t.Fatal("no warning for the leaked connection")
+	}
+	select {
+	case warning := <-warnings:
+		t.Fatalf("unexpected warning %s", warning)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	redisClient.EnableLeakDetection(0)
+	conn = redisClient.GetConn()
+	defer conn.Close()
+	_, ok := conn.(*leakConn)
+	test.Equal(t, false, ok)
+}
@@ -0,0 +1,129 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const (
+	lockMinBackoff = 10 * time.Millisecond
+	lockMaxBackoff = 500 * time.Millisecond
+)
+
+// releaseLockScript deletes KEYS[1] only if it holds the token ARGV[1]
+var releaseLockScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// extendLockScript sets the ttl of KEYS[1] to ARGV[2] milliseconds only if
+// it holds the token ARGV[1]
+var extendLockScript = redis.NewScript(1, `
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// ErrLockNotHeld is returned by Lock.Release if the lock expired or was
+// acquired by someone else
+var ErrLockNotHeld = errors.New("redisutil: lock is not held")
+
+// Lock is a lock acquired by TryLock
+type Lock struct {
+	rc    *RedisClient
+	key   string
+	token string
+}
+
+// TryLock tries once to acquire the lock at key for ttl with SET NX and a
+// random token, returns false if the lock is held by someone else.
+// use AcquireLock to wait for the lock
+func (rc *RedisClient) TryLock(key string, ttl time.Duration) (*Lock, bool, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := rc.tryLock(key, token, ttl)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+	return &Lock{rc: rc, key: key, token: token}, true, nil
+}
+
+// Release releases the lock only if it is still held with its token, so a
+// lock acquired by someone else after expiry is never deleted.
+// returns ErrLockNotHeld if the lock is not held anymore
+func (l *Lock) Release() error {
+	ok, err := l.rc.ReleaseLock(l.key, l.token)
+	if err == nil && !ok {
+		err = ErrLockNotHeld
+	}
+	return err
+}
+
+// Extend sets the ttl of the lock to ttl if it is still held,
+// returns false if the lock is not held anymore
+func (l *Lock) Extend(ttl time.Duration) (bool, error) {
+	conn := l.rc.getConn()
+	defer conn.Close()
+	val, err := redis.Bool(extendLockScript.Do(conn, l.key, l.token, int64(ttl/time.Millisecond)))
+	return val, err
+}
+
+// AcquireLock acquires the lock at key for ttl with SET NX, retrying with a
+// jittered exponential backoff until it succeeds or ctx is done, in which case
+// ctx.Err() is returned. the returned token is required by ReleaseLock
+func (rc *RedisClient) AcquireLock(ctx context.Context, key string, ttl time.Duration) (token string, err error) {
+	token, err = newToken()
+	if err != nil {
+		return "", err
+	}
+	backoff := lockMinBackoff
+	for {
+		ok, err := rc.tryLock(key, token, ttl)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return token, nil
+		}
+
+		// sleep a random duration in [backoff/2, backoff)
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)))
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(wait):
+		}
+		if backoff *= 2; backoff > lockMaxBackoff {
+			backoff = lockMaxBackoff
+		}
+	}
+}
+
+// ReleaseLock releases the lock at key if it is still held with token,
+// returns false if the lock expired or was acquired by someone else
+func (rc *RedisClient) ReleaseLock(key, token string) (bool, error) {
+	conn := rc.getConn()
+	defer conn.Close()
+	val, err := redis.Bool(releaseLockScript.Do(conn, key, token))
+	return val, err
+}
+
+// tryLock sets key to token for ttl if key does not exists
+func (rc *RedisClient) tryLock(key, token string, ttl time.Duration) (bool, error) {
+	conn := rc.getConn()
+	defer conn.Close()
+	reply, err := conn.Do("SET", key, token, "PX", int64(ttl/time.Millisecond), "NX")
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
@@ -0,0 +1,116 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_AcquireLock(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:lock"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	token, err := redisClient.AcquireLock(context.Background(), key, time.Minute)
+	test.Nil(t, err)
+
+	acquired := make(chan string)
+	go func() {
+		waiter, err := redisClient.AcquireLock(context.Background(), key, time.Minute)
+		test.Nil(t, err)
+		acquired <- waiter
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("lock acquired while held")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	ok, err := redisClient.ReleaseLock(key, "not-the-token")
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	ok, err = redisClient.ReleaseLock(key, token)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+
+	select {
+	case waiter := <-acquired:
+		test.NotEqual(t, token, waiter)
+		ok, err = redisClient.ReleaseLock(key, waiter)
+		test.Nil(t, err)
+		test.Equal(t, true, ok)
+	case <-time.After(2 * time.Second):
+		t.Fatal("waiter did not acquire the released lock")
+	}
+}
+
+func TestRedisClient_AcquireLock_Cancel(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:lock:cancel"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	_, err := redisClient.AcquireLock(context.Background(), key, time.Minute)
+	test.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = redisClient.AcquireLock(ctx, key, time.Minute)
+	test.Equal(t, context.Canceled, err)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancelled after %v", elapsed)
+	}
+}
+
+func TestRedisClient_TryLock(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:trylock"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	lock, ok, err := redisClient.TryLock(key, time.Minute)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	_, ok, err = redisClient.TryLock(key, time.Minute)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+
+	ok, err = lock.Extend(2 * time.Minute)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	if ttl, _ := redisClient.TTL(key); ttl <= 60 {
+		t.Errorf("lock not extended, ttl %d", ttl)
+	}
+	test.Nil(t, lock.Release())
+	test.Equal(t, ErrLockNotHeld, lock.Release())
+	ok, err = lock.Extend(time.Minute)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+}
+
+func TestRedisClient_TryLock_Expired(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:trylock:expired"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	expired, ok, err := redisClient.TryLock(key, 50*time.Millisecond)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	time.Sleep(150 * time.Millisecond)
+
+	lock, ok, err := redisClient.TryLock(key, time.Minute)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	// the expired lock must not release the lock acquired after it
+	test.Equal(t, ErrLockNotHeld, expired.Release())
+	exists, err := redisClient.Exists(key)
+	test.Nil(t, err)
+	test.Equal(t, true, exists)
+	test.Nil(t, lock.Release())
+}
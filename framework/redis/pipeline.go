@@ -0,0 +1,69 @@
+package redisutil
+
+import (
+	"context"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Pipeline buffers commands in memory and sends them on a single connection
+// in one flush, user is responsible for closing it
+type Pipeline struct {
+	conn     redis.Conn
+	commands []pipelineCommand
+}
+
+type pipelineCommand struct {
+	cmd  string
+	args []interface{}
+}
+
+// Pipeline returns a new Pipeline holding a connection from the pool
+func (rc *RedisClient) Pipeline() *Pipeline {
+	return rc.PipelineCtx(context.Background())
+}
+
+// PipelineCtx is like Pipeline but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PipelineCtx(ctx context.Context) *Pipeline {
+	return &Pipeline{conn: rc.getConnContext(ctx)}
+}
+
+// Send buffers the command cmd with args, the command is sent by Exec
+func (p *Pipeline) Send(cmd string, args ...interface{}) {
+	p.commands = append(p.commands, pipelineCommand{cmd: cmd, args: args})
+}
+
+// Exec sends the buffered commands and returns their replies in send order.
+// the reply of a command which failed is its redis.Error, the error is only
+// returned if the connection failed. the pipeline can be reused afterwards
+func (p *Pipeline) Exec() ([]interface{}, error) {
+	commands := p.commands
+	p.commands = nil
+	for _, c := range commands {
+		if err := p.conn.Send(c.cmd, c.args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.conn.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]interface{}, len(commands))
+	for i := range replies {
+		reply, err := p.conn.Receive()
+		if err != nil {
+			if _, ok := err.(redis.Error); !ok {
+				return replies[:i], err
+			}
+			reply = err
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// Close returns the connection to the pool, the commands buffered and not
+// executed are discarded without being sent
+func (p *Pipeline) Close() error {
+	p.commands = nil
+	return p.conn.Close()
+}
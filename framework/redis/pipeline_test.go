@@ -0,0 +1,64 @@
+package redisutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestPipeline(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:pipeline"
+	counter := "dotweb:test:pipeline:counter"
+	redisClient.Del(counter)
+	defer redisClient.Del(key)
+	defer redisClient.Del(counter)
+
+	pipeline := redisClient.Pipeline()
+	defer pipeline.Close()
+	pipeline.Send("SET", key, "dotweb")
+	pipeline.Send("INCR", counter)
+	pipeline.Send("INCR", key)
+	pipeline.Send("INCR", counter)
+	pipeline.Send("GET", key)
+	replies, err := pipeline.Exec()
+	test.Nil(t, err)
+	test.Equal(t, 5, len(replies))
+	test.Equal(t, "OK", replies[0])
+	test.Equal(t, int64(1), replies[1])
+	_, isErr := replies[2].(redis.Error)
+	test.Equal(t, true, isErr)
+	test.Equal(t, int64(2), replies[3])
+	test.Equal(t, []byte("dotweb"), replies[4])
+
+	// the pipeline can be reused
+	pipeline.Send("INCR", counter)
+	replies, err = pipeline.Exec()
+	test.Nil(t, err)
+	test.Equal(t, []interface{}{int64(3)}, replies)
+}
+
+func TestPipeline_CloseDiscards(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "OK"
+	})
+	defer server.Close()
+	redisClient := GetRedisClient(server.URL())
+
+	pipeline := redisClient.Pipeline()
+	pipeline.Send("SET", "key", "value")
+	pipeline.Send("INCR", "counter")
+	test.Nil(t, pipeline.Close())
+	// the connection is reused by the next command
+	_, err := redisClient.Ping()
+	test.Nil(t, err)
+	for _, args := range server.Commands() {
+		switch strings.ToUpper(args[0]) {
+		case "SET", "INCR":
+			t.Fatalf("%v sent after Close without Exec", args)
+		}
+	}
+	test.Equal(t, 1, server.Conns())
+}
@@ -0,0 +1,223 @@
+package redisutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// keyPositions are the positions of the key arguments of a command: every
+// step arguments from first, except the last skipLast ones, at most max keys
+// if max is not 0
+type keyPositions struct {
+	first    int
+	step     int
+	skipLast int
+	max      int
+}
+
+var (
+	firstKey    = keyPositions{step: 1, max: 1}
+	firstTwo    = keyPositions{step: 1, max: 2}
+	secondKey   = keyPositions{first: 1, step: 1, max: 1}
+	allKeys     = keyPositions{step: 1}
+	allButLast  = keyPositions{step: 1, skipLast: 1}
+	allButFirst = keyPositions{first: 1, step: 1}
+	pairKeys    = keyPositions{step: 2}
+)
+
+// commandKeys maps the commands to the positions of their key arguments,
+// the commands of commandTypes have a key as first argument too
+var commandKeys = map[string]keyPositions{
+	"DEL": allKeys, "UNLINK": allKeys, "EXISTS": allKeys, "TOUCH": allKeys, "MGET": allKeys, "WATCH": allKeys,
+	"RENAME": allKeys, "RENAMENX": allKeys, "RPOPLPUSH": allKeys, "PFCOUNT": allKeys, "PFMERGE": allKeys,
+	"SDIFF": allKeys, "SINTER": allKeys, "SUNION": allKeys, "SDIFFSTORE": allKeys, "SINTERSTORE": allKeys, "SUNIONSTORE": allKeys,
+	"BLPOP": allButLast, "BRPOP": allButLast, "BRPOPLPUSH": allButLast,
+	"SMOVE": firstTwo, "LMOVE": firstTwo, "COPY": firstTwo,
+	"MSET": pairKeys, "MSETNX": pairKeys,
+	"BITOP":  allButFirst,
+	"OBJECT": secondKey, "MEMORY": secondKey,
+	"TYPE": firstKey, "TTL": firstKey, "PTTL": firstKey, "EXPIRE": firstKey, "EXPIREAT": firstKey,
+	"PEXPIRE": firstKey, "PEXPIREAT": firstKey, "PERSIST": firstKey, "SET": firstKey, "SETEX": firstKey,
+	"PSETEX": firstKey, "SETNX": firstKey, "GETEX": firstKey, "DUMP": firstKey, "RESTORE": firstKey,
+	"PFADD": firstKey, "BITFIELD": firstKey, "MOVE": firstKey, "GEOADD": firstKey, "GEOPOS": firstKey,
+	"GEODIST": firstKey, "GEORADIUS": firstKey, "GEOHASH": firstKey, "GEOSEARCH": firstKey,
+	"XADD": firstKey, "XLEN": firstKey, "XRANGE": firstKey, "XREVRANGE": firstKey, "XTRIM": firstKey, "XDEL": firstKey,
+	"XACK": firstKey, "XPENDING": firstKey, "XCLAIM": firstKey, "XGROUP": secondKey,
+}
+
+// keyPositionsOf returns the positions of the key arguments of command,
+// false if it has no key argument
+func keyPositionsOf(command string) (keyPositions, bool) {
+	if pos, ok := commandKeys[command]; ok {
+		return pos, true
+	}
+	if _, ok := commandTypes[command]; ok {
+		return firstKey, true
+	}
+	return keyPositions{}, false
+}
+
+// WithPrefix returns a client sharing the connections of rc, which prepends
+// prefix to every key of its commands, like "app1:" to namespace the keys of
+// a service. the prefix is removed from the keys returned by Scan, KEYS, the
+// blocking pops and the stream reads. settings like Select, hooks or the
+// retry policy are shared with rc, closing the returned client does nothing
+func (rc *RedisClient) WithPrefix(prefix string) *RedisClient {
+	if rc.parent != nil {
+		return rc.parent.WithPrefix(rc.prefix + prefix)
+	}
+	return &RedisClient{pool: rc.pool, Address: rc.Address, parent: rc, prefix: prefix, db: -1}
+}
+
+// Prefix returns the prefix set by WithPrefix
+func (rc *RedisClient) Prefix() string {
+	return rc.prefix
+}
+
+// prefixedConn returns a connection of the client rc was created from by
+// WithPrefix, which prefixes the keys with the prefix of rc
+func (rc *RedisClient) prefixedConn(ctx context.Context) redis.Conn {
+	return &prefixConn{Conn: rc.parent.getConnContext(ctx), prefix: rc.prefix}
+}
+
+// prefixConn prepends prefix to the keys of the commands
+type prefixConn struct {
+	redis.Conn
+	prefix string
+}
+
+func (c *prefixConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		return c.Conn.Do(commandName, args...)
+	}
+	reply, err := c.Conn.Do(commandName, c.prefixArgs(commandName, args)...)
+	if err != nil {
+		return reply, err
+	}
+	return c.stripReply(commandName, reply), nil
+}
+
+func (c *prefixConn) Send(commandName string, args ...interface{}) error {
+	return c.Conn.Send(commandName, c.prefixArgs(commandName, args)...)
+}
+
+// prefixArgs returns a copy of args with the keys of the command prefixed
+func (c *prefixConn) prefixArgs(commandName string, args []interface{}) []interface{} {
+	commandName = strings.ToUpper(commandName)
+	prefixed := make([]interface{}, len(args))
+	copy(prefixed, args)
+	switch commandName {
+	case "EVAL", "EVALSHA":
+		if len(args) > 1 {
+			numKeys, _ := strconv.Atoi(fmt.Sprint(args[1]))
+			for i := 2; i < 2+numKeys && i < len(args); i++ {
+				prefixed[i] = c.key(args[i])
+			}
+		}
+		return prefixed
+	case "ZUNIONSTORE", "ZINTERSTORE":
+		if len(args) > 1 {
+			prefixed[0] = c.key(args[0])
+			numKeys, _ := strconv.Atoi(fmt.Sprint(args[1]))
+			for i := 2; i < 2+numKeys && i < len(args); i++ {
+				prefixed[i] = c.key(args[i])
+			}
+		}
+		return prefixed
+	case "XREAD", "XREADGROUP":
+		// the streams follow STREAMS, then their ids
+		for i := 0; i < len(args); i++ {
+			if strings.EqualFold(fmt.Sprint(args[i]), "STREAMS") {
+				streams := (len(args) - i - 1) / 2
+				for j := i + 1; j <= i+streams; j++ {
+					prefixed[j] = c.key(args[j])
+				}
+				break
+			}
+		}
+		return prefixed
+	case "KEYS":
+		if len(args) > 0 {
+			prefixed[0] = c.key(args[0])
+		}
+		return prefixed
+	case "SCAN":
+		for i := 1; i < len(args)-1; i++ {
+			if strings.EqualFold(fmt.Sprint(args[i]), "MATCH") {
+				prefixed[i+1] = c.key(args[i+1])
+				return prefixed
+			}
+		}
+		return append(prefixed, "MATCH", c.prefix+"*")
+	}
+	pos, ok := keyPositionsOf(commandName)
+	if !ok {
+		return prefixed
+	}
+	for i, n := pos.first, 0; i < len(args)-pos.skipLast && (pos.max == 0 || n < pos.max); i, n = i+pos.step, n+1 {
+		prefixed[i] = c.key(args[i])
+	}
+	return prefixed
+}
+
+// key returns key with the prefix prepended
+func (c *prefixConn) key(key interface{}) interface{} {
+	switch k := key.(type) {
+	case string:
+		return c.prefix + k
+	case []byte:
+		return append([]byte(c.prefix), k...)
+	}
+	return c.prefix + fmt.Sprint(key)
+}
+
+// stripReply removes the prefix from the keys of the reply of the command
+func (c *prefixConn) stripReply(commandName string, reply interface{}) interface{} {
+	switch strings.ToUpper(commandName) {
+	case "SCAN":
+		if values, ok := reply.([]interface{}); ok && len(values) == 2 {
+			return []interface{}{values[0], c.stripKeys(values[1])}
+		}
+	case "KEYS":
+		return c.stripKeys(reply)
+	case "XREAD", "XREADGROUP":
+		if streams, ok := reply.([]interface{}); ok {
+			stripped := make([]interface{}, len(streams))
+			for i, stream := range streams {
+				stripped[i] = stream
+				if values, ok := stream.([]interface{}); ok && len(values) == 2 {
+					stripped[i] = []interface{}{c.stripKey(values[0]), values[1]}
+				}
+			}
+			return stripped
+		}
+	case "BLPOP", "BRPOP":
+		if values, ok := reply.([]interface{}); ok && len(values) == 2 {
+			return []interface{}{c.stripKey(values[0]), values[1]}
+		}
+	}
+	return reply
+}
+
+func (c *prefixConn) stripKeys(reply interface{}) interface{} {
+	keys, ok := reply.([]interface{})
+	if !ok {
+		return reply
+	}
+	stripped := make([]interface{}, len(keys))
+	for i, key := range keys {
+		stripped[i] = c.stripKey(key)
+	}
+	return stripped
+}
+
+func (c *prefixConn) stripKey(key interface{}) interface{} {
+	if k, ok := key.([]byte); ok && strings.HasPrefix(string(k), c.prefix) {
+		return k[len(c.prefix):]
+	}
+	return key
+}
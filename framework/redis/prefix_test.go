@@ -0,0 +1,107 @@
+package redisutil
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_WithPrefix(t *testing.T) {
+	redisClient := newTestClient(t)
+	prefixed := redisClient.WithPrefix("dotweb:test:prefix:")
+	test.Equal(t, "dotweb:test:prefix:", prefixed.Prefix())
+	for _, key := range []string{"a", "b", "s1", "s2", "nested:c"} {
+		defer prefixed.Del(key)
+	}
+
+	_, err := prefixed.Set("a", "1")
+	test.Nil(t, err)
+	val, err := redisClient.Get("dotweb:test:prefix:a")
+	test.Nil(t, err)
+	test.Equal(t, "1", val)
+	_, err = redisClient.Get("a")
+	test.Equal(t, ErrNil, err)
+
+	// multi-key commands prefix every key
+	test.Nil(t, prefixed.MSet(map[string]interface{}{"b": "2"}))
+	vals, err := prefixed.MGet("a", "b")
+	test.Nil(t, err)
+	test.Equal(t, []string{"1", "2"}, vals)
+	_, err = prefixed.SAdd("s1", "x", "y")
+	test.Nil(t, err)
+	_, err = prefixed.SAdd("s2", "y", "z")
+	test.Nil(t, err)
+	members, err := prefixed.SInter("s1", "s2")
+	test.Nil(t, err)
+	test.Equal(t, []string{"y"}, members)
+
+	// Scan only returns the keys of the prefix, without it
+	keys, err := prefixed.Scan("", 100)
+	test.Nil(t, err)
+	sort.Strings(keys)
+	test.Equal(t, []string{"a", "b", "s1", "s2"}, keys)
+	keys, err = prefixed.Scan("s*", 100)
+	test.Nil(t, err)
+	sort.Strings(keys)
+	test.Equal(t, []string{"s1", "s2"}, keys)
+
+	// prefixes are nested
+	nested := prefixed.WithPrefix("nested:")
+	test.Equal(t, "dotweb:test:prefix:nested:", nested.Prefix())
+	_, err = nested.Set("c", "3")
+	test.Nil(t, err)
+	val, err = prefixed.Get("nested:c")
+	test.Nil(t, err)
+	test.Equal(t, "3", val)
+
+	// stream names are prefixed and stripped
+	id, err := prefixed.XAdd("stream", "*", map[string]interface{}{"field": "value"})
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	defer prefixed.Del("stream")
+	streams, err := prefixed.XRead(map[string]string{"stream": "0"}, 0, 0)
+	test.Nil(t, err)
+	test.Equal(t, map[string][]StreamMessage{"stream": {{ID: id, Fields: map[string]string{"field": "value"}}}}, streams)
+}
+
+func TestRedisClient_WithPrefixSharedPool(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "OK"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	prefixed := redisClient.WithPrefix("app:")
+	test.Equal(t, redisClient.pool, prefixed.pool)
+	_, err := prefixed.Set("key", "value")
+	test.Nil(t, err)
+	_, err = redisClient.Set("key", "value")
+	test.Nil(t, err)
+	test.Equal(t, 1, server.Conns())
+	test.Nil(t, prefixed.Close())
+	test.Equal(t, 1, redisClient.pool.Stats().IdleCount)
+
+	conn := prefixed.GetConn()
+	defer conn.Close()
+	for _, args := range [][]interface{}{
+		{"BLPOP", "a", "b", 1},
+		{"SMOVE", "a", "b", "member"},
+		{"MSET", "a", "1", "b", "2"},
+		{"BITOP", "AND", "dest", "a"},
+		{"EVAL", "return 1", 1, "a", "arg"},
+		{"PUBLISH", "channel", "message"},
+		{"XREAD", "COUNT", 1, "STREAMS", "a", "b", "0", "$"},
+	} {
+		_, err := conn.Do(args[0].(string), args[1:]...)
+		test.Nil(t, err)
+	}
+	commands := server.Commands()
+	test.Equal(t, []string{"BLPOP", "app:a", "app:b", "1"}, commands[2])
+	test.Equal(t, []string{"SMOVE", "app:a", "app:b", "member"}, commands[3])
+	test.Equal(t, []string{"MSET", "app:a", "1", "app:b", "2"}, commands[4])
+	test.Equal(t, []string{"BITOP", "AND", "app:dest", "app:a"}, commands[5])
+	test.Equal(t, []string{"EVAL", "return 1", "1", "app:a", "arg"}, commands[6])
+	test.Equal(t, []string{"PUBLISH", "channel", "message"}, commands[7])
+	test.Equal(t, []string{"XREAD", "COUNT", "1", "STREAMS", "app:a", "app:b", "0", "$"}, commands[8])
+}
@@ -0,0 +1,271 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// ErrSubscriptionClosed is returned by Receive after the subscription is closed
+var ErrSubscriptionClosed = errors.New("redisutil: subscription closed")
+
+// Message is a message published to a subscribed channel
+type Message struct {
+	Channel string
+	Pattern string // the matching pattern for a message of PSubscribe
+	Payload string
+}
+
+// Publish publishes message to channel, returns count of receivers
+func (rc *RedisClient) Publish(channel string, message interface{}) (int64, error) {
+	return rc.PublishCtx(context.Background(), channel, message)
+}
+
+// PublishCtx is like Publish but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PublishCtx(ctx context.Context, channel string, message interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("PUBLISH", channel, message))
+	return val, err
+}
+
+// Subscription holds a connection subscribed to one or more channels.
+// messages are read from the connection in background and returned by Receive,
+// user is responsible for closing the subscription
+type Subscription struct {
+	conn      redis.PubSubConn
+	messages  chan Message
+	done      chan struct{}
+	readDone  chan struct{}
+	closeOnce sync.Once
+	confirms  chan redis.Subscription // confirmations of Subscribe and PSubscribe
+	subMu     sync.Mutex              // serializes Subscribe and PSubscribe
+
+	mu            sync.Mutex
+	cond          *sync.Cond
+	pending       []Message
+	paused        bool
+	dropWhenPause bool
+	closed        bool
+	err           error
+	count         int
+}
+
+// Subscribe subscribes to the specified channels on a dedicated connection
+func (rc *RedisClient) Subscribe(channels ...string) (*Subscription, error) {
+	return rc.newSubscription((*Subscription).Subscribe, channels)
+}
+
+// PSubscribe subscribes to the channels matching the specified patterns on a
+// dedicated connection
+func (rc *RedisClient) PSubscribe(patterns ...string) (*Subscription, error) {
+	return rc.newSubscription((*Subscription).PSubscribe, patterns)
+}
+
+func (rc *RedisClient) newSubscription(subscribe func(*Subscription, ...string) error, names []string) (*Subscription, error) {
+	s := &Subscription{
+		conn:     redis.PubSubConn{Conn: rc.getConn()},
+		messages: make(chan Message),
+		done:     make(chan struct{}),
+		readDone: make(chan struct{}),
+		confirms: make(chan redis.Subscription, 16),
+	}
+	s.cond = sync.NewCond(&s.mu)
+	go s.readLoop()
+	go s.deliverLoop()
+	if err := subscribe(s, names...); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Subscribe adds the specified channels to the subscription, it returns once
+// the server confirmed them, so no message published afterwards can be missed
+func (s *Subscription) Subscribe(channels ...string) error {
+	return s.subscribe(s.conn.Subscribe, channels)
+}
+
+// PSubscribe adds the specified patterns to the subscription, it returns once
+// the server confirmed them, so no message published afterwards can be missed
+func (s *Subscription) PSubscribe(patterns ...string) error {
+	return s.subscribe(s.conn.PSubscribe, patterns)
+}
+
+func (s *Subscription) subscribe(send func(...interface{}) error, names []string) error {
+	s.subMu.Lock()
+	defer s.subMu.Unlock()
+	if err := send(redis.Args{}.AddFlat(names)...); err != nil {
+		return err
+	}
+	for range names {
+		select {
+		case <-s.confirms:
+		case <-s.done:
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			return s.err
+		}
+	}
+	return nil
+}
+
+// Count returns count of channels and patterns subscribed, as confirmed by the server
+func (s *Subscription) Count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Receive blocks until a message arrives and returns its channel and payload,
+// the confirmations of the subscriptions are not returned, see Count
+func (s *Subscription) Receive() (channel string, payload string, err error) {
+	msg, err := s.ReceiveMessage()
+	return msg.Channel, msg.Payload, err
+}
+
+// ReceiveMessage is like Receive but returns the message with the matching pattern
+func (s *Subscription) ReceiveMessage() (Message, error) {
+	msg, ok := <-s.messages
+	if !ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return Message{}, s.err
+	}
+	return msg, nil
+}
+
+// Pause stops delivering messages to Receive, the connection stays subscribed.
+// messages received while paused are buffered until Resume, or dropped if
+// SetDropWhenPaused(true) was called
+func (s *Subscription) Pause() {
+	s.mu.Lock()
+	s.paused = true
+	s.mu.Unlock()
+}
+
+// Resume delivers the buffered messages and continues the normal delivery
+func (s *Subscription) Resume() {
+	s.mu.Lock()
+	s.paused = false
+	s.cond.Broadcast()
+	s.mu.Unlock()
+}
+
+// IsPaused returns whether the subscription is paused
+func (s *Subscription) IsPaused() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.paused
+}
+
+// SetDropWhenPaused sets whether messages received while paused are dropped
+// instead of buffered, defaults to false
+func (s *Subscription) SetDropWhenPaused(drop bool) {
+	s.mu.Lock()
+	s.dropWhenPause = drop
+	s.mu.Unlock()
+}
+
+// Close unsubscribes all channels and returns the connection to the pool
+func (s *Subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		if !s.closed {
+			s.closed = true
+			s.err = ErrSubscriptionClosed
+			close(s.done)
+		}
+		s.cond.Broadcast()
+		s.mu.Unlock()
+
+		// the read loop stops once the server confirms there is no subscription
+		// left, the connection must not be read concurrently when closing it
+		err = s.conn.Unsubscribe()
+		if perr := s.conn.PUnsubscribe(); err == nil {
+			err = perr
+		}
+		<-s.readDone
+		s.conn.Close()
+	})
+	return err
+}
+
+// readLoop reads the connection and queues the received messages
+func (s *Subscription) readLoop() {
+	defer close(s.readDone)
+	for {
+		switch v := s.conn.Receive().(type) {
+		case redis.Subscription:
+			s.mu.Lock()
+			s.count = v.Count
+			s.mu.Unlock()
+			if v.Count == 0 && s.isClosed() {
+				return
+			}
+			if v.Kind == "subscribe" || v.Kind == "psubscribe" {
+				select {
+				case s.confirms <- v:
+				case <-s.done:
+				}
+			}
+		case redis.Message:
+			s.queue(Message{Channel: v.Channel, Payload: string(v.Data)})
+		case redis.PMessage:
+			s.queue(Message{Channel: v.Channel, Pattern: v.Pattern, Payload: string(v.Data)})
+		case error:
+			s.mu.Lock()
+			if !s.closed {
+				s.closed = true
+				s.err = v
+				close(s.done)
+			}
+			s.cond.Broadcast()
+			s.mu.Unlock()
+			return
+		}
+	}
+}
+
+// queue queues msg for delivery unless paused and dropping
+func (s *Subscription) queue(msg Message) {
+	s.mu.Lock()
+	if !s.paused || !s.dropWhenPause {
+		s.pending = append(s.pending, msg)
+		s.cond.Signal()
+	}
+	s.mu.Unlock()
+}
+
+func (s *Subscription) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+// deliverLoop passes the queued messages to Receive unless paused
+func (s *Subscription) deliverLoop() {
+	defer close(s.messages)
+	for {
+		s.mu.Lock()
+		for !s.closed && (s.paused || len(s.pending) == 0) {
+			s.cond.Wait()
+		}
+		if s.closed {
+			s.mu.Unlock()
+			return
+		}
+		msg := s.pending[0]
+		s.pending = s.pending[1:]
+		s.mu.Unlock()
+
+		select {
+		case s.messages <- msg:
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func publish(t *testing.T, redisClient *RedisClient, channel string, messages ...string) {
+	conn := redisClient.GetConn()
+	defer conn.Close()
+	for _, message := range messages {
+		_, err := conn.Do("PUBLISH", channel, message)
+		test.Nil(t, err)
+	}
+}
+
+func receiveAsync(s *Subscription) <-chan string {
+	payloads := make(chan string, 16)
+	go func() {
+		for {
+			_, payload, err := s.Receive()
+			if err != nil {
+				close(payloads)
+				return
+			}
+			payloads <- payload
+		}
+	}()
+	return payloads
+}
+
+func TestSubscription_PauseResume(t *testing.T) {
+	redisClient := newTestClient(t)
+	channel := "dotweb:test:pause"
+	s, err := redisClient.Subscribe(channel)
+	test.Nil(t, err)
+	defer s.Close()
+	payloads := receiveAsync(s)
+
+	s.Pause()
+	test.Equal(t, true, s.IsPaused())
+	publish(t, redisClient, channel, "1", "2", "3")
+	select {
+	case payload := <-payloads:
+		t.Fatalf("message %s delivered while paused", payload)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	s.Resume()
+	test.Equal(t, false, s.IsPaused())
+	for _, expected := range []string{"1", "2", "3"} {
+		select {
+		case payload := <-payloads:
+			test.Equal(t, expected, payload)
+		case <-time.After(time.Second):
+			t.Fatalf("message %s not delivered after resume", expected)
+		}
+	}
+}
+
+func TestSubscription_PauseDrop(t *testing.T) {
+	redisClient := newTestClient(t)
+	channel := "dotweb:test:pausedrop"
+	s, err := redisClient.Subscribe(channel)
+	test.Nil(t, err)
+	defer s.Close()
+	payloads := receiveAsync(s)
+
+	s.SetDropWhenPaused(true)
+	s.Pause()
+	publish(t, redisClient, channel, "dropped")
+	time.Sleep(200 * time.Millisecond)
+	s.Resume()
+	publish(t, redisClient, channel, "kept")
+
+	select {
+	case payload := <-payloads:
+		test.Equal(t, "kept", payload)
+	case <-time.After(time.Second):
+		t.Fatal("message not delivered after resume")
+	}
+}
+
+func TestSubscription_Close(t *testing.T) {
+	redisClient := newTestClient(t)
+	s, err := redisClient.Subscribe("dotweb:test:close")
+	test.Nil(t, err)
+	test.Nil(t, s.Close())
+	_, _, err = s.Receive()
+	test.Equal(t, ErrSubscriptionClosed, err)
+}
+
+func TestRedisClient_PSubscribe(t *testing.T) {
+	redisClient := newTestClient(t)
+	publisher := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxActive: 1})
+	s, err := redisClient.PSubscribe("dotweb:test:psub:*")
+	test.Nil(t, err)
+	defer s.Close()
+	test.Equal(t, 1, s.Count())
+
+	receivers, err := publisher.Publish("dotweb:test:psub:news", "hello")
+	test.Nil(t, err)
+	test.Equal(t, int64(1), receivers)
+	msg, err := s.ReceiveMessage()
+	test.Nil(t, err)
+	test.Equal(t, Message{Channel: "dotweb:test:psub:news", Pattern: "dotweb:test:psub:*", Payload: "hello"}, msg)
+
+	// channels can be added to a pattern subscription
+	test.Nil(t, s.Subscribe("dotweb:test:plain"))
+	test.Equal(t, 2, s.Count())
+	receivers, err = publisher.Publish("dotweb:test:plain", 42)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), receivers)
+	channel, payload, err := s.Receive()
+	test.Nil(t, err)
+	test.Equal(t, "dotweb:test:plain", channel)
+	test.Equal(t, "42", payload)
+
+	test.Nil(t, s.Close())
+	_, _, err = s.Receive()
+	test.Equal(t, ErrSubscriptionClosed, err)
+	receivers, err = publisher.Publish("dotweb:test:plain", "nobody")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), receivers)
+}
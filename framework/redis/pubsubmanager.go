@@ -0,0 +1,317 @@
+package redisutil
+
+import (
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// pubSubBufferSize is the buffer of the channels returned by
+// PubSubManager.Subscribe
+const pubSubBufferSize = 64
+
+// PubSubManager multiplexes subscriptions of many consumers over a single
+// connection. a channel is subscribed on the server when its first consumer
+// subscribes and unsubscribed when its last consumer leaves.
+// a consumer which does not keep up, so its buffer is full when a message
+// arrives, is evicted and its channel closed, so it cannot stall the others.
+// if the connection breaks, the channels of all consumers are closed and
+// Err returns the error
+type PubSubManager struct {
+	conn      redis.PubSubConn
+	readDone  chan struct{}
+	closeOnce sync.Once
+	subMu     sync.Mutex // serializes the commands sent on conn
+
+	mu      sync.Mutex
+	subs    map[string][]*pubSubConsumer
+	pending map[string][]chan struct{} // closed in order by the SUBSCRIBE confirmations
+	closing bool
+	err     error // error which broke the connection
+}
+
+type pubSubConsumer struct {
+	ch   chan Message
+	done chan struct{}
+	mu   sync.Mutex // held while delivering, ch is closed under it
+}
+
+// NewPubSubManager returns a PubSubManager using a dedicated connection,
+// user is responsible for closing it
+func (rc *RedisClient) NewPubSubManager() *PubSubManager {
+	m := &PubSubManager{
+		conn:     redis.PubSubConn{Conn: rc.getConn()},
+		readDone: make(chan struct{}),
+		subs:     make(map[string][]*pubSubConsumer),
+		pending:  make(map[string][]chan struct{}),
+	}
+	go m.readLoop()
+	return m
+}
+
+// Subscribe returns a channel receiving the messages published to channel,
+// it is closed by Unsubscribe, Close or a failure of the connection.
+// it returns once the server confirmed the subscription, so no message
+// published afterwards can be missed
+func (m *PubSubManager) Subscribe(channel string) (<-chan Message, error) {
+	m.subMu.Lock()
+	m.mu.Lock()
+	if m.closing {
+		err := m.closedErr()
+		m.mu.Unlock()
+		m.subMu.Unlock()
+		return nil, err
+	}
+	subscribe := len(m.subs[channel]) == 0
+	consumer := &pubSubConsumer{
+		ch:   make(chan Message, pubSubBufferSize),
+		done: make(chan struct{}),
+	}
+	m.subs[channel] = append(m.subs[channel], consumer)
+	if subscribe {
+		m.pending[channel] = append(m.pending[channel], make(chan struct{}))
+	}
+	// waits for the last SUBSCRIBE of channel, which may be sent by
+	// another consumer and not confirmed yet
+	var confirmed chan struct{}
+	if pending := m.pending[channel]; len(pending) > 0 {
+		confirmed = pending[len(pending)-1]
+	}
+	m.mu.Unlock()
+
+	// the consumers are closed by the read loop or Close if the
+	// subscription fails
+	var err error
+	if subscribe {
+		err = m.conn.Subscribe(channel)
+	}
+	m.subMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	if confirmed == nil {
+		return consumer.ch, nil
+	}
+	select {
+	case <-confirmed:
+		return consumer.ch, nil
+	case <-m.readDone:
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return nil, m.closedErr()
+	}
+}
+
+// Err returns the error which broke the connection, nil if it is not broken
+func (m *PubSubManager) Err() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// closedErr returns the error of the commands of a closed manager, m.mu is held
+func (m *PubSubManager) closedErr() error {
+	if m.err != nil {
+		return m.err
+	}
+	return ErrSubscriptionClosed
+}
+
+// Unsubscribe removes the consumer ch returned by Subscribe from channel and
+// closes ch, the channel is unsubscribed if ch was its last consumer
+func (m *PubSubManager) Unsubscribe(channel string, ch <-chan Message) error {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.mu.Lock()
+	var consumer *pubSubConsumer
+	consumers := m.subs[channel]
+	for i, c := range consumers {
+		if c.ch == ch {
+			consumer = c
+			consumers = append(consumers[:i:i], consumers[i+1:]...)
+			break
+		}
+	}
+	if consumer == nil {
+		m.mu.Unlock()
+		return nil
+	}
+	var err error
+	if len(consumers) == 0 {
+		delete(m.subs, channel)
+		if !m.closing {
+			err = m.conn.Unsubscribe(channel)
+		}
+	} else {
+		m.subs[channel] = consumers
+	}
+	m.mu.Unlock()
+
+	consumer.close()
+	return err
+}
+
+// Close unsubscribes all channels, closes the channels of all consumers and
+// returns the connection to the pool
+func (m *PubSubManager) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		m.subMu.Lock()
+		m.mu.Lock()
+		broken := m.closing // the read loop stopped on an error
+		m.closing = true
+		subs := m.subs
+		m.subs = make(map[string][]*pubSubConsumer)
+		m.mu.Unlock()
+		for _, consumers := range subs {
+			for _, consumer := range consumers {
+				consumer.close()
+			}
+		}
+
+		// the server always confirms UNSUBSCRIBE, even with no subscription,
+		// which stops the read loop
+		if !broken {
+			err = m.conn.Unsubscribe()
+		}
+		m.subMu.Unlock()
+		<-m.readDone
+		m.conn.Close()
+	})
+	return err
+}
+
+func (m *PubSubManager) readLoop() {
+	defer close(m.readDone)
+	for {
+		switch v := m.conn.Receive().(type) {
+		case redis.Subscription:
+			if v.Count == 0 && m.isClosing() {
+				return
+			}
+			if v.Kind == "subscribe" {
+				m.confirm(v.Channel)
+			}
+		case redis.Message:
+			m.mu.Lock()
+			consumers := append([]*pubSubConsumer(nil), m.subs[v.Channel]...)
+			m.mu.Unlock()
+			msg := Message{Channel: v.Channel, Payload: string(v.Data)}
+			for _, consumer := range consumers {
+				if !consumer.deliver(msg) {
+					m.evict(v.Channel, consumer)
+				}
+			}
+		case error:
+			m.fail(v)
+			return
+		}
+	}
+}
+
+// confirm releases the Subscribe waiting for the oldest SUBSCRIBE of channel
+func (m *PubSubManager) confirm(channel string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := m.pending[channel]
+	if len(pending) == 0 {
+		return
+	}
+	close(pending[0])
+	if len(pending) == 1 {
+		delete(m.pending, channel)
+	} else {
+		m.pending[channel] = pending[1:]
+	}
+}
+
+// evict removes the consumer whose buffer is full from channel and closes it,
+// the channel is unsubscribed in background if it was its last consumer
+func (m *PubSubManager) evict(channel string, consumer *pubSubConsumer) {
+	m.mu.Lock()
+	found := false
+	consumers := m.subs[channel]
+	for i, c := range consumers {
+		if c == consumer {
+			found = true
+			consumers = append(consumers[:i:i], consumers[i+1:]...)
+			break
+		}
+	}
+	if !found {
+		// already removed by Unsubscribe or Close
+		m.mu.Unlock()
+		return
+	}
+	if len(consumers) == 0 {
+		delete(m.subs, channel)
+		// the read loop must not wait for subMu, which Subscribe holds
+		go m.unsubscribeUnused(channel)
+	} else {
+		m.subs[channel] = consumers
+	}
+	m.mu.Unlock()
+	consumer.close()
+}
+
+// unsubscribeUnused unsubscribes channel unless a consumer subscribed it again
+func (m *PubSubManager) unsubscribeUnused(channel string) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.mu.Lock()
+	unused := !m.closing && len(m.subs[channel]) == 0
+	m.mu.Unlock()
+	if unused {
+		m.conn.Unsubscribe(channel)
+	}
+}
+
+// fail records err and closes the channels of all consumers, unless the
+// connection failed because the manager is closed
+func (m *PubSubManager) fail(err error) {
+	m.mu.Lock()
+	if m.closing {
+		m.mu.Unlock()
+		return
+	}
+	m.closing = true
+	m.err = err
+	subs := m.subs
+	m.subs = make(map[string][]*pubSubConsumer)
+	m.mu.Unlock()
+	for _, consumers := range subs {
+		for _, consumer := range consumers {
+			consumer.close()
+		}
+	}
+}
+
+func (m *PubSubManager) isClosing() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.closing
+}
+
+// deliver queues msg without blocking, returns false if the buffer is full
+func (c *pubSubConsumer) deliver(msg Message) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	select {
+	case <-c.done:
+		return true
+	default:
+	}
+	select {
+	case c.ch <- msg:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *pubSubConsumer) close() {
+	close(c.done)
+	c.mu.Lock()
+	close(c.ch)
+	c.mu.Unlock()
+}
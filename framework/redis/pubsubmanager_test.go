@@ -0,0 +1,154 @@
+package redisutil
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+func numSub(t *testing.T, redisClient *RedisClient, channel string) int64 {
+	conn := redisClient.GetConn()
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("PUBSUB", "NUMSUB", channel))
+	test.Nil(t, err)
+	count, err := redis.Int64(values[1], nil)
+	test.Nil(t, err)
+	return count
+}
+
+func receiveMessage(t *testing.T, ch <-chan Message) Message {
+	select {
+	case msg := <-ch:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("message not received")
+	}
+	return Message{}
+}
+
+func TestPubSubManager(t *testing.T) {
+	redisClient := newTestClient(t)
+	channel := "dotweb:test:manager"
+	manager := redisClient.NewPubSubManager()
+	defer manager.Close()
+
+	first, err := manager.Subscribe(channel)
+	test.Nil(t, err)
+	second, err := manager.Subscribe(channel)
+	test.Nil(t, err)
+	other, err := manager.Subscribe(channel + ":other")
+	test.Nil(t, err)
+
+	// both consumers share the same connection
+	test.Equal(t, int64(1), numSub(t, redisClient, channel))
+
+	publish(t, redisClient, channel, "hello")
+	test.Equal(t, Message{Channel: channel, Payload: "hello"}, receiveMessage(t, first))
+	test.Equal(t, Message{Channel: channel, Payload: "hello"}, receiveMessage(t, second))
+	select {
+	case msg := <-other:
+		t.Fatalf("unexpected message %v", msg)
+	default:
+	}
+
+	// the channel stays subscribed until its last consumer leaves
+	test.Nil(t, manager.Unsubscribe(channel, first))
+	_, ok := <-first
+	test.Equal(t, false, ok)
+	time.Sleep(50 * time.Millisecond)
+	test.Equal(t, int64(1), numSub(t, redisClient, channel))
+	publish(t, redisClient, channel, "again")
+	test.Equal(t, "again", receiveMessage(t, second).Payload)
+
+	test.Nil(t, manager.Unsubscribe(channel, second))
+	time.Sleep(50 * time.Millisecond)
+	test.Equal(t, int64(0), numSub(t, redisClient, channel))
+
+	test.Nil(t, manager.Close())
+	_, ok = <-other
+	test.Equal(t, false, ok)
+	_, err = manager.Subscribe(channel)
+	test.Equal(t, ErrSubscriptionClosed, err)
+}
+
+func TestPubSubManager_SubscribeConfirmed(t *testing.T) {
+	redisClient := newTestClient(t)
+	channel := "dotweb:test:manager:confirmed"
+	manager := redisClient.NewPubSubManager()
+	defer manager.Close()
+
+	// a message published right after Subscribe returns is received
+	for i := 0; i < 10; i++ {
+		ch, err := manager.Subscribe(channel)
+		test.Nil(t, err)
+		publish(t, redisClient, channel, "hello")
+		test.Equal(t, "hello", receiveMessage(t, ch).Payload)
+		test.Nil(t, manager.Unsubscribe(channel, ch))
+	}
+}
+
+func TestPubSubManager_ConnectionBroken(t *testing.T) {
+	u, err := url.Parse(redisServerURL)
+	test.Nil(t, err)
+	proxy := newTCPProxy(t, u.Host)
+	defer proxy.Close()
+	u.Host = proxy.ln.Addr().String()
+	manager := GetRedisClient(u.String()).NewPubSubManager()
+	defer manager.Close()
+
+	ch, err := manager.Subscribe("dotweb:test:manager:broken")
+	test.Nil(t, err)
+	test.Nil(t, manager.Err())
+	proxy.Break()
+	select {
+	case _, ok := <-ch:
+		test.Equal(t, false, ok)
+	case <-time.After(time.Second):
+		t.Fatal("consumer not closed after the connection broke")
+	}
+	test.NotNil(t, manager.Err())
+	_, err = manager.Subscribe("dotweb:test:manager:broken")
+	test.Equal(t, manager.Err(), err)
+	test.Nil(t, manager.Close())
+}
+
+func TestPubSubManager_SlowConsumer(t *testing.T) {
+	redisClient := newTestClient(t)
+	channel := "dotweb:test:manager:slow"
+	manager := redisClient.NewPubSubManager()
+
+	slow, err := manager.Subscribe(channel)
+	test.Nil(t, err)
+	fast, err := manager.Subscribe(channel)
+	test.Nil(t, err)
+
+	// slow never receives, fast still gets every message
+	count := pubSubBufferSize + 10
+	for i := 0; i < count; i++ {
+		publish(t, redisClient, channel, "hello")
+	}
+	for i := 0; i < count; i++ {
+		receiveMessage(t, fast)
+	}
+
+	// slow is evicted once its buffer is full
+	received := 0
+	for range slow {
+		received++
+	}
+	test.Equal(t, pubSubBufferSize, received)
+
+	closed := make(chan error)
+	go func() { closed <- manager.Close() }()
+	select {
+	case err := <-closed:
+		test.Nil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return")
+	}
+	_, ok := <-fast
+	test.Equal(t, false, ok)
+}
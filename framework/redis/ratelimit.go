@@ -0,0 +1,52 @@
+package redisutil
+
+import (
+	"errors"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// rateLimitScript increments the counter KEYS[1] and sets its expire on the
+// first hit of the window, or if a counter was left without expire, so the
+// counter cannot outlive its window. returns the count in the window.
+// ARGV: window in milliseconds
+var rateLimitScript = redis.NewScript(1, `
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return count
+`)
+
+// RateLimiter limits the count of hits per key in fixed time windows,
+// the counters are stored in redis so the limit is shared by all processes
+type RateLimiter struct {
+	rc *RedisClient
+}
+
+// RateLimiter returns a RateLimiter storing its counters in rc
+func (rc *RedisClient) RateLimiter() *RateLimiter {
+	return &RateLimiter{rc: rc}
+}
+
+// Allow records a hit of key and returns whether it is within limit hits per
+// window, and the count of hits remaining in the current window. the window
+// starts at the first hit, the counter is incremented and its expire is set
+// atomically
+func (l *RateLimiter) Allow(key string, limit int, window time.Duration) (allowed bool, remaining int, err error) {
+	if window < time.Millisecond {
+		return false, 0, errors.New("redisutil: RateLimiter window must be at least 1ms")
+	}
+	conn := l.rc.getConn()
+	defer conn.Close()
+	count, err := redis.Int(rateLimitScript.Do(conn, key, int64(window/time.Millisecond)))
+	if err != nil {
+		return false, 0, err
+	}
+	remaining = limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return count <= limit, remaining, nil
+}
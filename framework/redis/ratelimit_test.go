@@ -0,0 +1,46 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:ratelimit"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	limiter := redisClient.RateLimiter()
+	window := 500 * time.Millisecond
+	denied := 0
+	for i := 0; i < 4; i++ {
+		allowed, remaining, err := limiter.Allow(key, 3, window)
+		test.Nil(t, err)
+		if allowed {
+			test.Equal(t, 2-i, remaining)
+		} else {
+			denied++
+			test.Equal(t, 0, remaining)
+		}
+	}
+	test.Equal(t, 1, denied)
+
+	pttl, err := redisClient.PTTL(key)
+	test.Nil(t, err)
+	if pttl <= 0 || pttl > 500 {
+		t.Errorf("unexpected pttl %d", pttl)
+	}
+
+	// the counter is reset after the window
+	time.Sleep(window + 100*time.Millisecond)
+	allowed, remaining, err := limiter.Allow(key, 3, window)
+	test.Nil(t, err)
+	test.Equal(t, true, allowed)
+	test.Equal(t, 2, remaining)
+
+	_, _, err = limiter.Allow(key, 3, 0)
+	test.NotNil(t, err)
+}
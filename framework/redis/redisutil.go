@@ -5,14 +5,46 @@
 package redisutil
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/garyburd/redigo/redis"
 )
 
 type RedisClient struct {
+	waitCount int64 // gets which waited for a connection, first for the alignment of atomic
+	waitNanos int64 // total duration of the waits
+	leakNanos int64 // threshold of the leak detection, see EnableLeakDetection
+
 	pool    *redis.Pool
 	Address string
+
+	hookMutex sync.RWMutex
+	dialHooks []func(conn redis.Conn) error // run on each new connection
+	cmdHooks  []Hook                        // called around each command, see AddHook
+	logHook   Hook                          // called after the hooks, see SetLogger
+	connName  string                        // prefix of the connection names, see SetClientName
+	connSeq   int                           // count of the connections named
+	checkConn func(conn redis.Conn) error   // rejects the unusable connections when dialed and borrowed, see connCheckInterval
+
+	flights   flightGroup   // deduplicates concurrent loads in the cache helpers
+	checkType int32         // 1 if CheckType mode is enabled
+	closed    int32         // 1 if the client is closed
+	db        int32         // database selected by Select, -1 for the one of the address
+	types     typeCache     // types of keys cached in CheckType mode
+	retry     retrySettings // retry policy set by SetRetryPolicy
+
+	parent *RedisClient // client the connections are shared with, see WithPrefix
+	prefix string       // prepended to the keys, see WithPrefix
 }
 
 var (
@@ -20,516 +52,2989 @@ var (
 	mapMutex *sync.RWMutex
 )
 
+// ErrNil indicates that a reply value is nil, e.g. the key does not exists.
+// it is returned by the methods reading a single value, like Get and HGet
+var ErrNil = redis.ErrNil
+
+// ErrClientClosed is returned by the commands of a closed RedisClient
+var ErrClientClosed = errors.New("redisutil: client is closed")
+
+// ErrNoSuchKey is returned by Rename and RenameNX if the source key does not exists
+var ErrNoSuchKey = errors.New("redisutil: no such key")
+
+// ErrTimeout is returned by BLPopN and BRPopN if no element was popped in time
+var ErrTimeout = errors.New("redisutil: timeout")
+
 const (
-	defaultTimeout = 60 * 10 // defaults to 10 minutes
+	defaultTimeout   = 60 * 10 // defaults to 10 minutes
+	defaultMaxIdle   = 5
+	defaultMaxActive = 20
+	defaultPingIdle  = time.Minute
 )
 
+// PoolConfig configures the connection pool of a RedisClient,
+// zero values use the defaults of GetRedisClient
+type PoolConfig struct {
+	MaxIdle         int           // max number of idle connections, defaults to 5
+	MaxActive       int           // max number of connections, defaults to 20
+	IdleTimeout     time.Duration // idle connections are closed after it, 0 means never
+	Wait            bool          // wait for a connection when MaxActive is reached instead of failing
+	MaxConnLifetime time.Duration // connections older than it are closed instead of reused, 0 means never
+	PingIdleAfter   time.Duration // connections idle longer are pinged when borrowed, defaults to 1 minute, negative means never
+	ReadTimeout     time.Duration // max wait for a reply, 0 means no timeout. blocking commands and subscriptions wait longer
+	WriteTimeout    time.Duration // max wait to write a command, 0 means no timeout
+	ConnectTimeout  time.Duration // max wait to connect to the server, 0 means no timeout
+}
+
+// PoolOptions configures the connection pool of a RedisClient
+//
+// Deprecated: use PoolConfig
+type PoolOptions = PoolConfig
+
+// dialOptions returns the options of redis.DialURL for the timeouts of cfg
+func (cfg PoolConfig) dialOptions() []redis.DialOption {
+	return []redis.DialOption{
+		redis.DialReadTimeout(cfg.ReadTimeout),
+		redis.DialWriteTimeout(cfg.WriteTimeout),
+		redis.DialConnectTimeout(cfg.ConnectTimeout),
+	}
+}
+
+// withDefaults returns cfg with zero limits replaced by the defaults
+func (cfg PoolConfig) withDefaults() PoolConfig {
+	if cfg.MaxIdle <= 0 {
+		cfg.MaxIdle = defaultMaxIdle
+	}
+	if cfg.MaxActive <= 0 {
+		cfg.MaxActive = defaultMaxActive
+	}
+	if cfg.PingIdleAfter == 0 {
+		cfg.PingIdleAfter = defaultPingIdle
+	}
+	return cfg
+}
+
 func init() {
 	redisMap = make(map[string]*RedisClient)
 	mapMutex = new(sync.RWMutex)
 }
 
-// returns new connection pool
-// redisURL: connection string, like "redis:// :password@10.0.1.11:6379/0"
-func newPool(redisURL string) *redis.Pool {
+// returns new client of address whose connections are created by dial,
+// initialized by the dial hooks and switched to the database of Select
+func newRedisClient(address string, cfg PoolConfig, dial func() (redis.Conn, error)) *RedisClient {
+	rc := &RedisClient{Address: address, db: -1}
+	rc.pool = newPoolWithConfig(cfg, func() (redis.Conn, error) {
+		c, err := dial()
+		if err != nil {
+			return nil, err
+		}
+		if err := rc.nameConn(c); err != nil {
+			c.Close()
+			return nil, err
+		}
+		if err := rc.runDialHooks(c); err != nil {
+			c.Close()
+			return nil, err
+		}
+		return c, nil
+	}, rc.prepareConn)
+	return rc
+}
+
+// connCheckInterval is the min interval between two checks of a borrowed
+// connection by checkConn, so the check does not double the round trips
+const connCheckInterval = time.Second
+
+// prepareConn checks c with checkConn and switches it to the database of
+// Select, when it is dialed and each time it is borrowed
+func (rc *RedisClient) prepareConn(c *clientConn) error {
+	if rc.checkConn != nil && time.Since(c.checked) >= connCheckInterval {
+		if err := rc.checkConn(c.Conn); err != nil {
+			return err
+		}
+		c.checked = time.Now()
+	}
+	return rc.selectConnDB(c)
+}
 
+// returns new connection pool configured by cfg, connections are created by
+// dial and passed to prepare when created and each time they are borrowed
+func newPoolWithConfig(cfg PoolConfig, dial func() (redis.Conn, error), prepare func(c *clientConn) error) *redis.Pool {
+	cfg = cfg.withDefaults()
 	return &redis.Pool{
-		MaxIdle:   5,
-		MaxActive: 20, // max number of connections
+		MaxIdle:     cfg.MaxIdle,
+		MaxActive:   cfg.MaxActive, // max number of connections
+		IdleTimeout: cfg.IdleTimeout,
+		Wait:        cfg.Wait,
 		Dial: func() (redis.Conn, error) {
-			c, err := redis.DialURL(redisURL)
-			return c, err
+			c, err := dial()
+			if err != nil {
+				return nil, err
+			}
+			pc := &clientConn{Conn: c, created: time.Now(), db: -1, readTimeout: cfg.ReadTimeout}
+			if err := prepare(pc); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return pc, nil
 		},
+		TestOnBorrow: func(c redis.Conn, idleSince time.Time) error {
+			pc, ok := c.(*clientConn)
+			if !ok {
+				return nil
+			}
+			atomic.StoreInt32(&pc.subscribed, 0)
+			if cfg.MaxConnLifetime > 0 && time.Since(pc.created) > cfg.MaxConnLifetime {
+				return errConnExpired
+			}
+			// a connection idle for long may have been closed by the server
+			if cfg.PingIdleAfter > 0 && time.Since(idleSince) > cfg.PingIdleAfter {
+				if _, err := pc.Do("PING"); err != nil {
+					return err
+				}
+			}
+			return prepare(pc)
+		},
+	}
+}
+
+var errConnExpired = errors.New("redisutil: connection exceeded MaxConnLifetime")
+
+// clientConn is a connection of the pool with the state needed by
+// MaxConnLifetime, Select, ReadTimeout and checkConn
+type clientConn struct {
+	redis.Conn
+	created     time.Time
+	db          int32         // database selected by Select, -1 for the one of the address
+	readTimeout time.Duration // ReadTimeout of the pool
+	subscribed  int32         // 1 if the connection subscribed to a channel since borrowed, read by Receive concurrently
+	checked     time.Time     // last time the connection passed checkConn
+}
+
+// Do extends the read timeout of the blocking commands by the time they block
+func (c *clientConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	c.trackSubscribe(commandName)
+	if c.readTimeout > 0 {
+		if block, ok := blockingDuration(commandName, args); ok {
+			timeout := time.Duration(0) // blocks indefinitely
+			if block > 0 {
+				timeout = c.readTimeout + block
+			}
+			return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+		}
+	}
+	return c.Conn.Do(commandName, args...)
+}
+
+func (c *clientConn) Send(commandName string, args ...interface{}) error {
+	c.trackSubscribe(commandName)
+	return c.Conn.Send(commandName, args...)
+}
+
+// Receive does not time out on a subscribed connection, which waits for
+// the messages published
+func (c *clientConn) Receive() (interface{}, error) {
+	if c.readTimeout > 0 && atomic.LoadInt32(&c.subscribed) == 1 {
+		return redis.ReceiveWithTimeout(c.Conn, 0)
+	}
+	return c.Conn.Receive()
+}
+
+func (c *clientConn) trackSubscribe(commandName string) {
+	switch strings.ToUpper(commandName) {
+	case "SUBSCRIBE", "PSUBSCRIBE", "SSUBSCRIBE":
+		atomic.StoreInt32(&c.subscribed, 1)
+	}
+}
+
+func (c *clientConn) DoWithTimeout(timeout time.Duration, commandName string, args ...interface{}) (interface{}, error) {
+	c.trackSubscribe(commandName)
+	return redis.DoWithTimeout(c.Conn, timeout, commandName, args...)
+}
+
+func (c *clientConn) ReceiveWithTimeout(timeout time.Duration) (interface{}, error) {
+	return redis.ReceiveWithTimeout(c.Conn, timeout)
+}
+
+// getOrCreateClient returns the client registered with key,
+// the client is created by newClient and registered if it does not exists
+func getOrCreateClient(key string, newClient func() *RedisClient) *RedisClient {
+	mapMutex.RLock()
+	client, mok := redisMap[key]
+	mapMutex.RUnlock()
+	if mok {
+		return client
+	}
+	mapMutex.Lock()
+	defer mapMutex.Unlock()
+	if client, mok = redisMap[key]; !mok {
+		client = newClient()
+		redisMap[key] = client
 	}
+	return client
 }
 
-// GetRedisClient returns the RedisClient of specified address
+// GetRedisClient returns the RedisClient of specified address,
+// connections use TLS if the scheme of address is "rediss://"
 func GetRedisClient(address string) *RedisClient {
-	var redis *RedisClient
-	var mok bool
+	return GetRedisClientWithConfig(address, PoolConfig{})
+}
+
+// GetRedisClientWithConfig returns the RedisClient of specified address whose
+// pool is configured by cfg. clients are registered per address and config,
+// so clients of the same address with different configs are distinct
+func GetRedisClientWithConfig(address string, cfg PoolConfig) *RedisClient {
+	cfg = cfg.withDefaults()
+	return getOrCreateClient(poolClientKey(address, cfg), func() *RedisClient {
+		// address is a connection string, like "redis:// :password@10.0.1.11:6379/0"
+		return newRedisClient(address, cfg, func() (redis.Conn, error) {
+			return redis.DialURL(address, cfg.dialOptions()...)
+		})
+	})
+}
+
+// poolClientKey returns address if cfg is the default config, otherwise
+// address with the config appended, like "redis://10.0.1.11:6379/0#pool=10,100,0s,true,0s,1m0s"
+func poolClientKey(address string, cfg PoolConfig) string {
+	if cfg == (PoolConfig{}).withDefaults() {
+		return address
+	}
+	key := fmt.Sprintf("%s#pool=%d,%d,%s,%t,%s,%s", address,
+		cfg.MaxIdle, cfg.MaxActive, cfg.IdleTimeout, cfg.Wait, cfg.MaxConnLifetime, cfg.PingIdleAfter)
+	if cfg.ReadTimeout != 0 || cfg.WriteTimeout != 0 || cfg.ConnectTimeout != 0 {
+		key += fmt.Sprintf(",%s,%s", cfg.ReadTimeout, cfg.WriteTimeout)
+	}
+	if cfg.ConnectTimeout != 0 {
+		key += "," + cfg.ConnectTimeout.String()
+	}
+	return key
+}
+
+// GetRedisClientTLS returns the RedisClient of specified address whose
+// connections use TLS configured by tlsConfig, e.g. with the root CAs of a
+// managed redis, or InsecureSkipVerify for a self-signed test server.
+// TLS is used even if the scheme of address is "redis://". the client is
+// registered per address, tlsConfig only applies when the client is created.
+// GetRedisClient already uses TLS with the system roots for "rediss://"
+func GetRedisClientTLS(address string, tlsConfig *tls.Config) *RedisClient {
+	return getOrCreateClient(address+"#tls", func() *RedisClient {
+		tlsAddress := address
+		if u, err := url.Parse(address); err == nil && u.Scheme == "redis" {
+			// DialURL decides on TLS by the scheme
+			u.Scheme = "rediss"
+			tlsAddress = u.String()
+		}
+		return newRedisClient(address, PoolConfig{}, func() (redis.Conn, error) {
+			return redis.DialURL(tlsAddress, redis.DialUseTLS(true), redis.DialTLSConfig(tlsConfig))
+		})
+	})
+}
+
+// GetRedisClientACL returns the RedisClient of specified address which
+// authenticates each new connection with "AUTH username password", for the
+// ACL users of redis 6. if username is empty, the default user is used.
+// address should not contain a password, the client is registered per
+// address and username, opts only applies when the client is created
+func GetRedisClientACL(address, username, password string, opts PoolConfig) *RedisClient {
+	return getOrCreateClient(aclClientKey(address, username), func() *RedisClient {
+		dial := func() (redis.Conn, error) {
+			c, err := redis.DialURL(address, opts.dialOptions()...)
+			if err != nil {
+				return nil, err
+			}
+			args := []interface{}{password}
+			if username != "" {
+				args = []interface{}{username, password}
+			}
+			if _, err := c.Do("AUTH", args...); err != nil {
+				c.Close()
+				return nil, err
+			}
+			return c, nil
+		}
+		return newRedisClient(address, opts, dial)
+	})
+}
+
+// GetRedisClientWithAuth is like GetRedisClientACL with the default pool
+// config, an empty username authenticates with the password only, like
+// the password of a "redis://:password@host:port" address
+func GetRedisClientWithAuth(address, username, password string) *RedisClient {
+	return GetRedisClientACL(address, username, password, PoolConfig{})
+}
+
+// aclClientKey returns address with the username as its user info,
+// like "redis://username@10.0.1.11:6379/0"
+func aclClientKey(address, username string) string {
+	u, err := url.Parse(address)
+	if err != nil || username == "" {
+		return address + "#" + username
+	}
+	u.User = url.User(username)
+	return u.String()
+}
+
+// WithDialHook adds hook which is called after each successful dial of a new
+// connection, e.g. to run CLIENT SETNAME. hooks run in the order they were
+// added, a hook returning an error rejects the connection and the error is
+// returned to the caller. clients are shared per address, so the hook applies
+// to all users of rc. connections already in the pool are not affected
+func (rc *RedisClient) WithDialHook(hook func(conn redis.Conn) error) *RedisClient {
+	if rc.parent != nil {
+		rc.parent.WithDialHook(hook)
+		return rc
+	}
+	rc.hookMutex.Lock()
+	rc.dialHooks = append(rc.dialHooks, hook)
+	rc.hookMutex.Unlock()
+	return rc
+}
+
+func (rc *RedisClient) runDialHooks(conn redis.Conn) error {
+	rc.hookMutex.RLock()
+	hooks := rc.dialHooks
+	rc.hookMutex.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(conn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisteredAddresses returns a snapshot of the addresses of all clients
+// created by GetRedisClient, sorted in ascending order. an address with
+// several clients, like clients created with a config, is returned once
+func RegisteredAddresses() []string {
 	mapMutex.RLock()
-	redis, mok = redisMap[address]
+	seen := make(map[string]bool, len(redisMap))
+	addresses := make([]string, 0, len(redisMap))
+	for _, client := range redisMap {
+		if !seen[client.Address] {
+			seen[client.Address] = true
+			addresses = append(addresses, client.Address)
+		}
+	}
 	mapMutex.RUnlock()
-	if !mok {
-		redis = &RedisClient{Address: address, pool: newPool(address)}
-		mapMutex.Lock()
-		redisMap[address] = redis
-		mapMutex.Unlock()
+	sort.Strings(addresses)
+	return addresses
+}
+
+// RemoveRedisClient unregisters and closes all clients of address,
+// including the clients created with a config or ACL user
+func RemoveRedisClient(address string) {
+	mapMutex.Lock()
+	var clients []*RedisClient
+	for key, client := range redisMap {
+		if client.Address == address {
+			clients = append(clients, client)
+			delete(redisMap, key)
+		}
+	}
+	mapMutex.Unlock()
+	for _, client := range clients {
+		client.Close()
+	}
+}
+
+// Close unregisters rc and closes its pool, so GetRedisClient creates a new
+// client for the address. commands of rc return ErrClientClosed afterwards
+func (rc *RedisClient) Close() error {
+	if rc.parent != nil {
+		return nil
+	}
+	rc.markClosed()
+	return rc.pool.Close()
+}
+
+// shutdownPollInterval is how often Shutdown checks the connections in use
+const shutdownPollInterval = 10 * time.Millisecond
+
+// Shutdown closes rc gracefully: commands of rc return ErrClientClosed
+// immediately like after Close, but the pool is only closed once all the
+// connections in use are returned to it. if ctx is done before, an error is
+// returned and the pool stays open, Shutdown or Close can be called again
+func (rc *RedisClient) Shutdown(ctx context.Context) error {
+	if rc.parent != nil {
+		return nil
+	}
+	rc.markClosed()
+	ticker := time.NewTicker(shutdownPollInterval)
+	defer ticker.Stop()
+	for {
+		stats := rc.pool.Stats()
+		inUse := stats.ActiveCount - stats.IdleCount
+		if inUse <= 0 {
+			return rc.pool.Close()
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("redisutil: shutdown with %d connections still in use: %v", inUse, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// isClosed returns whether rc, or the client it was created from, is closed
+func (rc *RedisClient) isClosed() bool {
+	if rc.parent != nil {
+		return rc.parent.isClosed()
+	}
+	return atomic.LoadInt32(&rc.closed) == 1
+}
+
+// markClosed makes the commands of rc return ErrClientClosed and unregisters it
+func (rc *RedisClient) markClosed() {
+	if !atomic.CompareAndSwapInt32(&rc.closed, 0, 1) {
+		return
+	}
+	mapMutex.Lock()
+	for key, client := range redisMap {
+		if client == rc {
+			delete(redisMap, key)
+		}
+	}
+	mapMutex.Unlock()
+}
+
+// ClientCount returns count of clients created by GetRedisClient and its
+// variants, each with its own pool. it counts the clients, not the addresses:
+// the clients of an address with several configs, TLS or users are each
+// counted, see RegisteredAddresses for the addresses
+func ClientCount() int {
+	mapMutex.RLock()
+	defer mapMutex.RUnlock()
+	return len(redisMap)
+}
+
+// PoolStats are the statistics of the connection pool of a RedisClient
+type PoolStats struct {
+	ActiveCount  int           // connections in the pool, idle or in use
+	IdleCount    int           // idle connections
+	MaxActive    int           // max number of connections
+	WaitCount    int64         // total count of waits for a connection when MaxActive was reached
+	WaitDuration time.Duration // total duration of the waits
+}
+
+// Stats returns the statistics of the connection pool of rc. waits only
+// happen if the pool is configured with Wait, they are counted when all
+// connections are in use at the time a connection is requested
+func (rc *RedisClient) Stats() PoolStats {
+	if rc.parent != nil {
+		return rc.parent.Stats()
+	}
+	stats := rc.pool.Stats()
+	return PoolStats{
+		ActiveCount:  stats.ActiveCount,
+		IdleCount:    stats.IdleCount,
+		MaxActive:    rc.pool.MaxActive,
+		WaitCount:    atomic.LoadInt64(&rc.waitCount),
+		WaitDuration: time.Duration(atomic.LoadInt64(&rc.waitNanos)),
 	}
-	return redis
+}
+
+// AllStats returns the statistics of the pools of all clients created by
+// GetRedisClient, by the key they are registered with
+func AllStats() map[string]PoolStats {
+	mapMutex.RLock()
+	defer mapMutex.RUnlock()
+	stats := make(map[string]PoolStats, len(redisMap))
+	for key, client := range redisMap {
+		stats[key] = client.Stats()
+	}
+	return stats
+}
+
+// saturated returns whether all the connections of the pool are in use, so
+// getting one waits if the pool is configured with Wait
+func (rc *RedisClient) saturated() bool {
+	if !rc.pool.Wait || rc.pool.MaxActive <= 0 {
+		return false
+	}
+	stats := rc.pool.Stats()
+	return stats.IdleCount == 0 && stats.ActiveCount >= rc.pool.MaxActive
+}
+
+// recordWait records a wait for a connection started at start
+func (rc *RedisClient) recordWait(start time.Time) {
+	atomic.AddInt64(&rc.waitCount, 1)
+	atomic.AddInt64(&rc.waitNanos, int64(time.Since(start)))
 }
 
 // GetObj returns the content specified by key
 func (rc *RedisClient) GetObj(key string) (interface{}, error) {
-	conn := rc.pool.Get()
+	return rc.GetObjCtx(context.Background(), key)
+}
+
+// GetObjCtx is like GetObj but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetObjCtx(ctx context.Context, key string) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
 	reply, errDo := conn.Do("GET", key)
 	return reply, errDo
 }
 
-// Get returns the content as string specified by key
+// Get returns the content as string specified by key,
+// returns ErrNil if key does not exists, so it is distinguished from an empty content
 func (rc *RedisClient) Get(key string) (string, error) {
-	val, err := redis.String(rc.GetObj(key))
+	return rc.GetCtx(context.Background(), key)
+}
+
+// GetCtx is like Get but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetCtx(ctx context.Context, key string) (string, error) {
+	val, err := redis.String(rc.GetObjCtx(ctx, key))
+	return val, err
+}
+
+// GetBytes returns the raw content specified by key without any conversion,
+// returns ErrNil if key does not exists
+func (rc *RedisClient) GetBytes(key string) ([]byte, error) {
+	return rc.GetBytesCtx(context.Background(), key)
+}
+
+// GetBytesCtx is like GetBytes but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetBytesCtx(ctx context.Context, key string) ([]byte, error) {
+	val, err := redis.Bytes(rc.GetObjCtx(ctx, key))
 	return val, err
 }
 
 // Exists whether key exists
 func (rc *RedisClient) Exists(key string) (bool, error) {
-	conn := rc.pool.Get()
+	return rc.ExistsCtx(context.Background(), key)
+}
+
+// ExistsCtx is like Exists but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ExistsCtx(ctx context.Context, key string) (bool, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
 
 	reply, errDo := redis.Bool(conn.Do("EXISTS", key))
 	return reply, errDo
 }
 
-// Del deletes specified key
-func (rc *RedisClient) Del(key string) (int64, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	reply, errDo := conn.Do("DEL", key)
-	if errDo == nil && reply == nil {
-		return 0, nil
+// ExistsMany returns the count of the specified keys which exist with a
+// single EXISTS, a key given several times is counted as many times
+func (rc *RedisClient) ExistsMany(keys ...string) (int64, error) {
+	return rc.ExistsManyCtx(context.Background(), keys...)
+}
+
+// ExistsManyCtx is like ExistsMany but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ExistsManyCtx(ctx context.Context, keys ...string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("EXISTS", redis.Args{}.AddFlat(keys)...))
+	return val, err
+}
+
+// Del deletes the specified keys with a single DEL,
+// returns the count of keys which existed
+func (rc *RedisClient) Del(keys ...string) (int64, error) {
+	return rc.DelCtx(context.Background(), keys...)
+}
+
+// DelCtx is like Del but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DelCtx(ctx context.Context, keys ...string) (int64, error) {
+	return rc.deleteKeys(ctx, "DEL", keys)
+}
+
+// Unlink is like Del but the memory of the values is reclaimed in
+// background by the server, so deleting large values does not block it
+func (rc *RedisClient) Unlink(keys ...string) (int64, error) {
+	return rc.UnlinkCtx(context.Background(), keys...)
+}
+
+// UnlinkCtx is like Unlink but honors the deadline and cancellation of ctx
+func (rc *RedisClient) UnlinkCtx(ctx context.Context, keys ...string) (int64, error) {
+	return rc.deleteKeys(ctx, "UNLINK", keys)
+}
+
+// deleteKeys deletes keys with cmd, DEL or UNLINK
+func (rc *RedisClient) deleteKeys(ctx context.Context, cmd string, keys []string) (int64, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do(cmd, redis.Args{}.AddFlat(keys)...))
+	return val, err
+}
+
+// INCR atomically increment the value by 1 specified by key
+func (rc *RedisClient) INCR(key string) (int, error) {
+	return rc.INCRCtx(context.Background(), key)
+}
+
+// INCRCtx is like INCR but honors the deadline and cancellation of ctx
+func (rc *RedisClient) INCRCtx(ctx context.Context, key string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, errDo := conn.Do("INCR", key)
+	if errDo == nil && reply == nil {
+		return 0, nil
+	}
+	val, err := redis.Int(reply, errDo)
+	return val, err
+}
+
+// DECR atomically decrement the value by 1 specified by key
+func (rc *RedisClient) DECR(key string) (int, error) {
+	return rc.DECRCtx(context.Background(), key)
+}
+
+// DECRCtx is like DECR but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DECRCtx(ctx context.Context, key string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, errDo := conn.Do("DECR", key)
+	if errDo == nil && reply == nil {
+		return 0, nil
+	}
+	val, err := redis.Int(reply, errDo)
+	return val, err
+}
+
+// incrWithExpireScript increments KEYS[1] and sets its expire to ARGV[1]
+// seconds when the increment created it
+var incrWithExpireScript = redis.NewScript(1, `
+local val = redis.call('INCR', KEYS[1])
+if val == 1 then
+	redis.call('EXPIRE', KEYS[1], ARGV[1])
+end
+return val
+`)
+
+// IncrWithExpire atomically increments the value of key by 1 and returns it,
+// the key expires after ttlSeconds from the increment which created it.
+// unlike INCR followed by Expire, the key cannot be left without expire
+func (rc *RedisClient) IncrWithExpire(key string, ttlSeconds int64) (int64, error) {
+	return rc.IncrWithExpireCtx(context.Background(), key, ttlSeconds)
+}
+
+// IncrWithExpireCtx is like IncrWithExpire but honors the deadline and cancellation of ctx
+func (rc *RedisClient) IncrWithExpireCtx(ctx context.Context, key string, ttlSeconds int64) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(incrWithExpireScript.Do(conn, key, ttlSeconds))
+	return val, err
+}
+
+// Append appends the string to original value specivied by key,
+// returns the length of the value after the append.
+// if key does not exists, it behaves like Set
+func (rc *RedisClient) Append(key string, val interface{}) (int64, error) {
+	return rc.AppendCtx(context.Background(), key, val)
+}
+
+// AppendCtx is like Append but honors the deadline and cancellation of ctx
+func (rc *RedisClient) AppendCtx(ctx context.Context, key string, val interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	length, err := redis.Int64(conn.Do("APPEND", key, val))
+	return length, err
+}
+
+// StrLen returns the length of the value of key, returns 0 if key does not exists
+func (rc *RedisClient) StrLen(key string) (int64, error) {
+	return rc.StrLenCtx(context.Background(), key)
+}
+
+// StrLenCtx is like StrLen but honors the deadline and cancellation of ctx
+func (rc *RedisClient) StrLenCtx(ctx context.Context, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("STRLEN", key))
+	return val, err
+}
+
+// GetRange returns the substring of the value of key between the offsets
+// start and end, both inclusive. negative offsets count from the end,
+// -1 is the last byte
+func (rc *RedisClient) GetRange(key string, start, end int64) (string, error) {
+	return rc.GetRangeCtx(context.Background(), key, start, end)
+}
+
+// GetRangeCtx is like GetRange but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetRangeCtx(ctx context.Context, key string, start, end int64) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("GETRANGE", key, start, end))
+	return val, err
+}
+
+// SetRange overwrites the value of key from offset with val, the value is
+// padded with zero bytes if it is shorter than offset. returns the length of
+// the value after the change
+func (rc *RedisClient) SetRange(key string, offset int64, val string) (int64, error) {
+	return rc.SetRangeCtx(context.Background(), key, offset, val)
+}
+
+// SetRangeCtx is like SetRange but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetRangeCtx(ctx context.Context, key string, offset int64, val string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	length, err := redis.Int64(conn.Do("SETRANGE", key, offset, val))
+	return length, err
+}
+
+// Set put key/value into redis
+func (rc *RedisClient) Set(key string, val interface{}) (interface{}, error) {
+	return rc.SetCtx(context.Background(), key, val)
+}
+
+// SetCtx is like Set but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetCtx(ctx context.Context, key string, val interface{}) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("SET", key, val))
+	return val, err
+}
+
+// SetBytes put key/value into redis, the bytes are stored as is
+func (rc *RedisClient) SetBytes(key string, val []byte) error {
+	return rc.SetBytesCtx(context.Background(), key, val)
+}
+
+// SetBytesCtx is like SetBytes but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetBytesCtx(ctx context.Context, key string, val []byte) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("SET", key, val)
+	return err
+}
+
+// Expire specifies the expire duration for key
+func (rc *RedisClient) Expire(key string, timeOutSeconds int64) (int64, error) {
+	return rc.ExpireCtx(context.Background(), key, timeOutSeconds)
+}
+
+// ExpireCtx is like Expire but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ExpireCtx(ctx context.Context, key string, timeOutSeconds int64) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("EXPIRE", key, timeOutSeconds))
+	return val, err
+}
+
+// ExpireWithFlag sets the expire of key to seconds if the condition flag is
+// met: "NX" if key has no expire, "XX" if it has one, "GT" if seconds is
+// greater than the current expire and "LT" if it is less. returns false if
+// the condition is not met or key does not exists. requires redis 7
+func (rc *RedisClient) ExpireWithFlag(key string, seconds int64, flag string) (bool, error) {
+	return rc.ExpireWithFlagCtx(context.Background(), key, seconds, flag)
+}
+
+// ExpireWithFlagCtx is like ExpireWithFlag but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ExpireWithFlagCtx(ctx context.Context, key string, seconds int64, flag string) (bool, error) {
+	switch strings.ToUpper(flag) {
+	case "NX", "XX", "GT", "LT":
+	default:
+		return false, errors.New("redisutil: ExpireWithFlag flag must be NX, XX, GT or LT")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("EXPIRE", key, seconds, flag))
+	return val, err
+}
+
+// TTL returns the remaining time to live of key in seconds,
+// returns -2 if key does not exists and -1 if key has no expire
+func (rc *RedisClient) TTL(key string) (int64, error) {
+	return rc.TTLCtx(context.Background(), key)
+}
+
+// TTLCtx is like TTL but honors the deadline and cancellation of ctx
+func (rc *RedisClient) TTLCtx(ctx context.Context, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("TTL", key))
+	return val, err
+}
+
+// PTTL returns the remaining time to live of key in milliseconds,
+// returns -2 if key does not exists and -1 if key has no expire
+func (rc *RedisClient) PTTL(key string) (int64, error) {
+	return rc.PTTLCtx(context.Background(), key)
+}
+
+// PTTLCtx is like PTTL but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PTTLCtx(ctx context.Context, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("PTTL", key))
+	return val, err
+}
+
+// Persist removes the expire of key, returns false if key does not exists
+// or has no expire
+func (rc *RedisClient) Persist(key string) (bool, error) {
+	return rc.PersistCtx(context.Background(), key)
+}
+
+// PersistCtx is like Persist but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PersistCtx(ctx context.Context, key string) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("PERSIST", key))
+	return val, err
+}
+
+// ExpireAt specifies the expire time of key as unix timestamp in seconds,
+// returns false if key does not exists
+func (rc *RedisClient) ExpireAt(key string, unixTime int64) (bool, error) {
+	return rc.ExpireAtCtx(context.Background(), key, unixTime)
+}
+
+// ExpireAtCtx is like ExpireAt but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ExpireAtCtx(ctx context.Context, key string, unixTime int64) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("EXPIREAT", key, unixTime))
+	return val, err
+}
+
+// Rename renames key src to dst, the value of dst is overwritten if it exists.
+// returns ErrNoSuchKey if src does not exists
+func (rc *RedisClient) Rename(src, dst string) error {
+	return rc.RenameCtx(context.Background(), src, dst)
+}
+
+// RenameCtx is like Rename but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RenameCtx(ctx context.Context, src, dst string) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("RENAME", src, dst)
+	return noSuchKeyErr(err)
+}
+
+// RenameNX renames key src to dst if dst does not exists, returns false if
+// dst exists. returns ErrNoSuchKey if src does not exists
+func (rc *RedisClient) RenameNX(src, dst string) (bool, error) {
+	return rc.RenameNXCtx(context.Background(), src, dst)
+}
+
+// RenameNXCtx is like RenameNX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RenameNXCtx(ctx context.Context, src, dst string) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("RENAMENX", src, dst))
+	return val, noSuchKeyErr(err)
+}
+
+// noSuchKeyErr returns ErrNoSuchKey for the "no such key" error of redis
+func noSuchKeyErr(err error) error {
+	if e, ok := err.(redis.Error); ok && strings.Contains(strings.ToLower(e.Error()), "no such key") {
+		return ErrNoSuchKey
+	}
+	return err
+}
+
+// Move moves key to the database db, returns false if key does not exists
+// or already exists in db
+func (rc *RedisClient) Move(key string, db int) (bool, error) {
+	return rc.MoveCtx(context.Background(), key, db)
+}
+
+// MoveCtx is like Move but honors the deadline and cancellation of ctx
+func (rc *RedisClient) MoveCtx(ctx context.Context, key string, db int) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("MOVE", key, db))
+	return val, err
+}
+
+// Copy copies the value of key src to dst, dst is overwritten if replace is
+// true. returns false if src does not exists or dst exists and replace is
+// false. requires redis 6.2
+func (rc *RedisClient) Copy(src, dst string, replace bool) (bool, error) {
+	return rc.CopyCtx(context.Background(), src, dst, replace)
+}
+
+// CopyCtx is like Copy but honors the deadline and cancellation of ctx
+func (rc *RedisClient) CopyCtx(ctx context.Context, src, dst string, replace bool) (bool, error) {
+	args := []interface{}{src, dst}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("COPY", args...))
+	return val, err
+}
+
+// Dump returns the value of key serialized in the format of redis, to be
+// restored by Restore. returns ErrNil if key does not exists
+func (rc *RedisClient) Dump(key string) ([]byte, error) {
+	return rc.DumpCtx(context.Background(), key)
+}
+
+// DumpCtx is like Dump but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DumpCtx(ctx context.Context, key string) ([]byte, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bytes(conn.Do("DUMP", key))
+	return val, err
+}
+
+// Restore creates key with the value serialized by Dump, the key expires
+// after ttlMillis milliseconds, 0 means no expire. an existing key is
+// overwritten if replace is true, otherwise an error is returned
+func (rc *RedisClient) Restore(key string, ttlMillis int64, data []byte, replace bool) error {
+	return rc.RestoreCtx(context.Background(), key, ttlMillis, data, replace)
+}
+
+// RestoreCtx is like Restore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RestoreCtx(ctx context.Context, key string, ttlMillis int64, data []byte, replace bool) error {
+	args := []interface{}{key, ttlMillis, data}
+	if replace {
+		args = append(args, "REPLACE")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("RESTORE", args...)
+	return err
+}
+
+// Type returns the type of the value of key, like "string", "list", "set",
+// "zset" or "hash", returns "none" if key does not exists
+func (rc *RedisClient) Type(key string) (string, error) {
+	return rc.TypeCtx(context.Background(), key)
+}
+
+// TypeCtx is like Type but honors the deadline and cancellation of ctx
+func (rc *RedisClient) TypeCtx(ctx context.Context, key string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("TYPE", key))
+	return val, err
+}
+
+// SetWithExpire set the key/value with specified duration
+func (rc *RedisClient) SetWithExpire(key string, val interface{}, timeOutSeconds int64) (interface{}, error) {
+	return rc.SetWithExpireCtx(context.Background(), key, val, timeOutSeconds)
+}
+
+// SetWithExpireCtx is like SetWithExpire but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetWithExpireCtx(ctx context.Context, key string, val interface{}, timeOutSeconds int64) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("SET", key, val, "EX", timeOutSeconds))
+	return val, err
+}
+
+// SetEX sets key/value with an expire of seconds
+func (rc *RedisClient) SetEX(key string, val interface{}, seconds int64) error {
+	return rc.SetEXCtx(context.Background(), key, val, seconds)
+}
+
+// SetEXCtx is like SetEX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetEXCtx(ctx context.Context, key string, val interface{}, seconds int64) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("SETEX", key, seconds, val)
+	return err
+}
+
+// PSetEX sets key/value with an expire of ms milliseconds
+func (rc *RedisClient) PSetEX(key string, val interface{}, ms int64) error {
+	return rc.PSetEXCtx(context.Background(), key, val, ms)
+}
+
+// PSetEXCtx is like PSetEX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PSetEXCtx(ctx context.Context, key string, val interface{}, ms int64) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("PSETEX", key, ms, val)
+	return err
+}
+
+// SetOptions are the options of SetOpts, the zero value sets the key
+// unconditionally without expire
+type SetOptions struct {
+	NX      bool  // only set the key if it does not exists
+	XX      bool  // only set the key if it already exists
+	EX      int64 // expire in seconds, 0 means no expire
+	PX      int64 // expire in milliseconds, 0 means no expire
+	KeepTTL bool  // keep the expire of the key, requires redis 6
+}
+
+// args returns the arguments of SET for opts
+func (opts SetOptions) args() []interface{} {
+	var args []interface{}
+	if opts.NX {
+		args = append(args, "NX")
+	}
+	if opts.XX {
+		args = append(args, "XX")
+	}
+	if opts.EX > 0 {
+		args = append(args, "EX", opts.EX)
+	}
+	if opts.PX > 0 {
+		args = append(args, "PX", opts.PX)
+	}
+	if opts.KeepTTL {
+		args = append(args, "KEEPTTL")
+	}
+	return args
+}
+
+// SetOpts sets key/value with the options of SET, returns false if the key
+// was not set because the condition of NX or XX is not met
+func (rc *RedisClient) SetOpts(key string, val interface{}, opts SetOptions) (bool, error) {
+	return rc.SetOptsCtx(context.Background(), key, val, opts)
+}
+
+// SetOptsCtx is like SetOpts but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetOptsCtx(ctx context.Context, key string, val interface{}, opts SetOptions) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, err := conn.Do("SET", append([]interface{}{key, val}, opts.args()...)...)
+	if err != nil {
+		return false, err
+	}
+	return reply != nil, nil
+}
+
+// GetSet atomically sets key to val and returns the previous content,
+// returns ErrNil if key did not exist
+func (rc *RedisClient) GetSet(key string, val interface{}) (string, error) {
+	return rc.GetSetCtx(context.Background(), key, val)
+}
+
+// GetSetCtx is like GetSet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetSetCtx(ctx context.Context, key string, val interface{}) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, err := redis.String(conn.Do("GETSET", key, val))
+	return reply, err
+}
+
+// GetDel atomically returns the content of key and deletes it,
+// returns ErrNil if key does not exists. servers older than redis 6.2 without
+// GETDEL are sent GET and DEL in a transaction
+func (rc *RedisClient) GetDel(key string) (string, error) {
+	return rc.GetDelCtx(context.Background(), key)
+}
+
+// GetDelCtx is like GetDel but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetDelCtx(ctx context.Context, key string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, err := conn.Do("GETDEL", key)
+	if isUnknownCommand(err) {
+		conn.Send("MULTI")
+		conn.Send("GET", key)
+		conn.Send("DEL", key)
+		var values []interface{}
+		if values, err = redis.Values(conn.Do("EXEC")); err != nil {
+			return "", err
+		}
+		reply = values[0]
+	}
+	val, err := redis.String(reply, err)
+	return val, err
+}
+
+// isUnknownCommand returns whether err is the error of a command which is
+// not supported by the server
+func isUnknownCommand(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(strings.ToLower(string(e)), "err unknown command")
+}
+
+// SetNX sets key/value only if key does not exists,
+// it does nothing if key already exists. returns 1 on success, 0 on failure
+func (rc *RedisClient) SetNX(key, value string) (interface{}, error) {
+	return rc.SetNXCtx(context.Background(), key, value)
+}
+
+// SetNXCtx is like SetNX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetNXCtx(ctx context.Context, key, value string) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+
+	val, err := conn.Do("SETNX", key, value)
+	return val, err
+}
+
+// MGet returns the contents of all keys in order with a single MGET,
+// the content of a key which does not exists is empty
+func (rc *RedisClient) MGet(keys ...string) ([]string, error) {
+	return rc.MGetCtx(context.Background(), keys...)
+}
+
+// MGetCtx is like MGet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) MGetCtx(ctx context.Context, keys ...string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	return mgetStrings(conn.Do("MGET", redis.Args{}.AddFlat(keys)...))
+}
+
+// MGetPipelined is like MGet for a large count of keys, it splits keys in
+// batches of batchSize keys and sends an MGET per batch in a single pipeline,
+// so no command is oversized. batchSize not positive sends a single MGET
+func (rc *RedisClient) MGetPipelined(keys []string, batchSize int) ([]string, error) {
+	return rc.MGetPipelinedCtx(context.Background(), keys, batchSize)
+}
+
+// MGetPipelinedCtx is like MGetPipelined but honors the deadline and cancellation of ctx
+func (rc *RedisClient) MGetPipelinedCtx(ctx context.Context, keys []string, batchSize int) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	if batchSize <= 0 || batchSize > len(keys) {
+		batchSize = len(keys)
+	}
+	p := rc.PipelineCtx(ctx)
+	defer p.Close()
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		p.Send("MGET", redis.Args{}.AddFlat(keys[start:end])...)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(keys))
+	for _, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			return nil, err
+		}
+		values, err := mgetStrings(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, values...)
+	}
+	return result, nil
+}
+
+// mgetStrings converts the reply of MGET, the values of missing keys are empty
+func mgetStrings(reply interface{}, err error) ([]string, error) {
+	values, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if result[i], err = redis.String(value, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// MSet put all key/value pairs into redis with a single MSET
+func (rc *RedisClient) MSet(pairs map[string]interface{}) error {
+	return rc.MSetCtx(context.Background(), pairs)
+}
+
+// MSetCtx is like MSet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) MSetCtx(ctx context.Context, pairs map[string]interface{}) error {
+	if len(pairs) == 0 {
+		return nil
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("MSET", redis.Args{}.AddFlat(pairs)...)
+	return err
+}
+
+// MSetWithExpire sets all key/value pairs of items with an expire of
+// ttlSeconds, sending a SET per item in a single pipeline. the items which
+// failed are reported together in the error, the others are set
+func (rc *RedisClient) MSetWithExpire(items map[string]interface{}, ttlSeconds int64) error {
+	return rc.MSetWithExpireCtx(context.Background(), items, ttlSeconds)
+}
+
+// MSetWithExpireCtx is like MSetWithExpire but honors the deadline and cancellation of ctx
+func (rc *RedisClient) MSetWithExpireCtx(ctx context.Context, items map[string]interface{}, ttlSeconds int64) error {
+	if len(items) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	p := rc.PipelineCtx(ctx)
+	defer p.Close()
+	for _, key := range keys {
+		p.Send("SET", key, items[key], "EX", ttlSeconds)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for i, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			errs = append(errs, keys[i]+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("redisutil: MSetWithExpire failed for %d of %d keys: %s", len(errs), len(keys), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ****************** bitmap ***********************
+
+// BitPos returns the position of the first bit set to bit (0 or 1) in the
+// string specified by key, searching the bytes from start to end.
+// start 0 and end -1 search the whole string, in which case looking for a
+// clear bit in a string with all bits set returns the bit right after it.
+// returns -1 when no such bit is found
+func (rc *RedisClient) BitPos(key string, bit int, start, end int64) (int64, error) {
+	return rc.BitPosCtx(context.Background(), key, bit, start, end)
+}
+
+// BitPosCtx is like BitPos but honors the deadline and cancellation of ctx
+func (rc *RedisClient) BitPosCtx(ctx context.Context, key string, bit int, start, end int64) (int64, error) {
+	if bit != 0 && bit != 1 {
+		return 0, errors.New("redisutil: BitPos bit must be 0 or 1")
+	}
+	args := []interface{}{key, bit}
+	if start != 0 || end != -1 {
+		args = append(args, start, end)
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("BITPOS", args...))
+	return val, err
+}
+
+// SetBit sets the bit at offset of the string specified by key to value
+// (0 or 1), returns the previous bit
+func (rc *RedisClient) SetBit(key string, offset int64, value int) (int64, error) {
+	return rc.SetBitCtx(context.Background(), key, offset, value)
+}
+
+// SetBitCtx is like SetBit but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SetBitCtx(ctx context.Context, key string, offset int64, value int) (int64, error) {
+	if value != 0 && value != 1 {
+		return 0, errors.New("redisutil: SetBit value must be 0 or 1")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("SETBIT", key, offset, value))
+	return val, err
+}
+
+// GetBit returns the bit at offset of the string specified by key,
+// returns 0 if key does not exists or offset is beyond the string
+func (rc *RedisClient) GetBit(key string, offset int64) (int64, error) {
+	return rc.GetBitCtx(context.Background(), key, offset)
+}
+
+// GetBitCtx is like GetBit but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GetBitCtx(ctx context.Context, key string, offset int64) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("GETBIT", key, offset))
+	return val, err
+}
+
+// BitCount returns the count of bits set in the string specified by key.
+// byteRange is optional, if given it must be the start and end bytes to count
+func (rc *RedisClient) BitCount(key string, byteRange ...int64) (int64, error) {
+	return rc.BitCountCtx(context.Background(), key, byteRange...)
+}
+
+// BitCountCtx is like BitCount but honors the deadline and cancellation of ctx
+func (rc *RedisClient) BitCountCtx(ctx context.Context, key string, byteRange ...int64) (int64, error) {
+	if len(byteRange) != 0 && len(byteRange) != 2 {
+		return 0, errors.New("redisutil: BitCount byteRange must be start and end")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("BITCOUNT", redis.Args{key}.AddFlat(byteRange)...))
+	return val, err
+}
+
+// BitOp stores the result of the bitwise operation op (AND, OR, XOR or NOT)
+// between the strings of srcKeys at destKey, returns the length of the result
+func (rc *RedisClient) BitOp(op string, destKey string, srcKeys ...string) (int64, error) {
+	return rc.BitOpCtx(context.Background(), op, destKey, srcKeys...)
+}
+
+// BitOpCtx is like BitOp but honors the deadline and cancellation of ctx
+func (rc *RedisClient) BitOpCtx(ctx context.Context, op string, destKey string, srcKeys ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("BITOP", redis.Args{op, destKey}.AddFlat(srcKeys)...))
+	return val, err
+}
+
+// ****************** hash set ***********************
+
+// HGet returns content specified by hashID and field,
+// returns ErrNil if hashID or field does not exists
+func (rc *RedisClient) HGet(hashID string, field string) (string, error) {
+	return rc.HGetCtx(context.Background(), hashID, field)
+}
+
+// HGetCtx is like HGet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HGetCtx(ctx context.Context, hashID string, field string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("HGET", hashID, field))
+	return val, err
+}
+
+// HGetAll returns all content specified by hashID,
+// returns empty if hashID does not exists as redis does not distinguish it from an empty hash
+func (rc *RedisClient) HGetAll(hashID string) (map[string]string, error) {
+	return rc.HGetAllCtx(context.Background(), hashID)
+}
+
+// HGetAllCtx is like HGetAll but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HGetAllCtx(ctx context.Context, hashID string) (map[string]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, err := redis.StringMap(conn.Do("HGetAll", hashID))
+	return reply, err
+}
+
+// HSet set content with hashID and field
+func (rc *RedisClient) HSet(hashID string, field string, val string) error {
+	return rc.HSetCtx(context.Background(), hashID, field, val)
+}
+
+// HSetCtx is like HSet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HSetCtx(ctx context.Context, hashID string, field string, val string) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("HSET", hashID, field, val)
+	return err
+}
+
+// HSetNX set content with hashID and field, if the field does not exists,
+// this operation has no effect
+func (rc *RedisClient) HSetNX(hashID, field, value string) (interface{}, error) {
+	return rc.HSetNXCtx(context.Background(), hashID, field, value)
+}
+
+// HSetNXCtx is like HSetNX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HSetNXCtx(ctx context.Context, hashID, field, value string) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+
+	val, err := conn.Do("HSETNX", hashID, field, value)
+	return val, err
+}
+
+// HExist returns if the field exists in specified hashID, 1 if it exists.
+// use HExists for a bool result
+func (rc *RedisClient) HExist(hashID string, field string) (int, error) {
+	return rc.HExistCtx(context.Background(), hashID, field)
+}
+
+// HExistCtx is like HExist but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HExistCtx(ctx context.Context, hashID string, field string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int(conn.Do("HEXISTS", hashID, field))
+	return val, err
+}
+
+// HIncrBy increment the value specified by hashID and field by delta,
+// returns the value after the increment
+func (rc *RedisClient) HIncrBy(hashID string, field string, delta int64) (int64, error) {
+	return rc.HIncrByCtx(context.Background(), hashID, field, delta)
+}
+
+// HIncrByCtx is like HIncrBy but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HIncrByCtx(ctx context.Context, hashID string, field string, delta int64) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("HINCRBY", hashID, field, delta))
+	return val, err
+}
+
+// HLen returns count of fileds in hashID, returns 0 if hashID does not exists
+func (rc *RedisClient) HLen(hashID string) (int64, error) {
+	return rc.HLenCtx(context.Background(), hashID)
+}
+
+// HLenCtx is like HLen but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HLenCtx(ctx context.Context, hashID string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+
+	val, err := redis.Int64(conn.Do("HLEN", hashID))
+	return val, err
+}
+
+// HDel delete content in hashset, if the field does not exists, this operation
+// returns 0 and have no effect
+func (rc *RedisClient) HDel(args ...interface{}) (int64, error) {
+	return rc.HDelCtx(context.Background(), args...)
+}
+
+// HDelCtx is like HDel but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HDelCtx(ctx context.Context, args ...interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+
+	val, err := redis.Int64(conn.Do("HDEL", args...))
+	return val, err
+}
+
+// HVals return all the values in all fields specified by hashID, returns empty
+// if hashID does not exists
+func (rc *RedisClient) HVals(hashID string) (interface{}, error) {
+	return rc.HValsCtx(context.Background(), hashID)
+}
+
+// HValsCtx is like HVals but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HValsCtx(ctx context.Context, hashID string) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+
+	val, err := redis.Strings(conn.Do("HVALS", hashID))
+	return val, err
+}
+
+// HMGet returns the contents of fields in hashID in order,
+// the content of a field which does not exists is empty
+func (rc *RedisClient) HMGet(hashID string, fields ...string) ([]string, error) {
+	return rc.HMGetCtx(context.Background(), hashID, fields...)
+}
+
+// HMGetCtx is like HMGet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HMGetCtx(ctx context.Context, hashID string, fields ...string) ([]string, error) {
+	if len(fields) == 0 {
+		return []string{}, nil
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("HMGET", redis.Args{}.Add(hashID).AddFlat(fields)...))
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(values))
+	for i, value := range values {
+		if value == nil {
+			continue
+		}
+		if result[i], err = redis.String(value, nil); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// HMSet set the contents of all fields in hashID
+func (rc *RedisClient) HMSet(hashID string, fields map[string]interface{}) error {
+	return rc.HMSetCtx(context.Background(), hashID, fields)
+}
+
+// HMSetCtx is like HMSet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HMSetCtx(ctx context.Context, hashID string, fields map[string]interface{}) error {
+	if len(fields) == 0 {
+		return nil
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("HMSET", redis.Args{}.Add(hashID).AddFlat(fields)...)
+	return err
+}
+
+// HExists returns whether the field exists in specified hashID
+func (rc *RedisClient) HExists(hashID, field string) (bool, error) {
+	return rc.HExistsCtx(context.Background(), hashID, field)
+}
+
+// HExistsCtx is like HExists but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HExistsCtx(ctx context.Context, hashID, field string) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("HEXISTS", hashID, field))
+	return val, err
+}
+
+// HKeys returns all field names in hashID, returns empty if hashID does not exists
+func (rc *RedisClient) HKeys(hashID string) ([]string, error) {
+	return rc.HKeysCtx(context.Background(), hashID)
+}
+
+// HKeysCtx is like HKeys but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HKeysCtx(ctx context.Context, hashID string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("HKEYS", hashID))
+	return val, err
+}
+
+// HGetStruct fills the fields of the struct pointed by dest with the content
+// of hashID, using the "redis" field tags like redis.ScanStruct.
+// returns ErrNil if hashID does not exists
+func (rc *RedisClient) HGetStruct(hashID string, dest interface{}) error {
+	return rc.HGetStructCtx(context.Background(), hashID, dest)
+}
+
+// HGetStructCtx is like HGetStruct but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HGetStructCtx(ctx context.Context, hashID string, dest interface{}) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("HGETALL", hashID))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return ErrNil
+	}
+	return redis.ScanStruct(values, dest)
+}
+
+// HSetStruct set the content of hashID from the fields of the struct src,
+// using the "redis" field tags like redis.Args.AddFlat
+func (rc *RedisClient) HSetStruct(hashID string, src interface{}) error {
+	return rc.HSetStructCtx(context.Background(), hashID, src)
+}
+
+// HSetStructCtx is like HSetStruct but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HSetStructCtx(ctx context.Context, hashID string, src interface{}) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("HMSET", redis.Args{}.Add(hashID).AddFlat(src)...)
+	return err
+}
+
+// HashWithTTL is the content of a hash with its remaining time to live
+type HashWithTTL struct {
+	Fields map[string]string
+	TTL    int64 // seconds, -1 if no expire, -2 if the hash does not exists
+}
+
+// HGetAllWithTTLMulti returns all content and the ttl of each hashID,
+// using a single pipeline of HGETALL and TTL per hashID.
+// missing hashID returns empty Fields and TTL -2
+func (rc *RedisClient) HGetAllWithTTLMulti(hashIDs ...string) (map[string]HashWithTTL, error) {
+	return rc.HGetAllWithTTLMultiCtx(context.Background(), hashIDs...)
+}
+
+// HGetAllWithTTLMultiCtx is like HGetAllWithTTLMulti but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HGetAllWithTTLMultiCtx(ctx context.Context, hashIDs ...string) (map[string]HashWithTTL, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	for _, hashID := range hashIDs {
+		conn.Send("HGETALL", hashID)
+		conn.Send("TTL", hashID)
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	result := make(map[string]HashWithTTL, len(hashIDs))
+	for _, hashID := range hashIDs {
+		fields, err := redis.StringMap(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		ttl, err := redis.Int64(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		result[hashID] = HashWithTTL{Fields: fields, TTL: ttl}
+	}
+	return result, nil
+}
+
+// HGetAllMany returns all content of each hashID, using a single pipeline
+// of HGETALL per hashID. missing hashID returns an empty map
+func (rc *RedisClient) HGetAllMany(hashIDs ...string) (map[string]map[string]string, error) {
+	return rc.HGetAllManyCtx(context.Background(), hashIDs...)
+}
+
+// HGetAllManyCtx is like HGetAllMany but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HGetAllManyCtx(ctx context.Context, hashIDs ...string) (map[string]map[string]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	for _, hashID := range hashIDs {
+		conn.Send("HGETALL", hashID)
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	result := make(map[string]map[string]string, len(hashIDs))
+	for _, hashID := range hashIDs {
+		fields, err := redis.StringMap(conn.Receive())
+		if err != nil {
+			return nil, err
+		}
+		result[hashID] = fields
+	}
+	return result, nil
+}
+
+// ****************** list ***********************
+
+// LPush inserts the values into front of the list, returns the length of the list
+func (rc *RedisClient) LPush(key string, value ...interface{}) (int64, error) {
+	return rc.LPushCtx(context.Background(), key, value...)
+}
+
+// LPushCtx is like LPush but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LPushCtx(ctx context.Context, key string, value ...interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	args := append([]interface{}{key}, value...)
+	resp, err := redis.Int64(conn.Do("LPUSH", args...))
+	return resp, err
+}
+
+func (rc *RedisClient) LPushX(key string, value string) (int, error) {
+	return rc.LPushXCtx(context.Background(), key, value)
+}
+
+// LPushXCtx is like LPushX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LPushXCtx(ctx context.Context, key string, value string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.Int(conn.Do("LPUSHX", key, value))
+	return resp, err
+}
+
+// LRange returns the elements of the list from start to stop
+func (rc *RedisClient) LRange(key string, start, stop int64) ([]string, error) {
+	return rc.LRangeCtx(context.Background(), key, start, stop)
+}
+
+// LRangeCtx is like LRange but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LRangeCtx(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.Strings(conn.Do("LRANGE", key, start, stop))
+	return resp, err
+}
+
+// LRem removes count occurrences of value from the list, from head to tail
+// if count is positive, from tail to head if negative and all if 0.
+// returns count of removed elements
+func (rc *RedisClient) LRem(key string, count int64, value string) (int64, error) {
+	return rc.LRemCtx(context.Background(), key, count, value)
+}
+
+// LRemCtx is like LRem but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LRemCtx(ctx context.Context, key string, count int64, value string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.Int64(conn.Do("LREM", key, count, value))
+	return resp, err
+}
+
+func (rc *RedisClient) LSet(key string, index int, value string) (string, error) {
+	return rc.LSetCtx(context.Background(), key, index, value)
+}
+
+// LSetCtx is like LSet but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LSetCtx(ctx context.Context, key string, index int, value string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.String(conn.Do("LSET", key, index, value))
+	return resp, err
+}
+
+func (rc *RedisClient) LTrim(key string, start int, stop int) (string, error) {
+	return rc.LTrimCtx(context.Background(), key, start, stop)
+}
+
+// LTrimCtx is like LTrim but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LTrimCtx(ctx context.Context, key string, start int, stop int) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.String(conn.Do("LTRIM", key, start, stop))
+	return resp, err
+}
+
+// RPop removes and returns the last element of the list,
+// returns ErrNil if the list is empty
+func (rc *RedisClient) RPop(key string) (string, error) {
+	return rc.RPopCtx(context.Background(), key)
+}
+
+// RPopCtx is like RPop but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RPopCtx(ctx context.Context, key string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.String(conn.Do("RPOP", key))
+	return resp, err
+}
+
+// RPush inserts the values at the end of the list, returns the length of the list
+func (rc *RedisClient) RPush(key string, value ...interface{}) (int64, error) {
+	return rc.RPushCtx(context.Background(), key, value...)
+}
+
+// RPushCtx is like RPush but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RPushCtx(ctx context.Context, key string, value ...interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	args := append([]interface{}{key}, value...)
+	resp, err := redis.Int64(conn.Do("RPUSH", args...))
+	return resp, err
+}
+
+func (rc *RedisClient) RPushX(key string, value ...interface{}) (int, error) {
+	return rc.RPushXCtx(context.Background(), key, value...)
+}
+
+// RPushXCtx is like RPushX but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RPushXCtx(ctx context.Context, key string, value ...interface{}) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	args := append([]interface{}{key}, value...)
+	resp, err := redis.Int(conn.Do("RPUSHX", args...))
+	return resp, err
+}
+
+func (rc *RedisClient) RPopLPush(source string, destination string) (string, error) {
+	return rc.RPopLPushCtx(context.Background(), source, destination)
+}
+
+// RPopLPushCtx is like RPopLPush but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RPopLPushCtx(ctx context.Context, source string, destination string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	resp, err := redis.String(conn.Do("RPOPLPUSH", source, destination))
+	return resp, err
+}
+
+// LMove atomically pops an element from the srcWhere end of the list src
+// and pushes it to the dstWhere end of the list dst, where is "LEFT" or
+// "RIGHT". returns the element moved, ErrNil if src is empty. requires redis 6.2
+func (rc *RedisClient) LMove(src, dst, srcWhere, dstWhere string) (string, error) {
+	return rc.LMoveCtx(context.Background(), src, dst, srcWhere, dstWhere)
+}
+
+// LMoveCtx is like LMove but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LMoveCtx(ctx context.Context, src, dst, srcWhere, dstWhere string) (string, error) {
+	for _, where := range []string{srcWhere, dstWhere} {
+		switch strings.ToUpper(where) {
+		case "LEFT", "RIGHT":
+		default:
+			return "", errors.New("redisutil: LMove where must be LEFT or RIGHT")
+		}
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("LMOVE", src, dst, srcWhere, dstWhere))
+	return val, err
+}
+
+// LPos returns the index of the first occurrence of element in the list,
+// returns ErrNil if element is not in the list. requires redis 6.0.6
+func (rc *RedisClient) LPos(key, element string) (int64, error) {
+	return rc.LPosCtx(context.Background(), key, element)
+}
+
+// LPosCtx is like LPos but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LPosCtx(ctx context.Context, key, element string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("LPOS", key, element))
+	return val, err
+}
+
+func (rc *RedisClient) BLPop(key ...interface{}) (map[string]string, error) {
+	return rc.BLPopCtx(context.Background(), key...)
+}
+
+// BLPopCtx is like BLPop but honors the deadline and cancellation of ctx,
+// a canceled ctx aborts the wait
+func (rc *RedisClient) BLPopCtx(ctx context.Context, key ...interface{}) (map[string]string, error) {
+	val, err := redis.StringMap(rc.blockingDo(ctx, "BLPOP", key...))
+	return val, err
+}
+
+// BRPop returns the last element in the list and delete it. It blocks if the
+// list is empty
+func (rc *RedisClient) BRPop(key ...interface{}) (map[string]string, error) {
+	return rc.BRPopCtx(context.Background(), key...)
+}
+
+// BRPopCtx is like BRPop but honors the deadline and cancellation of ctx,
+// a canceled ctx aborts the wait
+func (rc *RedisClient) BRPopCtx(ctx context.Context, key ...interface{}) (map[string]string, error) {
+	val, err := redis.StringMap(rc.blockingDo(ctx, "BRPOP", key...))
+	return val, err
+}
+
+// BLPopN pops the first element of the first non empty list of keys, it
+// blocks at most timeout until an element is pushed, 0 blocks indefinitely.
+// returns the key of the list and the element, or ErrTimeout
+func (rc *RedisClient) BLPopN(timeout time.Duration, keys ...string) (key string, value string, err error) {
+	return rc.BLPopNCtx(context.Background(), timeout, keys...)
+}
+
+// BLPopNCtx is like BLPopN but honors the deadline and cancellation of ctx,
+// a canceled ctx aborts the wait
+func (rc *RedisClient) BLPopNCtx(ctx context.Context, timeout time.Duration, keys ...string) (key string, value string, err error) {
+	return rc.blockingPop(ctx, "BLPOP", timeout, keys)
+}
+
+// BRPopN is like BLPopN but pops the last element of the list
+func (rc *RedisClient) BRPopN(timeout time.Duration, keys ...string) (key string, value string, err error) {
+	return rc.BRPopNCtx(context.Background(), timeout, keys...)
+}
+
+// BRPopNCtx is like BRPopN but honors the deadline and cancellation of ctx,
+// a canceled ctx aborts the wait
+func (rc *RedisClient) BRPopNCtx(ctx context.Context, timeout time.Duration, keys ...string) (key string, value string, err error) {
+	return rc.blockingPop(ctx, "BRPOP", timeout, keys)
+}
+
+// blockingPop pops an element of keys with cmd, BLPOP or BRPOP. timeout is
+// rounded up to seconds, the unit of the servers before redis 6
+func (rc *RedisClient) blockingPop(ctx context.Context, cmd string, timeout time.Duration, keys []string) (string, string, error) {
+	if len(keys) == 0 {
+		return "", "", errors.New("redisutil: " + cmd + " requires at least one key")
+	}
+	seconds := int64((timeout + time.Second - 1) / time.Second)
+	reply, err := rc.blockingDoTimeout(ctx, seconds, cmd, redis.Args{}.AddFlat(keys)...)
+	if err == nil && reply == nil {
+		return "", "", ErrTimeout
+	}
+	values, err := redis.Strings(reply, err)
+	if err != nil {
+		return "", "", err
+	}
+	if len(values) != 2 {
+		return "", "", errors.New("redisutil: unexpected " + cmd + " reply")
+	}
+	return values[0], values[1], nil
+}
+
+func (rc *RedisClient) BRPopLPush(source string, destination string) (string, error) {
+	return rc.BRPopLPushCtx(context.Background(), source, destination)
+}
+
+// BRPopLPushCtx is like BRPopLPush but honors the deadline and cancellation of ctx,
+// a canceled ctx aborts the wait
+func (rc *RedisClient) BRPopLPushCtx(ctx context.Context, source string, destination string) (string, error) {
+	val, err := redis.String(rc.blockingDo(ctx, "BRPOPLPUSH", source, destination))
+	return val, err
+}
+
+// LIndex returns the element at index of the list, negative index counts from
+// the end. returns ErrNil if index is out of range
+func (rc *RedisClient) LIndex(key string, index int64) (string, error) {
+	return rc.LIndexCtx(context.Background(), key, index)
+}
+
+// LIndexCtx is like LIndex but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LIndexCtx(ctx context.Context, key string, index int64) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("LINDEX", key, index))
+	return val, err
+}
+
+func (rc *RedisClient) LInsertBefore(key string, pivot string, value string) (int, error) {
+	return rc.LInsertBeforeCtx(context.Background(), key, pivot, value)
+}
+
+// LInsertBeforeCtx is like LInsertBefore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LInsertBeforeCtx(ctx context.Context, key string, pivot string, value string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int(conn.Do("LINSERT", key, "BEFORE", pivot, value))
+	return val, err
+}
+
+func (rc *RedisClient) LInsertAfter(key string, pivot string, value string) (int, error) {
+	return rc.LInsertAfterCtx(context.Background(), key, pivot, value)
+}
+
+// LInsertAfterCtx is like LInsertAfter but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LInsertAfterCtx(ctx context.Context, key string, pivot string, value string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int(conn.Do("LINSERT", key, "AFTER", pivot, value))
+	return val, err
+}
+
+// LLen returns the length of the list, returns 0 if key does not exists
+func (rc *RedisClient) LLen(key string) (int64, error) {
+	return rc.LLenCtx(context.Background(), key)
+}
+
+// LLenCtx is like LLen but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LLenCtx(ctx context.Context, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("LLEN", key))
+	return val, err
+}
+
+// LPop removes and returns the first element of the list,
+// returns ErrNil if the list is empty
+func (rc *RedisClient) LPop(key string) (string, error) {
+	return rc.LPopCtx(context.Background(), key)
+}
+
+// LPopCtx is like LPop but honors the deadline and cancellation of ctx
+func (rc *RedisClient) LPopCtx(ctx context.Context, key string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("LPOP", key))
+	return val, err
+}
+
+// drainListScript returns all items of the list KEYS[1] and deletes it
+var drainListScript = redis.NewScript(1, `
+local items = redis.call('LRANGE', KEYS[1], 0, -1)
+redis.call('DEL', KEYS[1])
+return items
+`)
+
+// DrainList atomically returns all items of the list in list order and
+// deletes it, so no item pushed concurrently is lost or returned twice.
+// returns empty if key does not exists
+func (rc *RedisClient) DrainList(key string) ([]string, error) {
+	return rc.DrainListCtx(context.Background(), key)
+}
+
+// DrainListCtx is like DrainList but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DrainListCtx(ctx context.Context, key string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(drainListScript.Do(conn, key))
+	return val, err
+}
+
+// ****************** set ***********************
+
+// SAdd add one or multiple members in to the set, creates a new set with key
+// if it does not exists
+func (rc *RedisClient) SAdd(key string, member ...interface{}) (int, error) {
+	return rc.SAddCtx(context.Background(), key, member...)
+}
+
+// SAddCtx is like SAdd but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SAddCtx(ctx context.Context, key string, member ...interface{}) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	args := append([]interface{}{key}, member...)
+	val, err := redis.Int(conn.Do("SADD", args...))
+	return val, err
+}
+
+// SCard returns cardinality of the set(count of elements).
+// returns 0 when set does not exist
+func (rc *RedisClient) SCard(key string) (int, error) {
+	return rc.SCardCtx(context.Background(), key)
+}
+
+// SCardCtx is like SCard but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SCardCtx(ctx context.Context, key string) (int, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int(conn.Do("SCARD", key))
+	return val, err
+}
+
+// SPop return and remove a random element from the set,
+// use SRandMember if the element should not be removed
+func (rc *RedisClient) SPop(key string) (string, error) {
+	return rc.SPopCtx(context.Background(), key)
+}
+
+// SPopCtx is like SPop but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SPopCtx(ctx context.Context, key string) (string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("SPOP", key))
+	return val, err
+}
+
+// SPopN return and remove at most count random elements from the set,
+// returns empty if the set does not exists
+func (rc *RedisClient) SPopN(key string, count int64) ([]string, error) {
+	return rc.SPopNCtx(context.Background(), key, count)
+}
+
+// SPopNCtx is like SPopN but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SPopNCtx(ctx context.Context, key string, count int64) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("SPOP", key, count))
+	return val, err
+}
+
+// SRandMember returns random count elements from set
+func (rc *RedisClient) SRandMember(key string, count int) ([]string, error) {
+	return rc.SRandMemberCtx(context.Background(), key, count)
+}
+
+// SRandMemberCtx is like SRandMember but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SRandMemberCtx(ctx context.Context, key string, count int) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("SRANDMEMBER", key, count))
+	return val, err
+}
+
+// SRem remove multiple elements from set, returns count of removed elements
+func (rc *RedisClient) SRem(key string, member ...interface{}) (int64, error) {
+	return rc.SRemCtx(context.Background(), key, member...)
+}
+
+// SRemCtx is like SRem but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SRemCtx(ctx context.Context, key string, member ...interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	args := append([]interface{}{key}, member...)
+	val, err := redis.Int64(conn.Do("SREM", args...))
+	return val, err
+}
+
+// SDiff returns the members of the first set which are not in the other sets
+func (rc *RedisClient) SDiff(keys ...string) ([]string, error) {
+	return rc.SDiffCtx(context.Background(), keys...)
+}
+
+// SDiffCtx is like SDiff but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SDiffCtx(ctx context.Context, keys ...string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("SDIFF", redis.Args{}.AddFlat(keys)...))
+	return val, err
+}
+
+// SDiffStore stores the members of the first set which are not in the other sets in dest,
+// overwriting it, and returns the count of its members. the members are
+// computed by the server, so they are not sent to the client
+func (rc *RedisClient) SDiffStore(dest string, keys ...string) (int64, error) {
+	return rc.SDiffStoreCtx(context.Background(), dest, keys...)
+}
+
+// SDiffStoreCtx is like SDiffStore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SDiffStoreCtx(ctx context.Context, dest string, keys ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("SDIFFSTORE", redis.Args{dest}.AddFlat(keys)...))
+	return val, err
+}
+
+// SInter returns the members of the intersection of all sets
+func (rc *RedisClient) SInter(keys ...string) ([]string, error) {
+	return rc.SInterCtx(context.Background(), keys...)
+}
+
+// SInterCtx is like SInter but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SInterCtx(ctx context.Context, keys ...string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("SINTER", redis.Args{}.AddFlat(keys)...))
+	return val, err
+}
+
+// SInterStore stores the intersection of the sets in dest,
+// overwriting it, and returns the count of its members. the members are
+// computed by the server, so they are not sent to the client
+func (rc *RedisClient) SInterStore(dest string, keys ...string) (int64, error) {
+	return rc.SInterStoreCtx(context.Background(), dest, keys...)
+}
+
+// SInterStoreCtx is like SInterStore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SInterStoreCtx(ctx context.Context, dest string, keys ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("SINTERSTORE", redis.Args{dest}.AddFlat(keys)...))
+	return val, err
+}
+
+// SIsMember returns whether member is a member of the set
+func (rc *RedisClient) SIsMember(key string, member string) (bool, error) {
+	return rc.SIsMemberCtx(context.Background(), key, member)
+}
+
+// SIsMemberCtx is like SIsMember but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SIsMemberCtx(ctx context.Context, key string, member string) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("SISMEMBER", key, member))
+	return val, err
+}
+
+// SMembers returns all members of the set, returns empty if key does not exists
+func (rc *RedisClient) SMembers(key string) ([]string, error) {
+	return rc.SMembersCtx(context.Background(), key)
+}
+
+// SMembersCtx is like SMembers but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SMembersCtx(ctx context.Context, key string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("SMEMBERS", key))
+	return val, err
+}
+
+// SMove atomically moves member from the set source to the set destination,
+// returns false if member is not in source, in which case no set is modified
+func (rc *RedisClient) SMove(source string, destination string, member string) (bool, error) {
+	return rc.SMoveCtx(context.Background(), source, destination, member)
+}
+
+// SMoveCtx is like SMove but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SMoveCtx(ctx context.Context, source string, destination string, member string) (bool, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Bool(conn.Do("SMOVE", source, destination, member))
+	return val, err
+}
+
+// SUnion returns the members of the union of all sets
+func (rc *RedisClient) SUnion(keys ...string) ([]string, error) {
+	return rc.SUnionCtx(context.Background(), keys...)
+}
+
+// SUnionCtx is like SUnion but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SUnionCtx(ctx context.Context, keys ...string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("SUNION", redis.Args{}.AddFlat(keys)...))
+	return val, err
+}
+
+// SUnionStore stores the union of the sets in dest,
+// overwriting it, and returns the count of its members. the members are
+// computed by the server, so they are not sent to the client
+func (rc *RedisClient) SUnionStore(dest string, keys ...string) (int64, error) {
+	return rc.SUnionStoreCtx(context.Background(), dest, keys...)
+}
+
+// SUnionStoreCtx is like SUnionStore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SUnionStoreCtx(ctx context.Context, dest string, keys ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("SUNIONSTORE", redis.Args{dest}.AddFlat(keys)...))
+	return val, err
+}
+
+// SAddMany adds the members of each key of entries to its set with a single
+// pipeline of SADD per key. the errors of the keys which failed are
+// returned together, the other sets are updated anyway
+func (rc *RedisClient) SAddMany(entries map[string][]interface{}) error {
+	return rc.SAddManyCtx(context.Background(), entries)
+}
+
+// SAddManyCtx is like SAddMany but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SAddManyCtx(ctx context.Context, entries map[string][]interface{}) error {
+	return rc.pipelineMembers(ctx, "SAddMany", "SADD", entries)
+}
+
+// SRemMany removes the members of each key of entries from its set with a
+// single pipeline of SREM per key, the errors are returned like SAddMany
+func (rc *RedisClient) SRemMany(entries map[string][]interface{}) error {
+	return rc.SRemManyCtx(context.Background(), entries)
+}
+
+// SRemManyCtx is like SRemMany but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SRemManyCtx(ctx context.Context, entries map[string][]interface{}) error {
+	return rc.pipelineMembers(ctx, "SRemMany", "SREM", entries)
+}
+
+// pipelineMembers sends cmd with the members of each key of entries in a
+// single pipeline, the keys without members are skipped
+func (rc *RedisClient) pipelineMembers(ctx context.Context, name, cmd string, entries map[string][]interface{}) error {
+	keys := make([]string, 0, len(entries))
+	for key, members := range entries {
+		if len(members) > 0 {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	sort.Strings(keys)
+	p := rc.PipelineCtx(ctx)
+	defer p.Close()
+	for _, key := range keys {
+		p.Send(cmd, append([]interface{}{key}, entries[key]...)...)
+	}
+	replies, err := p.Exec()
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for i, reply := range replies {
+		if err, ok := reply.(redis.Error); ok {
+			errs = append(errs, keys[i]+": "+err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("redisutil: %s failed for %d of %d keys: %s", name, len(errs), len(keys), strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// ****************** sorted set ***********************
+
+// ZAdd adds member with score to the sorted set, or updates its score if it
+// already exists. returns 1 if member is added, 0 if updated
+func (rc *RedisClient) ZAdd(key string, score float64, member string) (int64, error) {
+	return rc.ZAddCtx(context.Background(), key, score, member)
+}
+
+// ZAddCtx is like ZAdd but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZAddCtx(ctx context.Context, key string, score float64, member string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("ZADD", key, score, member))
+	return val, err
+}
+
+// ZAddOptions are the options of ZAddOpts, the zero value adds or updates
+// the member unconditionally like ZAdd
+type ZAddOptions struct {
+	NX bool // only add new members, never update the score of existing ones
+	XX bool // only update the score of existing members, never add new ones
+	GT bool // only update the score if the new score is greater, requires redis 6.2
+	LT bool // only update the score if the new score is less, requires redis 6.2
+	CH bool // return the count of members added or whose score changed
+}
+
+// args returns the arguments of ZADD for opts, or an error if they are
+// mutually exclusive
+func (opts ZAddOptions) args() ([]interface{}, error) {
+	if opts.NX && (opts.XX || opts.GT || opts.LT) {
+		return nil, errors.New("redisutil: ZAddOpts NX cannot be combined with XX, GT or LT")
+	}
+	if opts.GT && opts.LT {
+		return nil, errors.New("redisutil: ZAddOpts GT cannot be combined with LT")
+	}
+	var args []interface{}
+	for _, opt := range []struct {
+		set  bool
+		name string
+	}{{opts.NX, "NX"}, {opts.XX, "XX"}, {opts.GT, "GT"}, {opts.LT, "LT"}, {opts.CH, "CH"}} {
+		if opt.set {
+			args = append(args, opt.name)
+		}
+	}
+	return args, nil
+}
+
+// ZAddOpts adds member with score to the sorted set like ZAdd, under the
+// conditions of opts. returns 1 if member is added, 0 otherwise, or with CH
+// 1 if member is added or its score changed
+func (rc *RedisClient) ZAddOpts(key string, opts ZAddOptions, score float64, member string) (int64, error) {
+	return rc.ZAddOptsCtx(context.Background(), key, opts, score, member)
+}
+
+// ZAddOptsCtx is like ZAddOpts but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZAddOptsCtx(ctx context.Context, key string, opts ZAddOptions, score float64, member string) (int64, error) {
+	args, err := opts.args()
+	if err != nil {
+		return 0, err
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	args = append(append([]interface{}{key}, args...), score, member)
+	val, err := redis.Int64(conn.Do("ZADD", args...))
+	return val, err
+}
+
+// ZRange returns the members of the sorted set from start to stop, ordered
+// by score from low to high
+func (rc *RedisClient) ZRange(key string, start, stop int64) ([]string, error) {
+	return rc.ZRangeCtx(context.Background(), key, start, stop)
+}
+
+// ZRangeCtx is like ZRange but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRangeCtx(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("ZRANGE", key, start, stop))
+	return val, err
+}
+
+// ZRangeWithScores returns the members of the sorted set from start to stop
+// with their scores
+func (rc *RedisClient) ZRangeWithScores(key string, start, stop int64) (map[string]float64, error) {
+	return rc.ZRangeWithScoresCtx(context.Background(), key, start, stop)
+}
+
+// ZRangeWithScoresCtx is like ZRangeWithScores but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRangeWithScoresCtx(ctx context.Context, key string, start, stop int64) (map[string]float64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	values, err := redis.Values(conn.Do("ZRANGE", key, start, stop, "WITHSCORES"))
+	if err != nil {
+		return nil, err
+	}
+	val := make(map[string]float64, len(values)/2)
+	for len(values) > 0 {
+		var member string
+		var score float64
+		if values, err = redis.Scan(values, &member, &score); err != nil {
+			return nil, err
+		}
+		val[member] = score
+	}
+	return val, nil
+}
+
+// ZScore returns the score of member in the sorted set,
+// returns ErrNil if member or key does not exists
+func (rc *RedisClient) ZScore(key, member string) (float64, error) {
+	return rc.ZScoreCtx(context.Background(), key, member)
+}
+
+// ZScoreCtx is like ZScore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZScoreCtx(ctx context.Context, key, member string) (float64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Float64(conn.Do("ZSCORE", key, member))
+	return val, err
+}
+
+// ZRem removes members from the sorted set, returns count of removed members
+func (rc *RedisClient) ZRem(key string, members ...string) (int64, error) {
+	return rc.ZRemCtx(context.Background(), key, members...)
+}
+
+// ZRemCtx is like ZRem but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRemCtx(ctx context.Context, key string, members ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("ZREM", redis.Args{}.Add(key).AddFlat(members)...))
+	return val, err
+}
+
+// ZIncrBy increments the score of member in the sorted set by incr,
+// member is added with score incr if it does not exists. returns the new score
+func (rc *RedisClient) ZIncrBy(key string, incr float64, member string) (float64, error) {
+	return rc.ZIncrByCtx(context.Background(), key, incr, member)
+}
+
+// ZIncrByCtx is like ZIncrBy but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZIncrByCtx(ctx context.Context, key string, incr float64, member string) (float64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Float64(conn.Do("ZINCRBY", key, incr, member))
+	return val, err
+}
+
+// ZRangeByScore returns the members of the sorted set with a score between
+// min and max inclusive, ordered by score from low to high. math.Inf can be
+// used for an unbounded range. offset and count paginate the members, a
+// count not positive returns all members after offset
+func (rc *RedisClient) ZRangeByScore(key string, min, max float64, offset, count int64) ([]string, error) {
+	return rc.ZRangeByScoreCtx(context.Background(), key, min, max, offset, count)
+}
+
+// ZRangeByScoreCtx is like ZRangeByScore but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRangeByScoreCtx(ctx context.Context, key string, min, max float64, offset, count int64) ([]string, error) {
+	args := []interface{}{key, scoreArg(min), scoreArg(max)}
+	if offset > 0 || count > 0 {
+		if count <= 0 {
+			count = -1
+		}
+		args = append(args, "LIMIT", offset, count)
 	}
-	val, err := redis.Int64(reply, errDo)
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("ZRANGEBYSCORE", args...))
 	return val, err
 }
 
-// INCR atomically increment the value by 1 specified by key
-func (rc *RedisClient) INCR(key string) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	reply, errDo := conn.Do("INCR", key)
-	if errDo == nil && reply == nil {
-		return 0, nil
+// scoreArg returns score as an argument of the score ranges,
+// the infinities are "-inf" and "+inf"
+func scoreArg(score float64) interface{} {
+	switch {
+	case math.IsInf(score, -1):
+		return "-inf"
+	case math.IsInf(score, 1):
+		return "+inf"
 	}
-	val, err := redis.Int(reply, errDo)
-	return val, err
+	return score
 }
 
-// DECR atomically decrement the value by 1 specified by key
-func (rc *RedisClient) DECR(key string) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	reply, errDo := conn.Do("DECR", key)
-	if errDo == nil && reply == nil {
-		return 0, nil
-	}
-	val, err := redis.Int(reply, errDo)
-	return val, err
+// ZRevRange returns the members of the sorted set from start to stop, ordered
+// by score from high to low
+func (rc *RedisClient) ZRevRange(key string, start, stop int64) ([]string, error) {
+	return rc.ZRevRangeCtx(context.Background(), key, start, stop)
 }
 
-// Append appends the string to original value specivied by key.
-// if key does not exists, it behaves like Set
-func (rc *RedisClient) Append(key string, val interface{}) (interface{}, error) {
-	conn := rc.pool.Get()
+// ZRevRangeCtx is like ZRevRange but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRevRangeCtx(ctx context.Context, key string, start, stop int64) ([]string, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	reply, errDo := conn.Do("APPEND", key, val)
-	if errDo == nil && reply == nil {
-		return 0, nil
-	}
-	val, err := redis.Uint64(reply, errDo)
+	val, err := redis.Strings(conn.Do("ZREVRANGE", key, start, stop))
 	return val, err
 }
 
-// Set put key/value into redis
-func (rc *RedisClient) Set(key string, val interface{}) (interface{}, error) {
-	conn := rc.pool.Get()
+// ZRank returns the rank of member in the sorted set ordered by score from
+// low to high, the lowest is 0. returns ErrNil if member or key does not exists
+func (rc *RedisClient) ZRank(key, member string) (int64, error) {
+	return rc.ZRankCtx(context.Background(), key, member)
+}
+
+// ZRankCtx is like ZRank but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRankCtx(ctx context.Context, key, member string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.String(conn.Do("SET", key, val))
+	val, err := redis.Int64(conn.Do("ZRANK", key, member))
 	return val, err
 }
 
-// Expire specifies the expire duration for key
-func (rc *RedisClient) Expire(key string, timeOutSeconds int64) (int64, error) {
-	conn := rc.pool.Get()
+// ZRevRank returns the rank of member in the sorted set ordered by score from
+// high to low, the highest is 0. returns ErrNil if member or key does not exists
+func (rc *RedisClient) ZRevRank(key, member string) (int64, error) {
+	return rc.ZRevRankCtx(context.Background(), key, member)
+}
+
+// ZRevRankCtx is like ZRevRank but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZRevRankCtx(ctx context.Context, key, member string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.Int64(conn.Do("EXPIRE", key, timeOutSeconds))
+	val, err := redis.Int64(conn.Do("ZREVRANK", key, member))
 	return val, err
 }
 
-// SetWithExpire set the key/value with specified duration
-func (rc *RedisClient) SetWithExpire(key string, val interface{}, timeOutSeconds int64) (interface{}, error) {
-	conn := rc.pool.Get()
+// ZCard returns the count of members of the sorted set, 0 if key does not exists
+func (rc *RedisClient) ZCard(key string) (int64, error) {
+	return rc.ZCardCtx(context.Background(), key)
+}
+
+// ZCardCtx is like ZCard but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ZCardCtx(ctx context.Context, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.String(conn.Do("SET", key, val, "EX", timeOutSeconds))
+	val, err := redis.Int64(conn.Do("ZCARD", key))
 	return val, err
 }
 
-// SetNX sets key/value only if key does not exists,
-// it does nothing if key already exists. returns 1 on success, 0 on failure
-func (rc *RedisClient) SetNX(key, value string) (interface{}, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
+// ****************** hyperloglog ***********************
 
-	val, err := conn.Do("SETNX", key, value)
+// PFAdd adds elements to the HyperLogLog key, returns 1 if the estimated
+// cardinality changed, 0 otherwise
+func (rc *RedisClient) PFAdd(key string, elements ...interface{}) (int64, error) {
+	return rc.PFAddCtx(context.Background(), key, elements...)
+}
+
+// PFAddCtx is like PFAdd but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PFAddCtx(ctx context.Context, key string, elements ...interface{}) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("PFADD", redis.Args{key}.AddFlat(elements)...))
 	return val, err
 }
 
-// ****************** hash set ***********************
+// PFCount returns the estimated cardinality of the HyperLogLog key, or of the
+// union of the HyperLogLogs if several keys are specified
+func (rc *RedisClient) PFCount(keys ...string) (int64, error) {
+	return rc.PFCountCtx(context.Background(), keys...)
+}
 
-// HGet returns content specified by hashID and field
-func (rc *RedisClient) HGet(hashID string, field string) (string, error) {
-	conn := rc.pool.Get()
+// PFCountCtx is like PFCount but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PFCountCtx(ctx context.Context, keys ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	reply, errDo := conn.Do("HGET", hashID, field)
-	if errDo == nil && reply == nil {
-		return "", nil
-	}
-	val, err := redis.String(reply, errDo)
+	val, err := redis.Int64(conn.Do("PFCOUNT", redis.Args{}.AddFlat(keys)...))
 	return val, err
 }
 
-// HGetAll returns all content specified by hashID
-func (rc *RedisClient) HGetAll(hashID string) (map[string]string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	reply, err := redis.StringMap(conn.Do("HGetAll", hashID))
-	return reply, err
+// PFMerge stores the union of the HyperLogLogs sources at dest,
+// dest is merged too if it exists
+func (rc *RedisClient) PFMerge(dest string, sources ...string) error {
+	return rc.PFMergeCtx(context.Background(), dest, sources...)
 }
 
-// HSet set content with hashID and field
-func (rc *RedisClient) HSet(hashID string, field string, val string) error {
-	conn := rc.pool.Get()
+// PFMergeCtx is like PFMerge but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PFMergeCtx(ctx context.Context, dest string, sources ...string) error {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	_, err := conn.Do("HSET", hashID, field, val)
+	_, err := conn.Do("PFMERGE", redis.Args{dest}.AddFlat(sources)...)
 	return err
 }
 
-// HSetNX set content with hashID and field, if the field does not exists,
-// this operation has no effect
-func (rc *RedisClient) HSetNX(hashID, field, value string) (interface{}, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
+// ****************** geo ***********************
 
-	val, err := conn.Do("HSETNX", hashID, field, value)
-	return val, err
+// GeoAdd adds member at longitude and latitude to the geo set key,
+// returns 1 if member is added, 0 if its position is updated
+func (rc *RedisClient) GeoAdd(key string, longitude, latitude float64, member string) (int64, error) {
+	return rc.GeoAddCtx(context.Background(), key, longitude, latitude, member)
 }
 
-// HExist returns if the field exists in specified hashID
-func (rc *RedisClient) HExist(hashID string, field string) (int, error) {
-	conn := rc.pool.Get()
+// GeoAddCtx is like GeoAdd but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GeoAddCtx(ctx context.Context, key string, longitude, latitude float64, member string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.Int(conn.Do("HEXISTS", hashID, field))
+	val, err := redis.Int64(conn.Do("GEOADD", key, longitude, latitude, member))
 	return val, err
 }
 
-// HIncrBy increment the value specified by hashID and field
-func (rc *RedisClient) HIncrBy(hashID string, field string, increment int) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Int(conn.Do("HINCRBY", hashID, field, increment))
-	return val, err
+// GeoPos returns the longitude and latitude of members in order,
+// both are NaN for a member which does not exists
+func (rc *RedisClient) GeoPos(key string, members ...string) ([][2]float64, error) {
+	return rc.GeoPosCtx(context.Background(), key, members...)
 }
 
-// HLen returns count of fileds in hashID, returns 0 if hashID does not exists
-func (rc *RedisClient) HLen(hashID string) (int64, error) {
-	conn := rc.pool.Get()
+// GeoPosCtx is like GeoPos but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GeoPosCtx(ctx context.Context, key string, members ...string) ([][2]float64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
+	replies, err := redis.Values(conn.Do("GEOPOS", redis.Args{key}.AddFlat(members)...))
+	if err != nil {
+		return nil, err
+	}
+	positions := make([][2]float64, len(replies))
+	for i, reply := range replies {
+		if reply == nil {
+			positions[i] = [2]float64{math.NaN(), math.NaN()}
+			continue
+		}
+		pos, err := redis.Float64s(reply, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(pos) != 2 {
+			return nil, fmt.Errorf("redisutil: unexpected GEOPOS reply %v", pos)
+		}
+		positions[i] = [2]float64{pos[0], pos[1]}
+	}
+	return positions, nil
+}
 
-	val, err := redis.Int64(conn.Do("HLEN", hashID))
-	return val, err
+// GeoDist returns the distance between the members m1 and m2 in unit, one of
+// "m", "km", "mi" or "ft", defaults to "m" if unit is empty.
+// returns ErrNil if a member does not exists
+func (rc *RedisClient) GeoDist(key, m1, m2, unit string) (float64, error) {
+	return rc.GeoDistCtx(context.Background(), key, m1, m2, unit)
 }
 
-// HDel delete content in hashset, if the field does not exists, this operation
-// returns 0 and have no effect
-func (rc *RedisClient) HDel(args ...interface{}) (int64, error) {
-	conn := rc.pool.Get()
+// GeoDistCtx is like GeoDist but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GeoDistCtx(ctx context.Context, key, m1, m2, unit string) (float64, error) {
+	args := []interface{}{key, m1, m2}
+	if unit != "" {
+		args = append(args, unit)
+	}
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-
-	val, err := redis.Int64(conn.Do("HDEL", args...))
+	val, err := redis.Float64(conn.Do("GEODIST", args...))
 	return val, err
 }
 
-// HVals return all the values in all fields specified by hashID, returns empty
-// if hashID does not exists
-func (rc *RedisClient) HVals(hashID string) (interface{}, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
+// GeoRadius returns the members within radius in unit, one of "m", "km",
+// "mi" or "ft", of the point at longitude and latitude, nearest first
+func (rc *RedisClient) GeoRadius(key string, longitude, latitude, radius float64, unit string) ([]string, error) {
+	return rc.GeoRadiusCtx(context.Background(), key, longitude, latitude, radius, unit)
+}
 
-	val, err := redis.Strings(conn.Do("HVALS", hashID))
+// GeoRadiusCtx is like GeoRadius but honors the deadline and cancellation of ctx
+func (rc *RedisClient) GeoRadiusCtx(ctx context.Context, key string, longitude, latitude, radius float64, unit string) ([]string, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Strings(conn.Do("GEORADIUS", key, longitude, latitude, radius, unit, "ASC"))
 	return val, err
 }
 
-// ****************** list ***********************
+// ****************** Global functions ***********************
+
+// Ping tests the client is ready for use
+func (rc *RedisClient) Ping() (string, error) {
+	return rc.PingCtx(context.Background())
+}
 
-// LPush insert the values into front of the list
-func (rc *RedisClient) LPush(key string, value ...interface{}) (int, error) {
-	conn := rc.pool.Get()
+// PingCtx is like Ping but honors the deadline and cancellation of ctx
+func (rc *RedisClient) PingCtx(ctx context.Context) (string, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	ret, err := redis.Int(conn.Do("LPUSH", key, value))
+	val, err := redis.String(conn.Do("PING"))
+	return val, err
+}
+
+// HealthCheck borrows a connection and pings the server, returns an error if
+// the connection fails or the server does not reply PONG.
+// Ping keeps returning the reply for compatibility
+func (rc *RedisClient) HealthCheck() error {
+	return rc.HealthCheckCtx(context.Background())
+}
+
+// HealthCheckCtx is like HealthCheck but honors the deadline and cancellation of ctx
+func (rc *RedisClient) HealthCheckCtx(ctx context.Context) error {
+	val, err := rc.PingCtx(ctx)
 	if err != nil {
-		return -1, err
-	} else {
-		return ret, nil
+		return err
 	}
+	if val != "PONG" {
+		return fmt.Errorf("redisutil: unexpected PING reply %q", val)
+	}
+	return nil
 }
 
-func (rc *RedisClient) LPushX(key string, value string) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	resp, err := redis.Int(conn.Do("LPUSHX", key, value))
-	return resp, err
+// CommandGetKeys returns the keys of the command cmd with args,
+// so commands that are not known in advance can be routed by key
+func (rc *RedisClient) CommandGetKeys(cmd string, args ...interface{}) ([]string, error) {
+	return rc.CommandGetKeysCtx(context.Background(), cmd, args...)
 }
 
-func (rc *RedisClient) LRange(key string, start int, stop int) ([]string, error) {
-	conn := rc.pool.Get()
+// CommandGetKeysCtx is like CommandGetKeys but honors the deadline and cancellation of ctx
+func (rc *RedisClient) CommandGetKeysCtx(ctx context.Context, cmd string, args ...interface{}) ([]string, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	resp, err := redis.Strings(conn.Do("LRANGE", key, start, stop))
-	return resp, err
+	val, err := redis.Strings(conn.Do("COMMAND", append([]interface{}{"GETKEYS", cmd}, args...)...))
+	return val, err
 }
 
-func (rc *RedisClient) LRem(key string, count int, value string) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	resp, err := redis.Int(conn.Do("LREM", key, count, value))
-	return resp, err
+// DBSize returns count of keys in the database
+func (rc *RedisClient) DBSize() (int64, error) {
+	return rc.DBSizeCtx(context.Background())
 }
 
-func (rc *RedisClient) LSet(key string, index int, value string) (string, error) {
-	conn := rc.pool.Get()
+// DBSizeCtx is like DBSize but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DBSizeCtx(ctx context.Context) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	resp, err := redis.String(conn.Do("LSET", key, index, value))
-	return resp, err
+
+	val, err := redis.Int64(conn.Do("DBSIZE"))
+	return val, err
 }
 
-func (rc *RedisClient) LTrim(key string, start int, stop int) (string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	resp, err := redis.String(conn.Do("LTRIM", key, start, stop))
-	return resp, err
+// ServerTime returns the current time of the server, with a precision of
+// a microsecond
+func (rc *RedisClient) ServerTime() (time.Time, error) {
+	return rc.ServerTimeCtx(context.Background())
 }
 
-func (rc *RedisClient) RPop(key string) (string, error) {
-	conn := rc.pool.Get()
+// ServerTimeCtx is like ServerTime but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ServerTimeCtx(ctx context.Context) (time.Time, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	resp, err := redis.String(conn.Do("RPOP", key))
-	return resp, err
+	// the reply is the unix time in seconds and the microseconds elapsed in the second
+	val, err := redis.Int64s(conn.Do("TIME"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(val) != 2 {
+		return time.Time{}, fmt.Errorf("redisutil: unexpected TIME reply %v", val)
+	}
+	return time.Unix(val[0], val[1]*int64(time.Microsecond)), nil
 }
 
-func (rc *RedisClient) RPush(key string, value ...interface{}) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	args := append([]interface{}{key}, value...)
-	resp, err := redis.Int(conn.Do("RPUSH", args...))
-	return resp, err
+// ClockSkew returns how far the clock of the server is ahead of the local
+// clock, negative if it is behind. the local time is taken halfway through
+// the round trip of TIME, so the skew is accurate to half the round trip
+func (rc *RedisClient) ClockSkew() (time.Duration, error) {
+	return rc.ClockSkewCtx(context.Background())
 }
 
-func (rc *RedisClient) RPushX(key string, value ...interface{}) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	args := append([]interface{}{key}, value...)
-	resp, err := redis.Int(conn.Do("RPUSHX", args...))
-	return resp, err
+// ClockSkewCtx is like ClockSkew but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ClockSkewCtx(ctx context.Context) (time.Duration, error) {
+	start := time.Now()
+	serverTime, err := rc.ServerTimeCtx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	local := start.Add(time.Since(start) / 2)
+	return serverTime.Sub(local), nil
 }
 
-func (rc *RedisClient) RPopLPush(source string, destination string) (string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	resp, err := redis.String(conn.Do("RPOPLPUSH", source, destination))
-	return resp, err
+// RandomKey returns a random key of the database, ErrNil if it is empty
+func (rc *RedisClient) RandomKey() (string, error) {
+	return rc.RandomKeyCtx(context.Background())
 }
 
-func (rc *RedisClient) BLPop(key ...interface{}) (map[string]string, error) {
-	conn := rc.pool.Get()
+// RandomKeyCtx is like RandomKey but honors the deadline and cancellation of ctx
+func (rc *RedisClient) RandomKeyCtx(ctx context.Context) (string, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.StringMap(conn.Do("BLPOP", key, defaultTimeout))
+	val, err := redis.String(conn.Do("RANDOMKEY"))
 	return val, err
 }
 
-// BRPop returns the last element in the list and delete it. It blocks if the
-// list is empty
-func (rc *RedisClient) BRPop(key ...interface{}) (map[string]string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.StringMap(conn.Do("BRPOP", key, defaultTimeout))
-	return val, err
+// SampleKeys returns up to n distinct random keys of the database, to inspect
+// its content without a full scan. fewer keys are returned if the database
+// has less than n keys, or if RANDOMKEY keeps returning keys already sampled.
+// returns empty if n is not positive
+func (rc *RedisClient) SampleKeys(n int) ([]string, error) {
+	return rc.SampleKeysCtx(context.Background(), n)
 }
 
-func (rc *RedisClient) BRPopLPush(source string, destination string) (string, error) {
-	conn := rc.pool.Get()
+// SampleKeysCtx is like SampleKeys but honors the deadline and cancellation of ctx
+func (rc *RedisClient) SampleKeysCtx(ctx context.Context, n int) ([]string, error) {
+	if n <= 0 {
+		return []string{}, nil
+	}
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.String(conn.Do("BRPOPLPUSH", source, destination))
-	return val, err
+	size, err := redis.Int(conn.Do("DBSIZE"))
+	if err != nil {
+		return nil, err
+	}
+	if n > size {
+		n = size
+	}
+	keys := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	// bounds the calls, as the last keys are hard to hit in a small database
+	for attempts := 0; len(keys) < n && attempts < 10*n; attempts++ {
+		key, err := redis.String(conn.Do("RANDOMKEY"))
+		if err == ErrNil {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, nil
 }
 
-func (rc *RedisClient) LIndex(key string, index int) (string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.String(conn.Do("LINDEX", key, index))
-	return val, err
+// ObjectEncoding returns the internal encoding of the value of key, like
+// "listpack" or "hashtable" for a hash. returns ErrNil if key does not exists
+func (rc *RedisClient) ObjectEncoding(key string) (string, error) {
+	return rc.ObjectEncodingCtx(context.Background(), key)
 }
 
-func (rc *RedisClient) LInsertBefore(key string, pivot string, value string) (int, error) {
-	conn := rc.pool.Get()
+// ObjectEncodingCtx is like ObjectEncoding but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ObjectEncodingCtx(ctx context.Context, key string) (string, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.Int(conn.Do("LINSERT", key, "BEFORE", pivot, value))
+	val, err := redis.String(conn.Do("OBJECT", "ENCODING", key))
 	return val, err
 }
 
-func (rc *RedisClient) LInsertAfter(key string, pivot string, value string) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Int(conn.Do("LINSERT", key, "AFTER", pivot, value))
-	return val, err
+// MemoryUsage returns the count of bytes used by key and its value in memory,
+// returns -1 and ErrNil if key does not exists
+func (rc *RedisClient) MemoryUsage(key string) (int64, error) {
+	return rc.MemoryUsageCtx(context.Background(), key)
 }
 
-func (rc *RedisClient) LLen(key string) (int, error) {
-	conn := rc.pool.Get()
+// MemoryUsageCtx is like MemoryUsage but honors the deadline and cancellation of ctx
+func (rc *RedisClient) MemoryUsageCtx(ctx context.Context, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.Int(conn.Do("LLEN", key))
+	val, err := redis.Int64(conn.Do("MEMORY", "USAGE", key))
+	if err == ErrNil {
+		return -1, err
+	}
 	return val, err
 }
 
-func (rc *RedisClient) LPop(key string) (string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.String(conn.Do("LPOP", key))
-	return val, err
+// ObjectIdleTime returns the count of seconds since key was last read or
+// written, the idle time used by the LRU eviction policies.
+// returns -1 and ErrNil if key does not exists
+func (rc *RedisClient) ObjectIdleTime(key string) (int64, error) {
+	return rc.ObjectIdleTimeCtx(context.Background(), key)
 }
 
-// ****************** set ***********************
+// ObjectIdleTimeCtx is like ObjectIdleTime but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ObjectIdleTimeCtx(ctx context.Context, key string) (int64, error) {
+	return rc.objectInt(ctx, "IDLETIME", key)
+}
 
-// SAdd add one or multiple members in to the set, creates a new set with key
-// if it does not exists
-func (rc *RedisClient) SAdd(key string, member ...interface{}) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	args := append([]interface{}{key}, member...)
-	val, err := redis.Int(conn.Do("SADD", args...))
-	return val, err
+// ObjectFreq returns the logarithmic access frequency counter of key used by
+// the LFU eviction policies. the server replies an error unless its
+// maxmemory-policy is an LFU policy, which is returned as is.
+// returns -1 and ErrNil if key does not exists
+func (rc *RedisClient) ObjectFreq(key string) (int64, error) {
+	return rc.ObjectFreqCtx(context.Background(), key)
 }
 
-// SCard returns cardinality of the set(count of elements).
-// returns 0 when set does not exist
-func (rc *RedisClient) SCard(key string) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Int(conn.Do("SCARD", key))
-	return val, err
+// ObjectFreqCtx is like ObjectFreq but honors the deadline and cancellation of ctx
+func (rc *RedisClient) ObjectFreqCtx(ctx context.Context, key string) (int64, error) {
+	return rc.objectInt(ctx, "FREQ", key)
 }
 
-// SPop return and remove a random element from the set,
-// use SRandMember if the element should not be removed
-func (rc *RedisClient) SPop(key string) (string, error) {
-	conn := rc.pool.Get()
+// objectInt returns the integer reply of the OBJECT subcommand for key,
+// -1 and ErrNil if key does not exists
+func (rc *RedisClient) objectInt(ctx context.Context, subcommand, key string) (int64, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.String(conn.Do("SPOP", key))
+	val, err := redis.Int64(conn.Do("OBJECT", subcommand, key))
+	if err == ErrNil {
+		return -1, err
+	}
 	return val, err
 }
 
-// SRandMember returns random count elements from set
-func (rc *RedisClient) SRandMember(key string, count int) ([]string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Strings(conn.Do("SRANDMEMBER", key, count))
-	return val, err
+// Wait blocks until the writes sent before on the connection are acknowledged
+// by numReplicas replicas or timeout elapses, 0 blocks indefinitely. returns
+// the count of replicas which acknowledged the writes. as the connection is
+// borrowed from the pool, use WAIT on the connection of GetConn to wait for
+// its own writes
+func (rc *RedisClient) Wait(numReplicas int, timeout time.Duration) (int64, error) {
+	return rc.WaitCtx(context.Background(), numReplicas, timeout)
 }
 
-// SRem remove multiple elements from set
-func (rc *RedisClient) SRem(key string, member ...interface{}) (int, error) {
-	conn := rc.pool.Get()
+// WaitCtx is like Wait but honors the deadline and cancellation of ctx
+func (rc *RedisClient) WaitCtx(ctx context.Context, numReplicas int, timeout time.Duration) (int64, error) {
+	ms := int64(timeout / time.Millisecond)
+	if ms == 0 && timeout > 0 {
+		ms = 1
+	}
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	args := append([]interface{}{key}, member...)
-	val, err := redis.Int(conn.Do("SREM", args...))
+	val, err := redis.Int64(conn.Do("WAIT", numReplicas, ms))
 	return val, err
 }
 
-func (rc *RedisClient) SDiff(key ...interface{}) ([]string, error) {
-	conn := rc.pool.Get()
+// Select switches all connections of rc to the database db, the connections
+// in use are switched when they are borrowed next time.
+// rc is shared per address, so prefer an address with the database index,
+// like "redis://10.0.1.11:6379/1", unless rc is used by a single user
+func (rc *RedisClient) Select(db int) error {
+	if db < 0 {
+		return errors.New("redisutil: Select database must not be negative")
+	}
+	if rc.parent != nil {
+		return rc.parent.Select(db)
+	}
+	prev := atomic.SwapInt32(&rc.db, int32(db))
+	conn := rc.getConn()
 	defer conn.Close()
-	val, err := redis.Strings(conn.Do("SDIFF", key...))
-	return val, err
+	if _, err := conn.Do("PING"); err != nil {
+		atomic.CompareAndSwapInt32(&rc.db, int32(db), prev)
+		return err
+	}
+	return nil
 }
 
-func (rc *RedisClient) SDiffStore(destination string, key ...interface{}) (int, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	args := append([]interface{}{destination}, key...)
-	val, err := redis.Int(conn.Do("SDIFFSTORE", args...))
-	return val, err
+// selectConnDB switches c to the database of Select if needed
+func (rc *RedisClient) selectConnDB(c *clientConn) error {
+	db := atomic.LoadInt32(&rc.db)
+	if db < 0 || c.db == db {
+		return nil
+	}
+	if _, err := c.Do("SELECT", db); err != nil {
+		return err
+	}
+	c.db = db
+	return nil
 }
 
-func (rc *RedisClient) SInter(key ...interface{}) ([]string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Strings(conn.Do("SINTER", key...))
-	return val, err
+// FlushDB removes all keys of the current database
+func (rc *RedisClient) FlushDB() error {
+	return rc.FlushDBCtx(context.Background())
 }
 
-func (rc *RedisClient) SInterStore(destination string, key ...interface{}) (int, error) {
-	conn := rc.pool.Get()
+// FlushDBCtx is like FlushDB but honors the deadline and cancellation of ctx
+func (rc *RedisClient) FlushDBCtx(ctx context.Context) error {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	args := append([]interface{}{destination}, key...)
-	val, err := redis.Int(conn.Do("SINTERSTORE", args...))
-	return val, err
+	_, err := conn.Do("FLUSHDB")
+	return err
 }
 
-func (rc *RedisClient) SIsMember(key string, member string) (bool, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Bool(conn.Do("SISMEMBER", key, member))
-	return val, err
+// FlushDBAsync removes all keys of the current database like FlushDB, the
+// server frees their memory in the background
+func (rc *RedisClient) FlushDBAsync() error {
+	return rc.FlushDBAsyncCtx(context.Background())
 }
 
-func (rc *RedisClient) SMembers(key string) ([]string, error) {
-	conn := rc.pool.Get()
+// FlushDBAsyncCtx is like FlushDBAsync but honors the deadline and cancellation of ctx
+func (rc *RedisClient) FlushDBAsyncCtx(ctx context.Context) error {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.Strings(conn.Do("SMEMBERS", key))
-	return val, err
+	_, err := conn.Do("FLUSHDB", "ASYNC")
+	return err
 }
 
-// smove is a atomic operate
-func (rc *RedisClient) SMove(source string, destination string, member string) (bool, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.Bool(conn.Do("SMOVE", source, destination, member))
-	return val, err
+// FlushAll removes all keys of all databases of the server
+func (rc *RedisClient) FlushAll() error {
+	return rc.FlushAllCtx(context.Background())
 }
 
-func (rc *RedisClient) SUnion(key ...interface{}) ([]string, error) {
-	conn := rc.pool.Get()
+// FlushAllCtx is like FlushAll but honors the deadline and cancellation of ctx
+func (rc *RedisClient) FlushAllCtx(ctx context.Context) error {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	val, err := redis.Strings(conn.Do("SUNION", key...))
-	return val, err
+	_, err := conn.Do("FLUSHALL")
+	return err
 }
 
-func (rc *RedisClient) SUnionStore(destination string, key ...interface{}) (int, error) {
-	conn := rc.pool.Get()
+// Do issues the command cmd with args on a connection from the pool and
+// returns the raw reply, for the commands which are not covered by RedisClient
+func (rc *RedisClient) Do(cmd string, args ...interface{}) (interface{}, error) {
+	return rc.DoWithContext(context.Background(), cmd, args...)
+}
+
+// DoWithContext is like Do but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DoWithContext(ctx context.Context, cmd string, args ...interface{}) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
 	defer conn.Close()
-	args := append([]interface{}{destination}, key...)
-	val, err := redis.Int(conn.Do("SUNIONSTORE", args))
-	return val, err
+	return conn.Do(cmd, args...)
 }
 
-// ****************** Global functions ***********************
+// DoInt64 is like Do but converts the reply to an int64
+func (rc *RedisClient) DoInt64(cmd string, args ...interface{}) (int64, error) {
+	return rc.DoInt64Ctx(context.Background(), cmd, args...)
+}
 
-// Ping tests the client is ready for use
-func (rc *RedisClient) Ping() (string, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	val, err := redis.String(conn.Do("PING"))
-	return val, err
+// DoInt64Ctx is like DoInt64 but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DoInt64Ctx(ctx context.Context, cmd string, args ...interface{}) (int64, error) {
+	return redis.Int64(rc.DoWithContext(ctx, cmd, args...))
 }
 
-// DBSize returns count of keys in the database
-func (rc *RedisClient) DBSize() (int64, error) {
-	conn := rc.pool.Get()
-	defer conn.Close()
+// DoString is like Do but converts the reply to a string
+func (rc *RedisClient) DoString(cmd string, args ...interface{}) (string, error) {
+	return rc.DoStringCtx(context.Background(), cmd, args...)
+}
 
-	val, err := redis.Int64(conn.Do("DBSIZE"))
-	return val, err
+// DoStringCtx is like DoString but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DoStringCtx(ctx context.Context, cmd string, args ...interface{}) (string, error) {
+	return redis.String(rc.DoWithContext(ctx, cmd, args...))
 }
 
-// FlushDB remove all data in the database
-// this command never fails
-func (rc *RedisClient) FlushDB() {
-	conn := rc.pool.Get()
-	defer conn.Close()
-	conn.Do("FLUSHALL")
+// DoStrings is like Do but converts the array reply to a []string
+func (rc *RedisClient) DoStrings(cmd string, args ...interface{}) ([]string, error) {
+	return rc.DoStringsCtx(context.Background(), cmd, args...)
+}
+
+// DoStringsCtx is like DoStrings but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DoStringsCtx(ctx context.Context, cmd string, args ...interface{}) ([]string, error) {
+	return redis.Strings(rc.DoWithContext(ctx, cmd, args...))
+}
+
+// DoBool is like Do but converts the reply to a bool, an integer reply is
+// true if it is not 0
+func (rc *RedisClient) DoBool(cmd string, args ...interface{}) (bool, error) {
+	return rc.DoBoolCtx(context.Background(), cmd, args...)
+}
+
+// DoBoolCtx is like DoBool but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DoBoolCtx(ctx context.Context, cmd string, args ...interface{}) (bool, error) {
+	return redis.Bool(rc.DoWithContext(ctx, cmd, args...))
+}
+
+// DoStringMap is like Do but converts the array reply of field/value pairs,
+// like the one of HGETALL, to a map[string]string
+func (rc *RedisClient) DoStringMap(cmd string, args ...interface{}) (map[string]string, error) {
+	return rc.DoStringMapCtx(context.Background(), cmd, args...)
+}
+
+// DoStringMapCtx is like DoStringMap but honors the deadline and cancellation of ctx
+func (rc *RedisClient) DoStringMapCtx(ctx context.Context, cmd string, args ...interface{}) (map[string]string, error) {
+	return redis.StringMap(rc.DoWithContext(ctx, cmd, args...))
 }
 
 // GetConn returns a connection from the pool,
 // user is responsible for closing this connection
 func (rc *RedisClient) GetConn() redis.Conn {
-	return rc.pool.Get()
+	conn := rc.getConn()
+	if threshold := rc.leakThreshold(); threshold > 0 {
+		conn = newLeakConn(conn, threshold)
+	}
+	return conn
 }
@@ -1,19 +1,2294 @@
 package redisutil
 
-/*
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
 )
 
-const redisServerURL = "redis://:123456@192.168.8.175:7001/0"
+// redisServerURL is the server used by tests which need a live redis,
+// it can be overridden by the DOTWEB_REDIS_URL environment variable
+var redisServerURL = "redis://127.0.0.1:6379/0"
 
-func TestRedisClient_Ping(t *testing.T) {
+func init() {
+	if url := os.Getenv("DOTWEB_REDIS_URL"); url != "" {
+		redisServerURL = url
+	}
+}
+
+// newTestClient returns the client of redisServerURL,
+// the test is skipped if the server is not reachable
+func newTestClient(t *testing.T) *RedisClient {
 	redisClient := GetRedisClient(redisServerURL)
+	if _, err := redisClient.Ping(); err != nil {
+		t.Skipf("redis server %s not available: %v", redisServerURL, err)
+	}
+	return redisClient
+}
+
+// skipIfUnsupported skips the test if err reports a command unknown to the
+// server, e.g. a command introduced by a newer redis version
+func skipIfUnsupported(t *testing.T, err error) {
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown") {
+		t.Skipf("command not supported by redis server: %v", err)
+	}
+}
+
+func TestRedisClient_Ping(t *testing.T) {
+	redisClient := newTestClient(t)
 	val, err := redisClient.Ping()
-	if err != nil {
-		t.Error(err)
-	} else {
-		t.Log(val)
+	test.Nil(t, err)
+	test.Equal(t, "PONG", val)
+}
+
+func TestRegisteredAddresses(t *testing.T) {
+	addresses := []string{
+		"redis://127.0.0.1:16379/1",
+		"redis://127.0.0.1:16379/2",
+		"redis://127.0.0.1:16379/3",
+	}
+	for _, address := range addresses {
+		GetRedisClient(address)
+	}
+	// requesting an existing address must not register it twice
+	GetRedisClient(addresses[0])
+	// the clients created with a config are listed by their address
+	GetRedisClientWithConfig(addresses[1], PoolConfig{MaxIdle: 3})
+	GetRedisClientTLS(addresses[2], nil)
+
+	registered := RegisteredAddresses()
+	test.Equal(t, true, len(registered) <= ClientCount())
+	for _, r := range registered {
+		test.Equal(t, false, strings.Contains(r, "#"))
+	}
+	for _, address := range addresses {
+		found := 0
+		for _, r := range registered {
+			if r == address {
+				found++
+			}
+		}
+		test.Equal(t, 1, found)
+	}
+
+	// the snapshot must be a copy of the live map
+	registered[0] = "modified"
+	test.NotEqual(t, "modified", RegisteredAddresses()[0])
+}
+
+func TestClientCount(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+	defer RemoveRedisClient(server.URL())
+
+	// each config of an address is a client of its own, of the same address
+	count, addresses := ClientCount(), len(RegisteredAddresses())
+	GetRedisClientWithConfig(server.URL(), PoolConfig{MaxActive: 2})
+	GetRedisClientWithConfig(server.URL(), PoolConfig{MaxActive: 3})
+	test.Equal(t, count+2, ClientCount())
+	test.Equal(t, addresses+1, len(RegisteredAddresses()))
+}
+
+func TestRedisClient_HGetAllWithTTLMulti(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:hash:1", "dotweb:test:hash:2", "dotweb:test:hash:missing"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+	test.Nil(t, redisClient.HSet(keys[0], "name", "dotweb"))
+	test.Nil(t, redisClient.HSet(keys[0], "lang", "go"))
+	test.Nil(t, redisClient.HSet(keys[1], "name", "redis"))
+	_, err := redisClient.Expire(keys[1], 100)
+	test.Nil(t, err)
+
+	result, err := redisClient.HGetAllWithTTLMulti(keys...)
+	test.Nil(t, err)
+	test.Equal(t, 3, len(result))
+	test.Equal(t, map[string]string{"name": "dotweb", "lang": "go"}, result[keys[0]].Fields)
+	test.Equal(t, int64(-1), result[keys[0]].TTL)
+	test.Equal(t, map[string]string{"name": "redis"}, result[keys[1]].Fields)
+	if ttl := result[keys[1]].TTL; ttl <= 0 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+	test.Equal(t, 0, len(result[keys[2]].Fields))
+	test.Equal(t, int64(-2), result[keys[2]].TTL)
+}
+
+func TestRedisClient_HGetAllMany(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:hashes:1", "dotweb:test:hashes:2", "dotweb:test:hashes:missing"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+	test.Nil(t, redisClient.HSet(keys[0], "name", "dotweb"))
+	test.Nil(t, redisClient.HSet(keys[0], "lang", "go"))
+	test.Nil(t, redisClient.HSet(keys[1], "name", "redis"))
+
+	result, err := redisClient.HGetAllMany(keys...)
+	test.Nil(t, err)
+	test.Equal(t, map[string]map[string]string{
+		keys[0]: {"name": "dotweb", "lang": "go"},
+		keys[1]: {"name": "redis"},
+		keys[2]: {},
+	}, result)
+
+	result, err = redisClient.HGetAllMany()
+	test.Nil(t, err)
+	test.Equal(t, 0, len(result))
+}
+
+func TestRedisClient_GetSetBytes(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:bytes"
+	defer redisClient.Del(key)
+
+	val := []byte{'d', 0x00, 0xff, 0xfe, 0x00, 'w', 0xc3, 0x28}
+	test.Nil(t, redisClient.SetBytes(key, val))
+	reply, err := redisClient.GetBytes(key)
+	test.Nil(t, err)
+	test.Equal(t, val, reply)
+
+	reply, err = redisClient.GetBytes("dotweb:test:bytes:missing")
+	test.Equal(t, ErrNil, err)
+	test.Nil(t, reply)
+}
+
+func TestRedisClient_CommandGetKeys(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys, err := redisClient.CommandGetKeys("MSET", "k1", "v1", "k2", "v2", "k3", "v3")
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, []string{"k1", "k2", "k3"}, keys)
+
+	keys, err = redisClient.CommandGetKeys("SET", "k1", "v1", "EX", 10)
+	test.Nil(t, err)
+	test.Equal(t, []string{"k1"}, keys)
+}
+
+func TestRedisClient_DrainList(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:drain"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	items, err := redisClient.DrainList(key)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(items))
+
+	_, err = redisClient.RPush(key, "a", "b", "c")
+	test.Nil(t, err)
+	items, err = redisClient.DrainList(key)
+	test.Nil(t, err)
+	test.Equal(t, []string{"a", "b", "c"}, items)
+
+	const writers, perWriter = 4, 200
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				redisClient.LPush(key, fmt.Sprintf("%d-%d", w, i))
+			}
+		}(w)
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	seen := make(map[string]int)
+	for finished := false; !finished; {
+		select {
+		case <-done:
+			finished = true
+		default:
+		}
+		items, err := redisClient.DrainList(key)
+		test.Nil(t, err)
+		for _, item := range items {
+			seen[item]++
+		}
+	}
+	test.Equal(t, writers*perWriter, len(seen))
+	for item, count := range seen {
+		if count != 1 {
+			t.Errorf("item %s drained %d times", item, count)
+		}
+	}
+}
+
+func TestGetRedisClientACL(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if len(args) == 3 && args[1] == "alice" && args[2] == "secret" {
+				return "OK"
+			}
+			return errors.New("WRONGPASS invalid username-password pair")
+		case "PING":
+			return "PONG"
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClientACL(server.URL(), "alice", "secret", PoolConfig{MaxActive: 2})
+	test.Equal(t, redisClient, GetRedisClientACL(server.URL(), "alice", "secret", PoolConfig{}))
+	test.Equal(t, 2, redisClient.pool.MaxActive)
+	val, err := redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, "PONG", val)
+	test.Equal(t, []string{"AUTH", "alice", "secret"}, server.Commands()[0])
+
+	_, err = GetRedisClientACL(server.URL(), "bob", "secret", PoolConfig{}).Ping()
+	test.NotNil(t, err)
+}
+
+func TestGetRedisClientWithAuth(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if len(args) == 3 && args[1] == "alice" && args[2] == "secret" {
+				return "OK"
+			}
+			if len(args) == 2 && args[1] == "legacy" {
+				return "OK"
+			}
+			return errors.New("WRONGPASS invalid username-password pair")
+		case "PING":
+			return "PONG"
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	_, err := GetRedisClientWithAuth(server.URL(), "alice", "secret").Ping()
+	test.Nil(t, err)
+	test.Equal(t, []string{"AUTH", "alice", "secret"}, server.Commands()[0])
+
+	// without username, only the password is sent
+	_, err = GetRedisClientWithAuth(server.URL(), "", "legacy").Ping()
+	test.Nil(t, err)
+	test.Equal(t, []string{"AUTH", "legacy"}, server.Commands()[2])
+
+	_, err = GetRedisClientWithAuth(server.URL(), "bob", "secret").Ping()
+	test.Contains(t, "WRONGPASS", err.Error())
+}
+
+// tlsStub accepts connections and records the server name of the TLS
+// client hellos, the handshakes are then rejected so no certificate is needed
+func tlsStub(t *testing.T) (ln net.Listener, serverNames <-chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	test.Nil(t, err)
+	names := make(chan string, 16)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			tls.Server(conn, &tls.Config{
+				GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+					names <- hello.ServerName
+					return nil, errors.New("stub")
+				},
+			}).Handshake()
+			conn.Close()
+		}
+	}()
+	return ln, names
+}
+
+func TestGetRedisClientTLS(t *testing.T) {
+	ln, serverNames := tlsStub(t)
+	defer ln.Close()
+	addr := ln.Addr().String()
+	redisClient := GetRedisClientTLS("redis://"+addr, &tls.Config{ServerName: "redis.example.com"})
+	test.Equal(t, redisClient, GetRedisClientTLS("redis://"+addr, nil))
+	test.NotEqual(t, redisClient, GetRedisClient("redis://"+addr))
+	_, err := redisClient.Ping()
+	test.NotNil(t, err)
+	select {
+	case name := <-serverNames:
+		test.Equal(t, "redis.example.com", name)
+	case <-time.After(time.Second):
+		t.Fatal("no TLS handshake")
+	}
+
+	// rediss:// uses TLS with the host as server name
+	_, port, _ := net.SplitHostPort(addr)
+	_, err = GetRedisClient("rediss://localhost:" + port).Ping()
+	test.NotNil(t, err)
+	select {
+	case name := <-serverNames:
+		test.Equal(t, "localhost", name)
+	case <-time.After(time.Second):
+		t.Fatal("no TLS handshake")
+	}
+}
+
+func TestRedisClient_BitPos(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:bitpos"
+	defer redisClient.Del(key)
+
+	// 0x00 0x0f 0xff: bits 12-23 are set
+	test.Nil(t, redisClient.SetBytes(key, []byte{0x00, 0x0f, 0xff}))
+	pos, err := redisClient.BitPos(key, 1, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, int64(12), pos)
+	pos, err = redisClient.BitPos(key, 0, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), pos)
+
+	// search from the second byte
+	pos, err = redisClient.BitPos(key, 0, 1, 2)
+	test.Nil(t, err)
+	test.Equal(t, int64(8), pos)
+	pos, err = redisClient.BitPos(key, 1, 2, 2)
+	test.Nil(t, err)
+	test.Equal(t, int64(16), pos)
+	pos, err = redisClient.BitPos(key, 0, 2, 2)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), pos)
+
+	_, err = redisClient.BitPos(key, 2, 0, -1)
+	test.NotNil(t, err)
+}
+
+func TestRedisClient_Bits(t *testing.T) {
+	redisClient := newTestClient(t)
+	key, other, dest := "dotweb:test:bits", "dotweb:test:bits:other", "dotweb:test:bits:dest"
+	defer redisClient.Del(key)
+	defer redisClient.Del(other)
+	defer redisClient.Del(dest)
+
+	for _, offset := range []int64{1, 7, 20} {
+		prev, err := redisClient.SetBit(key, offset, 1)
+		test.Nil(t, err)
+		test.Equal(t, int64(0), prev)
+	}
+	prev, err := redisClient.SetBit(key, 7, 1)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), prev)
+	bit, err := redisClient.GetBit(key, 20)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), bit)
+	bit, err = redisClient.GetBit(key, 1000)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), bit)
+	_, err = redisClient.SetBit(key, 0, 2)
+	test.NotNil(t, err)
+
+	count, err := redisClient.BitCount(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(3), count)
+	count, err = redisClient.BitCount(key, 0, 0)
+	test.Nil(t, err)
+	test.Equal(t, int64(2), count)
+	_, err = redisClient.BitCount(key, 0)
+	test.NotNil(t, err)
+
+	_, err = redisClient.SetBit(other, 7, 1)
+	test.Nil(t, err)
+	length, err := redisClient.BitOp("AND", dest, key, other)
+	test.Nil(t, err)
+	test.Equal(t, int64(3), length)
+	count, err = redisClient.BitCount(dest)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), count)
+}
+
+func TestRedisClient_WithDialHook(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "CLIENT", "PING":
+			return "OK"
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	var mu sync.Mutex
+	var calls []string
+	redisClient := GetRedisClient(server.URL()).
+		WithDialHook(func(conn redis.Conn) error {
+			mu.Lock()
+			calls = append(calls, "first")
+			mu.Unlock()
+			_, err := conn.Do("CLIENT", "SETNAME", "dotweb")
+			return err
+		}).
+		WithDialHook(func(conn redis.Conn) error {
+			mu.Lock()
+			calls = append(calls, "second")
+			mu.Unlock()
+			return nil
+		})
+
+	// two connections in use at the same time are both dialed
+	conn1 := redisClient.GetConn()
+	conn2 := redisClient.GetConn()
+	test.Nil(t, conn1.Err())
+	test.Nil(t, conn2.Err())
+	conn1.Close()
+	conn2.Close()
+	// an idle connection is reused without running the hooks
+	_, err := redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, []string{"first", "second", "first", "second"}, calls)
+	test.Equal(t, 2, server.Conns())
+	test.Equal(t, []string{"CLIENT", "SETNAME", "dotweb"}, server.Commands()[0])
+}
+
+func TestRedisClient_WithDialHookError(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	hookErr := errors.New("rejected")
+	redisClient := GetRedisClient(server.URL()).WithDialHook(func(conn redis.Conn) error {
+		return hookErr
+	})
+	_, err := redisClient.Ping()
+	test.Equal(t, hookErr, err)
+	test.Equal(t, 0, len(server.Commands()))
+}
+
+func TestGetRedisClientWithConfig(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	cfg := PoolConfig{MaxIdle: 1, MaxActive: 2}
+	redisClient := GetRedisClientWithConfig(server.URL(), cfg)
+	test.Equal(t, redisClient, GetRedisClientWithConfig(server.URL(), cfg))
+	test.NotEqual(t, redisClient, GetRedisClient(server.URL()))
+	test.Equal(t, GetRedisClient(server.URL()), GetRedisClientWithConfig(server.URL(), PoolConfig{}))
+
+	conn1 := redisClient.GetConn()
+	conn2 := redisClient.GetConn()
+	test.Nil(t, conn1.Err())
+	test.Nil(t, conn2.Err())
+	conn3 := redisClient.GetConn()
+	test.Equal(t, redis.ErrPoolExhausted, conn3.Err())
+	conn3.Close()
+	test.Equal(t, 2, redisClient.pool.Stats().ActiveCount)
+
+	conn1.Close()
+	conn2.Close()
+	stats := redisClient.pool.Stats()
+	test.Equal(t, 1, stats.ActiveCount)
+	test.Equal(t, 1, stats.IdleCount)
+}
+
+func TestRedisClient_Stats(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClientWithConfig(server.URL(), PoolConfig{MaxActive: 2, Wait: true})
+	conn1 := redisClient.GetConn()
+	conn2 := redisClient.GetConn()
+	test.Nil(t, conn1.Err())
+	test.Nil(t, conn2.Err())
+	stats := redisClient.Stats()
+	test.Equal(t, 2, stats.ActiveCount)
+	test.Equal(t, 0, stats.IdleCount)
+	test.Equal(t, 2, stats.MaxActive)
+	test.Equal(t, int64(0), stats.WaitCount)
+	test.Equal(t, stats, AllStats()[poolClientKey(server.URL(), PoolConfig{MaxActive: 2, Wait: true}.withDefaults())])
+
+	// the pool is exhausted, so the next get waits for a connection
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		conn1.Close()
+	}()
+	conn3 := redisClient.GetConn()
+	test.Nil(t, conn3.Err())
+	stats = redisClient.Stats()
+	test.Equal(t, int64(1), stats.WaitCount)
+	if stats.WaitDuration < 10*time.Millisecond {
+		t.Errorf("unexpected wait duration %s", stats.WaitDuration)
+	}
+
+	conn2.Close()
+	conn3.Close()
+	stats = redisClient.Stats()
+	test.Equal(t, 2, stats.ActiveCount)
+	test.Equal(t, 2, stats.IdleCount)
+}
+
+func TestGetRedisClientWithConfigMaxConnLifetime(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClientWithConfig(server.URL(), PoolConfig{MaxConnLifetime: 50 * time.Millisecond})
+	_, err := redisClient.Ping()
+	test.Nil(t, err)
+	_, err = redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, 1, server.Conns())
+
+	time.Sleep(100 * time.Millisecond)
+	_, err = redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, 2, server.Conns())
+	test.Equal(t, 1, redisClient.pool.Stats().ActiveCount)
+}
+
+func TestGetRedisClientWithConfigPingIdleAfter(t *testing.T) {
+	var mu sync.Mutex
+	pingFails := false
+	server := newFakeServer(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		if strings.ToUpper(args[0]) == "PING" && pingFails {
+			return errors.New("ERR stale connection")
+		}
+		return "OK"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClientWithConfig(server.URL(), PoolConfig{PingIdleAfter: 20 * time.Millisecond})
+	_, err := redisClient.Set("key", "value")
+	test.Nil(t, err)
+	_, err = redisClient.Set("key", "value")
+	test.Nil(t, err)
+	test.Equal(t, 2, len(server.Commands()))
+
+	// the idle connection is pinged when borrowed
+	time.Sleep(50 * time.Millisecond)
+	_, err = redisClient.Set("key", "value")
+	test.Nil(t, err)
+	test.Equal(t, []string{"PING"}, server.Commands()[2])
+	test.Equal(t, 1, server.Conns())
+
+	// a connection failing the ping is replaced
+	time.Sleep(50 * time.Millisecond)
+	mu.Lock()
+	pingFails = true
+	mu.Unlock()
+	_, err = redisClient.Set("key", "value")
+	test.Nil(t, err)
+	test.Equal(t, 2, server.Conns())
+	test.Equal(t, 1, redisClient.pool.Stats().ActiveCount)
+}
+
+func TestGetRedisClientWithConfigReadTimeout(t *testing.T) {
+	// the server accepts the connections but never replies
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	test.Nil(t, err)
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go io.Copy(ioutil.Discard, conn)
+		}
+	}()
+
+	redisClient := GetRedisClientWithConfig("redis://"+ln.Addr().String(), PoolConfig{ReadTimeout: 100 * time.Millisecond})
+	defer redisClient.Close()
+	start := time.Now()
+	_, err = redisClient.Ping()
+	elapsed := time.Since(start)
+	test.NotNil(t, err)
+	if elapsed < 100*time.Millisecond || elapsed > time.Second {
+		t.Fatalf("Ping timed out after %s, want 100ms", elapsed)
+	}
+}
+
+func TestGetRedisClientWithConfigReadTimeoutBlocking(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		if strings.ToUpper(args[0]) == "BLPOP" {
+			time.Sleep(300 * time.Millisecond)
+			return []interface{}{[]byte(args[1]), []byte("value")}
+		}
+		return "OK"
+	})
+	defer server.Close()
+
+	// BLPOP blocks longer than the read timeout
+	redisClient := GetRedisClientWithConfig(server.URL(), PoolConfig{ReadTimeout: 100 * time.Millisecond})
+	defer redisClient.Close()
+	key, val, err := redisClient.BLPopN(time.Second, "queue")
+	test.Nil(t, err)
+	test.Equal(t, "queue", key)
+	test.Equal(t, "value", val)
+}
+
+func TestGetRedisClientWithConfigReadTimeoutSubscribe(t *testing.T) {
+	redisClient := newTestClient(t)
+	subscriber := GetRedisClientWithConfig(redisServerURL, PoolConfig{ReadTimeout: 50 * time.Millisecond})
+	channel := "dotweb:test:readtimeout"
+	s, err := subscriber.Subscribe(channel)
+	test.Nil(t, err)
+	defer s.Close()
+
+	// the subscription waits longer than the read timeout
+	time.Sleep(200 * time.Millisecond)
+	publish(t, redisClient, channel, "message")
+	msg, err := s.ReceiveMessage()
+	test.Nil(t, err)
+	test.Equal(t, Message{Channel: channel, Payload: "message"}, msg)
+}
+
+func TestRedisClient_MGetMSet(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:mset:1", "dotweb:test:mset:missing", "dotweb:test:mset:2", "dotweb:test:mset:3"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+
+	test.Nil(t, redisClient.MSet(map[string]interface{}{
+		keys[0]: "one",
+		keys[2]: 2,
+		keys[3]: "three",
+	}))
+	values, err := redisClient.MGet(keys...)
+	test.Nil(t, err)
+	test.Equal(t, []string{"one", "", "2", "three"}, values)
+
+	values, err = redisClient.MGet(keys[3], keys[1], keys[0])
+	test.Nil(t, err)
+	test.Equal(t, []string{"three", "", "one"}, values)
+
+	values, err = redisClient.MGet()
+	test.Nil(t, err)
+	test.Equal(t, 0, len(values))
+}
+
+func TestRedisClient_MGetPipelined(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := make([]string, 3000)
+	items := make(map[string]interface{}, len(keys))
+	for i := range keys {
+		keys[i] = "dotweb:test:mgetpipe:" + strconv.Itoa(i)
+		if i%7 != 0 {
+			items[keys[i]] = i
+		}
+	}
+	defer redisClient.Del(keys...)
+	redisClient.Del(keys...)
+	test.Nil(t, redisClient.MSet(items))
+
+	values, err := redisClient.MGetPipelined(keys, 128)
+	test.Nil(t, err)
+	test.Equal(t, len(keys), len(values))
+	for i, key := range keys {
+		val, err := redisClient.Get(key)
+		if err == ErrNil {
+			val = ""
+		}
+		test.Equal(t, val, values[i])
+	}
+	test.Equal(t, "", values[0])
+	test.Equal(t, "2999", values[2999])
+
+	values, err = redisClient.MGetPipelined(keys[:10], 0)
+	test.Nil(t, err)
+	test.Equal(t, []string{"", "1", "2", "3", "4", "5", "6", "", "8", "9"}, values)
+	values, err = redisClient.MGetPipelined(nil, 10)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(values))
+}
+
+func TestRedisClient_MSetWithExpire(t *testing.T) {
+	redisClient := newTestClient(t)
+	items := make(map[string]interface{}, 300)
+	for i := 0; i < 300; i++ {
+		items["dotweb:test:msetex:"+strconv.Itoa(i)] = i
+	}
+	for key := range items {
+		defer redisClient.Del(key)
+	}
+
+	test.Nil(t, redisClient.MSetWithExpire(items, 100))
+	for key, value := range items {
+		val, err := redisClient.Get(key)
+		test.Nil(t, err)
+		test.Equal(t, strconv.Itoa(value.(int)), val)
+		ttl, err := redisClient.TTL(key)
+		test.Nil(t, err)
+		if ttl < 95 || ttl > 100 {
+			t.Fatalf("ttl of %s is %d, want about 100", key, ttl)
+		}
+	}
+	test.Nil(t, redisClient.MSetWithExpire(nil, 100))
+}
+
+func TestRedisClient_MSetWithExpireError(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		if args[1] == "b" {
+			return errors.New("OOM command not allowed when used memory > 'maxmemory'")
+		}
+		return "OK"
+	})
+	defer server.Close()
+
+	err := GetRedisClient(server.URL()).MSetWithExpire(map[string]interface{}{"a": 1, "b": 2, "c": 3}, 10)
+	test.NotNil(t, err)
+	test.Equal(t, "redisutil: MSetWithExpire failed for 1 of 3 keys: b: OOM command not allowed when used memory > 'maxmemory'", err.Error())
+	test.Equal(t, 1, server.Conns())
+	test.Equal(t, []string{"SET", "a", "1", "EX", "10"}, server.Commands()[0])
+	test.Equal(t, 3, len(server.Commands()))
+}
+
+func TestRedisClient_Do(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "SETEX":
+			return "OK"
+		case "GET":
+			return []byte("value")
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	reply, err := redisClient.Do("SETEX", "key", 10, "value")
+	test.Nil(t, err)
+	test.Equal(t, "OK", reply)
+	test.Equal(t, []string{"SETEX", "key", "10", "value"}, server.Commands()[0])
+	// the connection is returned to the pool
+	stats := redisClient.pool.Stats()
+	test.Equal(t, 1, stats.ActiveCount)
+	test.Equal(t, 1, stats.IdleCount)
+
+	reply, err = redisClient.DoWithContext(context.Background(), "GET", "key")
+	test.Nil(t, err)
+	test.Equal(t, []byte("value"), reply)
+	_, err = redisClient.Do("UNKNOWN")
+	test.NotNil(t, err)
+	test.Equal(t, 1, redisClient.pool.Stats().IdleCount)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = redisClient.DoWithContext(ctx, "GET", "key")
+	test.Equal(t, context.Canceled, err)
+	test.Equal(t, 1, server.Conns())
+}
+
+func TestRedisClient_DoTyped(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "INCR":
+			return int64(3)
+		case "GET":
+			if args[1] == "missing" {
+				return nil
+			}
+			return []byte("value")
+		case "KEYS":
+			return []interface{}{[]byte("a"), []byte("b")}
+		case "EXISTS":
+			return int64(1)
+		case "HGETALL":
+			return []interface{}{[]byte("field"), []byte("value")}
+		}
+		return nil
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	count, err := redisClient.DoInt64("INCR", "key")
+	test.Nil(t, err)
+	test.Equal(t, int64(3), count)
+	val, err := redisClient.DoString("GET", "key")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	keys, err := redisClient.DoStrings("KEYS", "*")
+	test.Nil(t, err)
+	test.Equal(t, []string{"a", "b"}, keys)
+	exists, err := redisClient.DoBool("EXISTS", "key")
+	test.Nil(t, err)
+	test.Equal(t, true, exists)
+	fields, err := redisClient.DoStringMap("HGETALL", "key")
+	test.Nil(t, err)
+	test.Equal(t, map[string]string{"field": "value"}, fields)
+
+	_, err = redisClient.DoString("GET", "missing")
+	test.Equal(t, ErrNil, err)
+	_, err = redisClient.DoInt64("GET", "key")
+	test.NotNil(t, err)
+
+	// a canceled context issues no command
+	issued := len(server.Commands())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = redisClient.DoInt64Ctx(ctx, "INCR", "key")
+	test.Equal(t, context.Canceled, err)
+	_, err = redisClient.DoStringMapCtx(ctx, "HGETALL", "key")
+	test.Equal(t, context.Canceled, err)
+	test.Equal(t, issued, len(server.Commands()))
+}
+
+func TestRedisClient_TTL(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:ttl"
+	defer redisClient.Del(key)
+
+	_, err := redisClient.SetWithExpire(key, "value", 100)
+	test.Nil(t, err)
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 90 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+	pttl, err := redisClient.PTTL(key)
+	test.Nil(t, err)
+	if pttl <= 90000 || pttl > 100000 {
+		t.Errorf("unexpected pttl %d", pttl)
+	}
+
+	_, err = redisClient.Set(key, "value")
+	test.Nil(t, err)
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), ttl)
+	pttl, err = redisClient.PTTL(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), pttl)
+
+	ttl, err = redisClient.TTL("dotweb:test:ttl:missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(-2), ttl)
+	pttl, err = redisClient.PTTL("dotweb:test:ttl:missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(-2), pttl)
+}
+
+func TestRedisClient_PersistExpireAt(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:persist"
+	defer redisClient.Del(key)
+
+	_, err := redisClient.SetWithExpire(key, "value", 100)
+	test.Nil(t, err)
+	ok, err := redisClient.Persist(key)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), ttl)
+	ok, err = redisClient.Persist(key)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+
+	ok, err = redisClient.ExpireAt(key, time.Now().Unix()+100)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 90 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+	ok, err = redisClient.ExpireAt("dotweb:test:persist:missing", time.Now().Unix()+100)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+}
+
+func TestRedisClient_IncrWithExpire(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:incrwithexpire"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	val, err := redisClient.IncrWithExpire(key, 100)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), val)
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 90 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+
+	// the following increments keep the expire of the first one
+	_, err = redisClient.Expire(key, 200)
+	test.Nil(t, err)
+	for i := int64(2); i <= 3; i++ {
+		val, err = redisClient.IncrWithExpire(key, 100)
+		test.Nil(t, err)
+		test.Equal(t, i, val)
+	}
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 190 || ttl > 200 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+}
+
+func TestRedisClient_ExpireWithFlag(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:expireflag"
+	defer redisClient.Del(key)
+
+	_, err := redisClient.SetWithExpire(key, "value", 100)
+	test.Nil(t, err)
+	// GT does not shorten the expire
+	ok, err := redisClient.ExpireWithFlag(key, 50, "GT")
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, true, ttl > 90 && ttl <= 100)
+
+	ok, err = redisClient.ExpireWithFlag(key, 200, "GT")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, true, ttl > 190 && ttl <= 200)
+
+	ok, err = redisClient.ExpireWithFlag(key, 300, "NX")
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	ok, err = redisClient.ExpireWithFlag(key, 150, "lt")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+
+	_, err = redisClient.ExpireWithFlag(key, 100, "GE")
+	test.NotNil(t, err)
+}
+
+func TestRedisClient_Type(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:type:string", "dotweb:test:type:list", "dotweb:test:type:set", "dotweb:test:type:hash"}
+	for _, key := range keys {
+		defer redisClient.Del(key)
+	}
+
+	_, err := redisClient.Set(keys[0], "value")
+	test.Nil(t, err)
+	_, err = redisClient.RPush(keys[1], "value")
+	test.Nil(t, err)
+	_, err = redisClient.SAdd(keys[2], "value")
+	test.Nil(t, err)
+	err = redisClient.HSet(keys[3], "field", "value")
+	test.Nil(t, err)
+	for i, expected := range []string{"string", "list", "set", "hash"} {
+		typ, err := redisClient.Type(keys[i])
+		test.Nil(t, err)
+		test.Equal(t, expected, typ)
+	}
+	typ, err := redisClient.Type("dotweb:test:type:missing")
+	test.Nil(t, err)
+	test.Equal(t, "none", typ)
+}
+
+func TestRedisClient_Rename(t *testing.T) {
+	redisClient := newTestClient(t)
+	src, dst, other := "dotweb:test:rename:src", "dotweb:test:rename:dst", "dotweb:test:rename:other"
+	defer redisClient.Del(src)
+	defer redisClient.Del(dst)
+	defer redisClient.Del(other)
+
+	_, err := redisClient.Set(src, "value")
+	test.Nil(t, err)
+	test.Nil(t, redisClient.Rename(src, dst))
+	exists, err := redisClient.Exists(src)
+	test.Nil(t, err)
+	test.Equal(t, false, exists)
+	val, err := redisClient.Get(dst)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	test.Equal(t, ErrNoSuchKey, redisClient.Rename(src, dst))
+
+	// RenameNX does not overwrite dst
+	_, err = redisClient.Set(other, "other")
+	test.Nil(t, err)
+	ok, err := redisClient.RenameNX(other, dst)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	val, err = redisClient.Get(dst)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	ok, err = redisClient.RenameNX(dst, src)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	_, err = redisClient.RenameNX(dst, src)
+	test.Equal(t, ErrNoSuchKey, err)
+}
+
+func TestRedisClient_SetOpts(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:setopts"
+	defer redisClient.Del(key)
+
+	test.Nil(t, redisClient.SetEX(key, "ex", 100))
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 90 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+	test.Nil(t, redisClient.PSetEX(key, "px", 100000))
+	pttl, err := redisClient.PTTL(key)
+	test.Nil(t, err)
+	if pttl <= 90000 || pttl > 100000 {
+		t.Errorf("unexpected pttl %d", pttl)
+	}
+
+	// NX fails when the key exists
+	ok, err := redisClient.SetOpts(key, "nx", SetOptions{NX: true})
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "px", val)
+
+	ok, err = redisClient.SetOpts(key, "xx", SetOptions{XX: true, PX: 50000})
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	pttl, err = redisClient.PTTL(key)
+	test.Nil(t, err)
+	if pttl <= 40000 || pttl > 50000 {
+		t.Errorf("unexpected pttl %d", pttl)
+	}
+
+	_, err = redisClient.Del(key)
+	test.Nil(t, err)
+	ok, err = redisClient.SetOpts(key, "xx", SetOptions{XX: true})
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	ok, err = redisClient.SetOpts(key, "nx", SetOptions{NX: true, EX: 100})
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	val, err = redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "nx", val)
+}
+
+func TestRedisClient_StringRange(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:strrange"
+	defer redisClient.Del(key)
+
+	length, err := redisClient.Append(key, "hello")
+	test.Nil(t, err)
+	test.Equal(t, int64(5), length)
+	length, err = redisClient.Append(key, " world")
+	test.Nil(t, err)
+	test.Equal(t, int64(11), length)
+	strLen, err := redisClient.StrLen(key)
+	test.Nil(t, err)
+	test.Equal(t, length, strLen)
+
+	val, err := redisClient.GetRange(key, 0, 4)
+	test.Nil(t, err)
+	test.Equal(t, "hello", val)
+	val, err = redisClient.GetRange(key, -5, -1)
+	test.Nil(t, err)
+	test.Equal(t, "world", val)
+
+	length, err = redisClient.SetRange(key, 6, "redis")
+	test.Nil(t, err)
+	test.Equal(t, int64(11), length)
+	val, err = redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "hello redis", val)
+
+	strLen, err = redisClient.StrLen("dotweb:test:strrange:missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), strLen)
+}
+
+func TestRedisClient_ExistsMany(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:exists:1", "dotweb:test:exists:2", "dotweb:test:exists:missing"}
+	redisClient.Del(keys...)
+	defer redisClient.Del(keys...)
+	test.Nil(t, redisClient.MSet(map[string]interface{}{keys[0]: 1, keys[1]: 2}))
+
+	count, err := redisClient.ExistsMany(keys...)
+	test.Nil(t, err)
+	test.Equal(t, int64(2), count)
+	// duplicates are counted
+	count, err = redisClient.ExistsMany(keys[0], keys[0], keys[2])
+	test.Nil(t, err)
+	test.Equal(t, int64(2), count)
+	count, err = redisClient.ExistsMany(keys[2])
+	test.Nil(t, err)
+	test.Equal(t, int64(0), count)
+	count, err = redisClient.ExistsMany()
+	test.Nil(t, err)
+	test.Equal(t, int64(0), count)
+}
+
+func TestRedisClient_DelUnlink(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:del:1", "dotweb:test:del:2", "dotweb:test:del:3"}
+	for _, key := range keys {
+		_, err := redisClient.Set(key, "value")
+		test.Nil(t, err)
+	}
+	count, err := redisClient.Del(keys[0], keys[1], "dotweb:test:del:missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(2), count)
+	count, err = redisClient.Del(keys[0])
+	test.Nil(t, err)
+	test.Equal(t, int64(0), count)
+	count, err = redisClient.Del()
+	test.Nil(t, err)
+	test.Equal(t, int64(0), count)
+
+	count, err = redisClient.Unlink(keys...)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), count)
+	exists, err := redisClient.Exists(keys[2])
+	test.Nil(t, err)
+	test.Equal(t, false, exists)
+}
+
+func TestRedisClient_HealthCheck(t *testing.T) {
+	redisClient := newTestClient(t)
+	test.Nil(t, redisClient.HealthCheck())
+
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "OK"
+	})
+	defer server.Close()
+	test.NotNil(t, GetRedisClient(server.URL()).HealthCheck())
+
+	// nothing listens on port 1, so the dial fails right away
+	start := time.Now()
+	test.NotNil(t, GetRedisClient("redis://127.0.0.1:1/0").HealthCheck())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("HealthCheck returned after %v", elapsed)
+	}
+}
+
+func TestRedisClient_Close(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	_, err := redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, 1, redisClient.pool.Stats().ActiveCount)
+
+	test.Nil(t, redisClient.Close())
+	test.Equal(t, 0, redisClient.pool.Stats().ActiveCount)
+	for _, address := range RegisteredAddresses() {
+		test.NotEqual(t, server.URL(), address)
+	}
+	_, err = redisClient.Ping()
+	test.Equal(t, ErrClientClosed, err)
+	test.Nil(t, redisClient.Close())
+
+	// a new client is created for the address
+	newClient := GetRedisClient(server.URL())
+	test.NotEqual(t, redisClient, newClient)
+	_, err = newClient.Ping()
+	test.Nil(t, err)
+}
+
+func TestRedisClient_Shutdown(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	_, err := redisClient.Ping()
+	test.Nil(t, err)
+	conn := redisClient.GetConn()
+	test.Nil(t, conn.Err())
+
+	// the connection in use is waited for
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = redisClient.Shutdown(ctx)
+	test.NotNil(t, err)
+	test.Contains(t, "1 connections still in use", err.Error())
+	_, err = redisClient.Ping()
+	test.Equal(t, ErrClientClosed, err)
+	_, err = conn.Do("PING")
+	test.Nil(t, err)
+
+	conn.Close()
+	test.Nil(t, redisClient.Shutdown(context.Background()))
+	test.Equal(t, 0, redisClient.pool.Stats().ActiveCount)
+}
+
+func TestRemoveRedisClient(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "PONG"
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	configClient := GetRedisClientWithConfig(server.URL(), PoolConfig{MaxActive: 2})
+	_, err := redisClient.Ping()
+	test.Nil(t, err)
+	_, err = configClient.Ping()
+	test.Nil(t, err)
+
+	count := ClientCount()
+	RemoveRedisClient(server.URL())
+	test.Equal(t, count-2, ClientCount())
+	test.Equal(t, 0, redisClient.pool.Stats().ActiveCount)
+	test.Equal(t, 0, configClient.pool.Stats().ActiveCount)
+	_, err = configClient.Ping()
+	test.Equal(t, ErrClientClosed, err)
+}
+
+func TestRedisClient_SortedSet(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:leaderboard"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	for member, score := range map[string]float64{"alice": 30, "bob": 10, "carol": 20, "dave": 0} {
+		added, err := redisClient.ZAdd(key, score, member)
+		test.Nil(t, err)
+		test.Equal(t, int64(1), added)
+	}
+	members, err := redisClient.ZRange(key, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"dave", "bob", "carol", "alice"}, members)
+
+	// bob overtakes alice
+	score, err := redisClient.ZIncrBy(key, 25, "bob")
+	test.Nil(t, err)
+	test.Equal(t, float64(35), score)
+	added, err := redisClient.ZAdd(key, 12.5, "carol")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), added)
+	members, err = redisClient.ZRange(key, -2, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"alice", "bob"}, members)
+
+	scores, err := redisClient.ZRangeWithScores(key, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, map[string]float64{"dave": 0, "carol": 12.5, "alice": 30, "bob": 35}, scores)
+
+	// a real zero score is not a missing member
+	score, err = redisClient.ZScore(key, "dave")
+	test.Nil(t, err)
+	test.Equal(t, float64(0), score)
+	_, err = redisClient.ZScore(key, "nobody")
+	test.Equal(t, ErrNil, err)
+
+	removed, err := redisClient.ZRem(key, "dave", "nobody", "carol")
+	test.Nil(t, err)
+	test.Equal(t, int64(2), removed)
+	members, err = redisClient.ZRange(key, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"alice", "bob"}, members)
+}
+
+func TestRedisClient_ZAddOpts(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:zaddopts"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	_, err := redisClient.ZAdd(key, 10, "alice")
+	test.Nil(t, err)
+	// GT only raises the score
+	changed, err := redisClient.ZAddOpts(key, ZAddOptions{GT: true, CH: true}, 5, "alice")
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), changed)
+	score, err := redisClient.ZScore(key, "alice")
+	test.Nil(t, err)
+	test.Equal(t, float64(10), score)
+	changed, err = redisClient.ZAddOpts(key, ZAddOptions{GT: true, CH: true}, 20, "alice")
+	test.Nil(t, err)
+	test.Equal(t, int64(1), changed)
+	score, err = redisClient.ZScore(key, "alice")
+	test.Nil(t, err)
+	test.Equal(t, float64(20), score)
+	// without CH only the added members are counted
+	changed, err = redisClient.ZAddOpts(key, ZAddOptions{GT: true}, 30, "alice")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), changed)
+
+	// NX never updates, XX never adds
+	added, err := redisClient.ZAddOpts(key, ZAddOptions{NX: true}, 1, "alice")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), added)
+	added, err = redisClient.ZAddOpts(key, ZAddOptions{XX: true}, 1, "bob")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), added)
+	members, err := redisClient.ZRange(key, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"alice"}, members)
+
+	_, err = redisClient.ZAddOpts(key, ZAddOptions{NX: true, GT: true}, 1, "alice")
+	test.NotNil(t, err)
+	_, err = redisClient.ZAddOpts(key, ZAddOptions{GT: true, LT: true}, 1, "alice")
+	test.NotNil(t, err)
+}
+
+func TestRedisClient_SortedSetRanks(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:zranks"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	for i, member := range []string{"a", "b", "c", "d", "e", "f"} {
+		_, err := redisClient.ZAdd(key, float64(i*10), member)
+		test.Nil(t, err)
+	}
+	count, err := redisClient.ZCard(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(6), count)
+
+	members, err := redisClient.ZRangeByScore(key, 10, 40, 0, 0)
+	test.Nil(t, err)
+	test.Equal(t, []string{"b", "c", "d", "e"}, members)
+	// pages of 2 members
+	members, err = redisClient.ZRangeByScore(key, math.Inf(-1), math.Inf(1), 0, 2)
+	test.Nil(t, err)
+	test.Equal(t, []string{"a", "b"}, members)
+	members, err = redisClient.ZRangeByScore(key, math.Inf(-1), math.Inf(1), 4, 2)
+	test.Nil(t, err)
+	test.Equal(t, []string{"e", "f"}, members)
+	members, err = redisClient.ZRangeByScore(key, math.Inf(-1), math.Inf(1), 6, 2)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(members))
+	members, err = redisClient.ZRangeByScore(key, 15, math.Inf(1), 1, 0)
+	test.Nil(t, err)
+	test.Equal(t, []string{"d", "e", "f"}, members)
+
+	members, err = redisClient.ZRevRange(key, 0, 2)
+	test.Nil(t, err)
+	test.Equal(t, []string{"f", "e", "d"}, members)
+
+	// rank 0 is a member, a missing member is ErrNil
+	rank, err := redisClient.ZRank(key, "a")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), rank)
+	rank, err = redisClient.ZRevRank(key, "a")
+	test.Nil(t, err)
+	test.Equal(t, int64(5), rank)
+	_, err = redisClient.ZRank(key, "missing")
+	test.Equal(t, ErrNil, err)
+	_, err = redisClient.ZRevRank(key, "missing")
+	test.Equal(t, ErrNil, err)
+
+	count, err = redisClient.ZCard("dotweb:test:zranks:missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(0), count)
+}
+
+func TestRedisClient_HyperLogLog(t *testing.T) {
+	redisClient := newTestClient(t)
+	key1, key2, dest := "dotweb:test:hll:1", "dotweb:test:hll:2", "dotweb:test:hll:dest"
+	redisClient.Del(key1, key2, dest)
+	defer redisClient.Del(key1, key2, dest)
+
+	// 0-599 and 400-999 overlap, the union has 1000 elements
+	var elements1, elements2 []interface{}
+	for i := 0; i < 600; i++ {
+		elements1 = append(elements1, fmt.Sprintf("visitor%d", i))
+		elements2 = append(elements2, fmt.Sprintf("visitor%d", i+400))
+	}
+	changed, err := redisClient.PFAdd(key1, elements1...)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), changed)
+	_, err = redisClient.PFAdd(key2, elements2...)
+	test.Nil(t, err)
+
+	// the standard error of redis is 0.81%, allow 5%
+	assertNear := func(expected, actual int64) {
+		if math.Abs(float64(actual-expected)) > float64(expected)*0.05 {
+			t.Errorf("estimated cardinality %d too far from %d", actual, expected)
+		}
+	}
+	count, err := redisClient.PFCount(key1)
+	test.Nil(t, err)
+	assertNear(600, count)
+	count, err = redisClient.PFCount(key1, key2)
+	test.Nil(t, err)
+	assertNear(1000, count)
+
+	test.Nil(t, redisClient.PFMerge(dest, key1, key2))
+	count, err = redisClient.PFCount(dest)
+	test.Nil(t, err)
+	assertNear(1000, count)
+}
+
+func TestRedisClient_Geo(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:geo"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	added, err := redisClient.GeoAdd(key, 13.361389, 38.115556, "Palermo")
+	test.Nil(t, err)
+	test.Equal(t, int64(1), added)
+	added, err = redisClient.GeoAdd(key, 15.087269, 37.502669, "Catania")
+	test.Nil(t, err)
+	test.Equal(t, int64(1), added)
+
+	dist, err := redisClient.GeoDist(key, "Palermo", "Catania", "km")
+	test.Nil(t, err)
+	if math.Abs(dist-166.27) > 0.1 {
+		t.Errorf("unexpected distance %f km", dist)
+	}
+	_, err = redisClient.GeoDist(key, "Palermo", "Rome", "km")
+	test.Equal(t, ErrNil, err)
+
+	positions, err := redisClient.GeoPos(key, "Palermo", "Rome")
+	test.Nil(t, err)
+	test.Equal(t, 2, len(positions))
+	if math.Abs(positions[0][0]-13.361389) > 0.0001 || math.Abs(positions[0][1]-38.115556) > 0.0001 {
+		t.Errorf("unexpected position %v", positions[0])
+	}
+	test.Equal(t, true, math.IsNaN(positions[1][0]) && math.IsNaN(positions[1][1]))
+
+	members, err := redisClient.GeoRadius(key, 15, 37, 100, "km")
+	test.Nil(t, err)
+	test.Equal(t, []string{"Catania"}, members)
+	members, err = redisClient.GeoRadius(key, 15, 37, 200, "km")
+	test.Nil(t, err)
+	test.Equal(t, []string{"Catania", "Palermo"}, members)
+}
+
+func TestRedisClient_GetErrNil(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:errnil"
+	hashID := "dotweb:test:errnil:hash"
+	redisClient.Del(key)
+	redisClient.Del(hashID)
+	defer redisClient.Del(key)
+	defer redisClient.Del(hashID)
+
+	_, err := redisClient.Get(key)
+	test.Equal(t, ErrNil, err)
+	_, err = redisClient.Set(key, "")
+	test.Nil(t, err)
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "", val)
+	_, err = redisClient.Set(key, "value")
+	test.Nil(t, err)
+	val, err = redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	_, err = redisClient.HGet(hashID, "field")
+	test.Equal(t, ErrNil, err)
+	test.Nil(t, redisClient.HSet(hashID, "empty", ""))
+	_, err = redisClient.HGet(hashID, "field")
+	test.Equal(t, ErrNil, err)
+	val, err = redisClient.HGet(hashID, "empty")
+	test.Nil(t, err)
+	test.Equal(t, "", val)
+	test.Nil(t, redisClient.HSet(hashID, "field", "value"))
+	val, err = redisClient.HGet(hashID, "field")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	fields, err := redisClient.HGetAll("dotweb:test:errnil:missing")
+	test.Nil(t, err)
+	test.Equal(t, 0, len(fields))
+}
+
+func TestRedisClient_SampleKeys(t *testing.T) {
+	newTestClient(t)
+	// dedicated client, so the database only holds the keys of the test
+	db11 := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 2, MaxActive: 11})
+	defer db11.Close()
+	test.Nil(t, db11.Select(11))
+	test.Nil(t, db11.FlushDB())
+	defer db11.FlushDB()
+
+	_, err := db11.RandomKey()
+	test.Equal(t, ErrNil, err)
+	keys, err := db11.SampleKeys(3)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(keys))
+
+	existing := map[string]bool{}
+	for i := 0; i < 5; i++ {
+		key := "dotweb:test:sample:" + strconv.Itoa(i)
+		existing[key] = true
+		_, err := db11.Set(key, i)
+		test.Nil(t, err)
+	}
+	key, err := db11.RandomKey()
+	test.Nil(t, err)
+	test.Equal(t, true, existing[key])
+
+	for _, n := range []int{3, 10} {
+		keys, err = db11.SampleKeys(n)
+		test.Nil(t, err)
+		if len(keys) == 0 || len(keys) > n || len(keys) > 5 {
+			t.Fatalf("SampleKeys(%d) returned %d keys", n, len(keys))
+		}
+		seen := map[string]bool{}
+		for _, key := range keys {
+			test.Equal(t, true, existing[key])
+			test.Equal(t, false, seen[key])
+			seen[key] = true
+		}
+	}
+}
+
+func TestRedisClient_SampleKeysNotPositive(t *testing.T) {
+	redisClient := newTestClient(t)
+	for _, n := range []int{0, -1} {
+		keys, err := redisClient.SampleKeys(n)
+		test.Nil(t, err)
+		test.Equal(t, []string{}, keys)
+	}
+}
+
+func TestRedisClient_ServerTime(t *testing.T) {
+	redisClient := newTestClient(t)
+	serverTime, err := redisClient.ServerTime()
+	test.Nil(t, err)
+	if d := time.Since(serverTime); d < -5*time.Second || d > 5*time.Second {
+		t.Errorf("server time %s is %s away from local time", serverTime, d)
+	}
+	skew, err := redisClient.ClockSkew()
+	test.Nil(t, err)
+	if skew < -5*time.Second || skew > 5*time.Second {
+		t.Errorf("unexpected clock skew %s", skew)
+	}
+}
+
+func TestRedisClient_ServerTimeReply(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []interface{}{[]byte("1700000000"), []byte("250000")}
+	})
+	defer server.Close()
+
+	serverTime, err := GetRedisClient(server.URL()).ServerTime()
+	test.Nil(t, err)
+	test.Equal(t, time.Unix(1700000000, 250*int64(time.Millisecond)), serverTime)
+}
+
+func TestRedisClient_ObjectEncodingMemoryUsage(t *testing.T) {
+	redisClient := newTestClient(t)
+	small, large := "dotweb:test:memory:small", "dotweb:test:memory:large"
+	defer redisClient.Del(small)
+	defer redisClient.Del(large)
+
+	test.Nil(t, redisClient.HSet(small, "field", "value"))
+	fields := make(map[string]interface{})
+	for i := 0; i < 1000; i++ {
+		fields[fmt.Sprintf("field%d", i)] = strings.Repeat("v", 100)
+	}
+	test.Nil(t, redisClient.HMSet(large, fields))
+
+	encoding, err := redisClient.ObjectEncoding(large)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, "hashtable", encoding)
+	smallUsage, err := redisClient.MemoryUsage(small)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	largeUsage, err := redisClient.MemoryUsage(large)
+	test.Nil(t, err)
+	if largeUsage <= smallUsage {
+		t.Errorf("memory usage of large hash %d not above small hash %d", largeUsage, smallUsage)
+	}
+}
+
+func TestRedisClient_ObjectEncodingMissing(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return nil
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	_, err := redisClient.ObjectEncoding("missing")
+	test.Equal(t, ErrNil, err)
+	usage, err := redisClient.MemoryUsage("missing")
+	test.Equal(t, ErrNil, err)
+	test.Equal(t, int64(-1), usage)
+	test.Equal(t, []string{"MEMORY", "USAGE", "missing"}, server.Commands()[1])
+}
+
+func TestRedisClient_ObjectIdleTime(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:idletime"
+	defer redisClient.Del(key)
+
+	_, err := redisClient.Set(key, "value")
+	test.Nil(t, err)
+	_, err = redisClient.Get(key)
+	test.Nil(t, err)
+	idle, err := redisClient.ObjectIdleTime(key)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	if idle < 0 || idle > 1 {
+		t.Errorf("unexpected idle time %d of a key just read", idle)
 	}
 }
-*/
+
+func TestRedisClient_ObjectIdleTimeFreqReply(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		if len(args) < 3 || args[2] == "missing" {
+			return nil
+		}
+		if strings.ToUpper(args[1]) == "FREQ" {
+			return errors.New("ERR An LFU maxmemory policy is not selected, access frequency not tracked.")
+		}
+		return int64(42)
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	idle, err := redisClient.ObjectIdleTime("key")
+	test.Nil(t, err)
+	test.Equal(t, int64(42), idle)
+	idle, err = redisClient.ObjectIdleTime("missing")
+	test.Equal(t, ErrNil, err)
+	test.Equal(t, int64(-1), idle)
+	freq, err := redisClient.ObjectFreq("missing")
+	test.Equal(t, ErrNil, err)
+	test.Equal(t, int64(-1), freq)
+	_, err = redisClient.ObjectFreq("key")
+	test.NotNil(t, err)
+	test.Contains(t, "LFU maxmemory policy", err.Error())
+}
+
+func TestRedisClient_Wait(t *testing.T) {
+	redisClient := newTestClient(t)
+	start := time.Now()
+	replicas, err := redisClient.Wait(0, time.Second)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), replicas)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Wait returned after %s", elapsed)
+	}
+
+	// without replicas, it returns 0 on timeout
+	replicas, err = redisClient.Wait(1, 50*time.Millisecond)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), replicas)
+}
+
+func TestRedisClient_SelectFlushDB(t *testing.T) {
+	newTestClient(t)
+	// dedicated clients, so switching the database does not affect other tests
+	db14 := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 2, MaxActive: 14})
+	db15 := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 2, MaxActive: 15})
+	defer db14.Close()
+	defer db15.Close()
+	test.Nil(t, db14.Select(14))
+	test.Nil(t, db15.Select(15))
+
+	key := "dotweb:test:select"
+	_, err := db14.Set(key, "14")
+	test.Nil(t, err)
+	_, err = db15.Set(key, "15")
+	test.Nil(t, err)
+	val, err := db14.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "14", val)
+
+	// an idle connection is switched when borrowed again
+	test.Nil(t, db14.Select(15))
+	val, err = db14.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "15", val)
+	test.Nil(t, db14.Select(14))
+
+	test.Nil(t, db15.FlushDB())
+	_, err = db15.Get(key)
+	test.Equal(t, ErrNil, err)
+	val, err = db14.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "14", val)
+	_, err = db15.Set(key, "15")
+	test.Nil(t, err)
+	test.Nil(t, db15.FlushDBAsync())
+	_, err = db15.Get(key)
+	test.Equal(t, ErrNil, err)
+	val, err = db14.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "14", val)
+	test.Nil(t, db14.FlushDB())
+
+	test.NotNil(t, db14.Select(-1))
+}
+
+func TestRedisClient_FlushDBError(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return errors.New("NOPERM this user has no permissions to run the 'flushdb' command")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	test.Contains(t, "NOPERM", redisClient.FlushDB().Error())
+	test.Contains(t, "NOPERM", redisClient.FlushDBAsync().Error())
+	test.Equal(t, []string{"FLUSHDB"}, server.Commands()[0])
+	test.Equal(t, []string{"FLUSHDB", "ASYNC"}, server.Commands()[1])
+}
+
+func TestRedisClient_Move(t *testing.T) {
+	newTestClient(t)
+	// dedicated clients, so switching the database does not affect other tests
+	db12 := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 2, MaxActive: 12})
+	db13 := GetRedisClientWithConfig(redisServerURL, PoolConfig{MaxIdle: 2, MaxActive: 13})
+	defer db12.Close()
+	defer db13.Close()
+	test.Nil(t, db12.Select(12))
+	test.Nil(t, db13.Select(13))
+	defer db12.FlushDB()
+	defer db13.FlushDB()
+
+	key := "dotweb:test:move"
+	_, err := db12.Set(key, "value")
+	test.Nil(t, err)
+	ok, err := db12.Move(key, 13)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	exists, err := db12.Exists(key)
+	test.Nil(t, err)
+	test.Equal(t, false, exists)
+	val, err := db13.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	// the key already exists in the target database
+	_, err = db12.Set(key, "other")
+	test.Nil(t, err)
+	ok, err = db12.Move(key, 13)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+}
+
+func TestRedisClient_Copy(t *testing.T) {
+	redisClient := newTestClient(t)
+	src, dst := "dotweb:test:copy:src", "dotweb:test:copy:dst"
+	defer redisClient.Del(src)
+	defer redisClient.Del(dst)
+
+	_, err := redisClient.Set(src, "value")
+	test.Nil(t, err)
+	ok, err := redisClient.Copy(src, dst, false)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	val, err := redisClient.Get(dst)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	// dst exists
+	_, err = redisClient.Set(src, "new")
+	test.Nil(t, err)
+	ok, err = redisClient.Copy(src, dst, false)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	ok, err = redisClient.Copy(src, dst, true)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	val, err = redisClient.Get(dst)
+	test.Nil(t, err)
+	test.Equal(t, "new", val)
+}
+
+func TestRedisClient_DumpRestore(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:dump"
+	defer redisClient.Del(key)
+
+	value := []byte{0x00, 0xff, 0x80, '\r', '\n', 'v'}
+	test.Nil(t, redisClient.SetBytes(key, value))
+	data, err := redisClient.Dump(key)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	_, err = redisClient.Del(key)
+	test.Nil(t, err)
+
+	test.Nil(t, redisClient.Restore(key, 0, data, false))
+	restored, err := redisClient.GetBytes(key)
+	test.Nil(t, err)
+	test.Equal(t, value, restored)
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), ttl)
+
+	// the key exists
+	test.NotNil(t, redisClient.Restore(key, 0, data, false))
+	test.Nil(t, redisClient.Restore(key, 100000, data, true))
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	if ttl <= 90 || ttl > 100 {
+		t.Errorf("unexpected ttl %d", ttl)
+	}
+
+	_, err = redisClient.Dump("dotweb:test:dump:missing")
+	test.Equal(t, ErrNil, err)
+}
+
+func TestRedisClient_GetSetGetDel(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:getset"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	_, err := redisClient.GetSet(key, 1)
+	test.Equal(t, ErrNil, err)
+	prev, err := redisClient.GetSet(key, 0)
+	test.Nil(t, err)
+	test.Equal(t, "1", prev)
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "0", val)
+
+	val, err = redisClient.GetDel(key)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, "0", val)
+	exists, err := redisClient.Exists(key)
+	test.Nil(t, err)
+	test.Equal(t, false, exists)
+	_, err = redisClient.GetDel(key)
+	test.Equal(t, ErrNil, err)
+}
+
+func TestRedisClient_GetDelFallback(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "GETDEL":
+			return errors.New("ERR unknown command 'GETDEL'")
+		case "MULTI":
+			return "OK"
+		case "GET", "DEL":
+			return "QUEUED"
+		case "EXEC":
+			return []interface{}{[]byte("value"), int64(1)}
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	val, err := GetRedisClient(server.URL()).GetDel("key")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	commands := server.Commands()
+	test.Equal(t, 5, len(commands))
+	test.Equal(t, []string{"GET", "key"}, commands[2])
+	test.Equal(t, []string{"DEL", "key"}, commands[3])
+}
+
+func TestRedisClient_List(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:list"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	n, err := redisClient.RPush(key, "b", "c", "d")
+	test.Nil(t, err)
+	test.Equal(t, int64(3), n)
+	n, err = redisClient.LPush(key, "a", "z")
+	test.Nil(t, err)
+	test.Equal(t, int64(5), n)
+	items, err := redisClient.LRange(key, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"z", "a", "b", "c", "d"}, items)
+
+	val, err := redisClient.LIndex(key, -1)
+	test.Nil(t, err)
+	test.Equal(t, "d", val)
+	_, err = redisClient.LIndex(key, 10)
+	test.Equal(t, ErrNil, err)
+
+	_, err = redisClient.RPush(key, "b")
+	test.Nil(t, err)
+	removed, err := redisClient.LRem(key, 0, "b")
+	test.Nil(t, err)
+	test.Equal(t, int64(2), removed)
+
+	// consume the queue from both ends
+	val, err = redisClient.LPop(key)
+	test.Nil(t, err)
+	test.Equal(t, "z", val)
+	val, err = redisClient.RPop(key)
+	test.Nil(t, err)
+	test.Equal(t, "d", val)
+	length, err := redisClient.LLen(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(2), length)
+	items, err = redisClient.LRange(key, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"a", "c"}, items)
+
+	redisClient.LPop(key)
+	redisClient.LPop(key)
+	_, err = redisClient.LPop(key)
+	test.Equal(t, ErrNil, err)
+	_, err = redisClient.RPop(key)
+	test.Equal(t, ErrNil, err)
+	length, err = redisClient.LLen(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), length)
+}
+
+func TestRedisClient_LMoveLPos(t *testing.T) {
+	redisClient := newTestClient(t)
+	queue := "dotweb:test:lmove:queue"
+	processing := "dotweb:test:lmove:processing"
+	for _, key := range []string{queue, processing} {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+	_, err := redisClient.RPush(queue, "job1", "job2", "job3")
+	test.Nil(t, err)
+
+	// a reliable queue keeps the job being processed in another list
+	val, err := redisClient.LMove(queue, processing, "LEFT", "RIGHT")
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, "job1", val)
+	val, err = redisClient.LMove(queue, processing, "left", "right")
+	test.Nil(t, err)
+	test.Equal(t, "job2", val)
+	items, err := redisClient.LRange(queue, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"job3"}, items)
+	items, err = redisClient.LRange(processing, 0, -1)
+	test.Nil(t, err)
+	test.Equal(t, []string{"job1", "job2"}, items)
+
+	pos, err := redisClient.LPos(processing, "job2")
+	test.Nil(t, err)
+	test.Equal(t, int64(1), pos)
+	_, err = redisClient.LPos(processing, "job3")
+	test.Equal(t, ErrNil, err)
+
+	_, err = redisClient.LMove(queue, processing, "LEFT", "MIDDLE")
+	test.NotNil(t, err)
+	redisClient.LPop(queue)
+	_, err = redisClient.LMove(queue, processing, "LEFT", "RIGHT")
+	test.Equal(t, ErrNil, err)
+}
+
+func TestRedisClient_BLPopN(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:blpop:a", "dotweb:test:blpop:b", "dotweb:test:blpop:c"}
+	redisClient.Del(keys...)
+	defer redisClient.Del(keys...)
+
+	time.AfterFunc(100*time.Millisecond, func() {
+		redisClient.RPush(keys[1], "first", "last")
+	})
+	key, value, err := redisClient.BLPopN(5*time.Second, keys...)
+	test.Nil(t, err)
+	test.Equal(t, keys[1], key)
+	test.Equal(t, "first", value)
+	key, value, err = redisClient.BRPopN(5*time.Second, keys...)
+	test.Nil(t, err)
+	test.Equal(t, keys[1], key)
+	test.Equal(t, "last", value)
+
+	start := time.Now()
+	_, _, err = redisClient.BRPopN(time.Second, keys...)
+	test.Equal(t, ErrTimeout, err)
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("BRPopN returned after %v", elapsed)
+	}
+}
+
+func TestRedisClient_BLPopNTimeout(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return nil
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	// the timeout is rounded up to seconds
+	_, _, err := redisClient.BLPopN(1500*time.Millisecond, "a", "b")
+	test.Equal(t, ErrTimeout, err)
+	test.Equal(t, []string{"BLPOP", "a", "b", "2"}, server.Commands()[0])
+	_, _, err = redisClient.BRPopN(0, "a")
+	test.Equal(t, ErrTimeout, err)
+	test.Equal(t, []string{"BRPOP", "a", "0"}, server.Commands()[1])
+	_, _, err = redisClient.BRPopN(time.Second)
+	test.NotNil(t, err)
+}
+
+func TestRedisClient_SetAlgebra(t *testing.T) {
+	redisClient := newTestClient(t)
+	golang := "dotweb:test:tags:go"
+	web := "dotweb:test:tags:web"
+	union := "dotweb:test:tags:union"
+	for _, key := range []string{golang, web, union} {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+	_, err := redisClient.SAdd(golang, "dotweb", "gin", "cobra")
+	test.Nil(t, err)
+	_, err = redisClient.SAdd(web, "dotweb", "gin", "django")
+	test.Nil(t, err)
+
+	members, err := redisClient.SMembers(golang)
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"cobra", "dotweb", "gin"}, members)
+	ok, err := redisClient.SIsMember(web, "django")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ok, err = redisClient.SIsMember(web, "cobra")
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+
+	members, err = redisClient.SInter(golang, web)
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"dotweb", "gin"}, members)
+	members, err = redisClient.SUnion(golang, web)
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"cobra", "django", "dotweb", "gin"}, members)
+	members, err = redisClient.SDiff(golang, web)
+	test.Nil(t, err)
+	test.Equal(t, []string{"cobra"}, members)
+
+	n, err := redisClient.SUnionStore(union, golang, web)
+	test.Nil(t, err)
+	test.Equal(t, int64(4), n)
+	n, err = redisClient.SInterStore(union, golang, web)
+	test.Nil(t, err)
+	test.Equal(t, int64(2), n)
+	members, err = redisClient.SMembers(union)
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"dotweb", "gin"}, members)
+	n, err = redisClient.SDiffStore(union, golang, web)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), n)
+	members, err = redisClient.SMembers(union)
+	test.Nil(t, err)
+	test.Equal(t, []string{"cobra"}, members)
+}
+
+func TestRedisClient_SInterStore(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:sinterstore:a", "dotweb:test:sinterstore:b", "dotweb:test:sinterstore:dest"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+	_, err := redisClient.SAdd(keys[0], "x", "y", "z")
+	test.Nil(t, err)
+	_, err = redisClient.SAdd(keys[1], "y", "z", "w")
+	test.Nil(t, err)
+	_, err = redisClient.SAdd(keys[2], "stale")
+	test.Nil(t, err)
+
+	// dest is overwritten with the intersection
+	n, err := redisClient.SInterStore(keys[2], keys[0], keys[1])
+	test.Nil(t, err)
+	test.Equal(t, int64(2), n)
+	members, err := redisClient.SMembers(keys[2])
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"y", "z"}, members)
+}
+
+func TestRedisClient_SAddManySRemMany(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:tagindex:go", "dotweb:test:tagindex:web", "dotweb:test:tagindex:string"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+
+	test.Nil(t, redisClient.SAddMany(map[string][]interface{}{
+		keys[0]: {"dotweb", "gin"},
+		keys[1]: {"dotweb", "django"},
+	}))
+	members, err := redisClient.SMembers(keys[0])
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"dotweb", "gin"}, members)
+	members, err = redisClient.SMembers(keys[1])
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"django", "dotweb"}, members)
+
+	test.Nil(t, redisClient.SRemMany(map[string][]interface{}{
+		keys[0]: {"gin"},
+		keys[1]: {"dotweb", "missing"},
+	}))
+	members, err = redisClient.SMembers(keys[0])
+	test.Nil(t, err)
+	test.Equal(t, []string{"dotweb"}, members)
+	members, err = redisClient.SMembers(keys[1])
+	test.Nil(t, err)
+	test.Equal(t, []string{"django"}, members)
+
+	// the failed keys are reported, the others are updated
+	_, err = redisClient.Set(keys[2], "value")
+	test.Nil(t, err)
+	err = redisClient.SAddMany(map[string][]interface{}{
+		keys[0]: {"echo"},
+		keys[2]: {"dotweb"},
+	})
+	test.NotNil(t, err)
+	test.Contains(t, "failed for 1 of 2 keys", err.Error())
+	test.Contains(t, keys[2]+": WRONGTYPE", err.Error())
+	ok, err := redisClient.SIsMember(keys[0], "echo")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+}
+
+func TestRedisClient_SRemSPopN(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:srem"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	_, err := redisClient.SAdd(key, "a", "b", "c", "d", "e")
+	test.Nil(t, err)
+	removed, err := redisClient.SRem(key, "a", "b", "missing")
+	test.Nil(t, err)
+	test.Equal(t, int64(2), removed)
+
+	popped, err := redisClient.SPopN(key, 2)
+	test.Nil(t, err)
+	test.Equal(t, 2, len(popped))
+	rest, err := redisClient.SMembers(key)
+	test.Nil(t, err)
+	all := append(popped, rest...)
+	sort.Strings(all)
+	test.Equal(t, []string{"c", "d", "e"}, all)
+
+	popped, err = redisClient.SPopN(key, 10)
+	test.Nil(t, err)
+	test.Equal(t, 1, len(popped))
+	popped, err = redisClient.SPopN(key, 10)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(popped))
+}
+
+func TestRedisClient_SMove(t *testing.T) {
+	redisClient := newTestClient(t)
+	pending, done := "dotweb:test:smove:pending", "dotweb:test:smove:done"
+	redisClient.Del(pending, done)
+	defer redisClient.Del(pending, done)
+
+	_, err := redisClient.SAdd(pending, "job1", "job2")
+	test.Nil(t, err)
+	_, err = redisClient.SAdd(done, "job0")
+	test.Nil(t, err)
+
+	moved, err := redisClient.SMove(pending, done, "job1")
+	test.Nil(t, err)
+	test.Equal(t, true, moved)
+	members, err := redisClient.SMembers(pending)
+	test.Nil(t, err)
+	test.Equal(t, []string{"job2"}, members)
+	members, err = redisClient.SMembers(done)
+	test.Nil(t, err)
+	sort.Strings(members)
+	test.Equal(t, []string{"job0", "job1"}, members)
+
+	// a member not in source modifies no set
+	moved, err = redisClient.SMove(pending, done, "job3")
+	test.Nil(t, err)
+	test.Equal(t, false, moved)
+	count, err := redisClient.SCard(pending)
+	test.Nil(t, err)
+	test.Equal(t, 1, count)
+	count, err = redisClient.SCard(done)
+	test.Nil(t, err)
+	test.Equal(t, 2, count)
+}
+
+func TestRedisClient_HashFields(t *testing.T) {
+	redisClient := newTestClient(t)
+	hashID := "dotweb:test:hash:fields"
+	redisClient.Del(hashID)
+	defer redisClient.Del(hashID)
+
+	test.Nil(t, redisClient.HMSet(hashID, map[string]interface{}{
+		"name":  "dotweb",
+		"stars": 100,
+		"lang":  "go",
+	}))
+	values, err := redisClient.HMGet(hashID, "stars", "missing", "name", "lang")
+	test.Nil(t, err)
+	test.Equal(t, []string{"100", "", "dotweb", "go"}, values)
+
+	ok, err := redisClient.HExists(hashID, "name")
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ok, err = redisClient.HExists(hashID, "missing")
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+
+	keys, err := redisClient.HKeys(hashID)
+	test.Nil(t, err)
+	sort.Strings(keys)
+	test.Equal(t, []string{"lang", "name", "stars"}, keys)
+
+	stars, err := redisClient.HIncrBy(hashID, "stars", -25)
+	test.Nil(t, err)
+	test.Equal(t, int64(75), stars)
+	stars, err = redisClient.HIncrBy(hashID, "forks", 3)
+	test.Nil(t, err)
+	test.Equal(t, int64(3), stars)
+}
+
+func TestRedisClient_HGetSetStruct(t *testing.T) {
+	type project struct {
+		Name     string  `redis:"name"`
+		Stars    int     `redis:"stars"`
+		Active   bool    `redis:"active"`
+		Score    float64 `redis:"score"`
+		Internal string  `redis:"-"`
+	}
+	redisClient := newTestClient(t)
+	hashID := "dotweb:test:hash:struct"
+	redisClient.Del(hashID)
+	defer redisClient.Del(hashID)
+
+	src := project{Name: "dotweb", Stars: 100, Active: true, Score: 9.5, Internal: "secret"}
+	test.Nil(t, redisClient.HSetStruct(hashID, &src))
+	fields, err := redisClient.HGetAll(hashID)
+	test.Nil(t, err)
+	test.Equal(t, map[string]string{"name": "dotweb", "stars": "100", "active": "1", "score": "9.5"}, fields)
+
+	var dest project
+	test.Nil(t, redisClient.HGetStruct(hashID, &dest))
+	src.Internal = ""
+	test.Equal(t, src, dest)
+
+	test.Equal(t, ErrNil, redisClient.HGetStruct("dotweb:test:hash:struct:missing", &dest))
+}
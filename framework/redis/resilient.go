@@ -0,0 +1,99 @@
+package redisutil
+
+import (
+	"sync"
+	"time"
+)
+
+// ReconnectedChannel is the Channel of the Message delivered by
+// SubscribeResilient once it subscribed again after its connection broke,
+// its Payload is the error which broke the connection. the messages
+// published meanwhile are lost
+const ReconnectedChannel = "redisutil:reconnected"
+
+const (
+	resubscribeMinBackoff = 100 * time.Millisecond
+	resubscribeMaxBackoff = 10 * time.Second
+)
+
+// SubscribeResilient subscribes to channels like Subscribe, but subscribes
+// again on a new connection when the connection breaks, waiting for a backoff
+// doubling up to 10s between the failed attempts. the returned channel
+// receives the messages, and a Message of ReconnectedChannel after each
+// reconnection. it is closed by the returned cancel function
+func (rc *RedisClient) SubscribeResilient(channels ...string) (<-chan Message, func()) {
+	out := make(chan Message, pubSubBufferSize)
+	done := make(chan struct{})
+	var once sync.Once
+	go rc.resubscribeLoop(channels, out, done)
+	return out, func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+func (rc *RedisClient) resubscribeLoop(channels []string, out chan<- Message, done <-chan struct{}) {
+	defer close(out)
+	backoff := resubscribeMinBackoff
+	var broken error // error which broke the last subscription
+	for {
+		s, err := rc.Subscribe(channels...)
+		if err == ErrClientClosed {
+			return
+		}
+		if err == nil {
+			backoff = resubscribeMinBackoff
+			if broken != nil {
+				select {
+				case out <- Message{Channel: ReconnectedChannel, Payload: broken.Error()}:
+				case <-done:
+					s.Close()
+					return
+				}
+			}
+			if err = forwardMessages(s, out, done); err == nil {
+				return
+			}
+			broken = err
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > resubscribeMaxBackoff {
+			backoff = resubscribeMaxBackoff
+		}
+	}
+}
+
+// forwardMessages passes the messages of s to out until done, and returns
+// nil, or until s fails, and returns its error. s is closed on return
+func forwardMessages(s *Subscription, out chan<- Message, done <-chan struct{}) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		// unblocks ReceiveMessage
+		select {
+		case <-done:
+			s.Close()
+		case <-stop:
+		}
+	}()
+	defer s.Close()
+	for {
+		msg, err := s.ReceiveMessage()
+		if err != nil {
+			select {
+			case <-done:
+				return nil
+			default:
+				return err
+			}
+		}
+		select {
+		case out <- msg:
+		case <-done:
+			return nil
+		}
+	}
+}
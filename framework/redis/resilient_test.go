@@ -0,0 +1,110 @@
+package redisutil
+
+import (
+	"io"
+	"net"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+// tcpProxy forwards the connections to target, so the tests can break them
+type tcpProxy struct {
+	ln     net.Listener
+	target string
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+func newTCPProxy(t *testing.T, target string) *tcpProxy {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	test.Nil(t, err)
+	p := &tcpProxy{ln: ln, target: target}
+	go p.serve()
+	return p
+}
+
+func (p *tcpProxy) serve() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		upstream, err := net.Dial("tcp", p.target)
+		if err != nil {
+			conn.Close()
+			continue
+		}
+		p.mu.Lock()
+		p.conns = append(p.conns, conn, upstream)
+		p.mu.Unlock()
+		go io.Copy(upstream, conn)
+		go io.Copy(conn, upstream)
+	}
+}
+
+// Break closes all the connections forwarded so far
+func (p *tcpProxy) Break() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+}
+
+func (p *tcpProxy) Close() {
+	p.ln.Close()
+	p.Break()
+}
+
+func TestRedisClient_SubscribeResilient(t *testing.T) {
+	redisClient := newTestClient(t)
+	u, err := url.Parse(redisServerURL)
+	test.Nil(t, err)
+	proxy := newTCPProxy(t, u.Host)
+	defer proxy.Close()
+	u.Host = proxy.ln.Addr().String()
+	subscriber := GetRedisClient(u.String())
+	defer subscriber.Close()
+
+	channel := "dotweb:test:resilient"
+	messages, cancel := subscriber.SubscribeResilient(channel)
+	defer cancel()
+	waitNumSub(t, redisClient, channel, 1)
+	publish(t, redisClient, channel, "before")
+	test.Equal(t, Message{Channel: channel, Payload: "before"}, receiveMessage(t, messages))
+
+	// the connection breaks, the channel is subscribed again
+	proxy.Break()
+	msg := receiveMessage(t, messages)
+	test.Equal(t, ReconnectedChannel, msg.Channel)
+	test.NotEqual(t, "", msg.Payload)
+	waitNumSub(t, redisClient, channel, 1)
+	publish(t, redisClient, channel, "after")
+	test.Equal(t, Message{Channel: channel, Payload: "after"}, receiveMessage(t, messages))
+
+	cancel()
+	select {
+	case _, ok := <-messages:
+		test.Equal(t, false, ok)
+	case <-time.After(time.Second):
+		t.Fatal("messages not closed by cancel")
+	}
+	waitNumSub(t, redisClient, channel, 0)
+}
+
+// waitNumSub waits until channel has n subscribers
+func waitNumSub(t *testing.T, redisClient *RedisClient, channel string, n int64) {
+	deadline := time.Now().Add(time.Second)
+	for numSub(t, redisClient, channel) != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s does not have %d subscribers", channel, n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
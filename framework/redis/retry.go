@@ -0,0 +1,115 @@
+package redisutil
+
+import (
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// readCommands are the commands which are safe to issue again after a
+// connection error, because they do not modify any data
+var readCommands = map[string]bool{}
+
+func init() {
+	for _, command := range []string{
+		"PING", "ECHO", "DBSIZE", "TYPE", "EXISTS", "TTL", "PTTL", "SCAN", "COMMAND", "DUMP",
+		"GET", "MGET", "STRLEN", "GETRANGE", "GETBIT", "BITCOUNT", "BITPOS",
+		"HGET", "HMGET", "HGETALL", "HLEN", "HKEYS", "HVALS", "HEXISTS", "HSTRLEN", "HSCAN",
+		"LLEN", "LRANGE", "LINDEX", "LPOS",
+		"SCARD", "SMEMBERS", "SISMEMBER", "SRANDMEMBER", "SDIFF", "SINTER", "SUNION", "SSCAN",
+		"ZCARD", "ZSCORE", "ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE", "ZREVRANGEBYSCORE",
+		"ZRANK", "ZREVRANK", "ZCOUNT", "ZSCAN",
+		"GEOPOS", "GEODIST", "GEOHASH", "GEOSEARCH",
+	} {
+		readCommands[command] = true
+	}
+}
+
+// retryPolicy is how the commands are retried after a connection error
+type retryPolicy struct {
+	maxRetries int
+	backoff    time.Duration
+}
+
+// retrySettings holds the retry policy of a RedisClient
+type retrySettings struct {
+	mu     sync.RWMutex
+	policy retryPolicy
+}
+
+// SetRetryPolicy sets how many times a read command is issued again on a new
+// connection after a connection error, e.g. a failed dial, EOF or reset, and
+// the wait before each retry. errors replied by the server, like WRONGTYPE,
+// are never retried, neither are the commands modifying data, which may have
+// been applied before the connection broke. maxRetries 0 disables retrying,
+// which is the default
+func (rc *RedisClient) SetRetryPolicy(maxRetries int, backoff time.Duration) {
+	if rc.parent != nil {
+		rc.parent.SetRetryPolicy(maxRetries, backoff)
+		return
+	}
+	rc.retry.mu.Lock()
+	rc.retry.policy = retryPolicy{maxRetries: maxRetries, backoff: backoff}
+	rc.retry.mu.Unlock()
+}
+
+func (rc *RedisClient) retryPolicy() retryPolicy {
+	rc.retry.mu.RLock()
+	defer rc.retry.mu.RUnlock()
+	return rc.retry.policy
+}
+
+// isConnError returns whether err is caused by the connection rather than
+// replied by the server
+func isConnError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return strings.Contains(err.Error(), "use of closed network connection") ||
+		strings.Contains(err.Error(), "connection reset")
+}
+
+// retryConn issues a read command again on a new connection of the pool
+// after a connection error. once Send was called, replies may be pending on
+// the connection, so the commands are not retried anymore
+type retryConn struct {
+	redis.Conn
+	rc     *RedisClient
+	ctx    context.Context
+	policy retryPolicy
+	sent   bool
+}
+
+func (c *retryConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	if c.sent || !readCommands[strings.ToUpper(commandName)] {
+		return reply, err
+	}
+	for retries := 0; retries < c.policy.maxRetries && isConnError(err); retries++ {
+		select {
+		case <-time.After(c.policy.backoff):
+		case <-c.ctx.Done():
+			return nil, c.ctx.Err()
+		}
+		c.Conn.Close()
+		c.Conn = c.rc.commandConn(c.ctx)
+		reply, err = c.Conn.Do(commandName, args...)
+	}
+	return reply, err
+}
+
+func (c *retryConn) Send(commandName string, args ...interface{}) error {
+	c.sent = true
+	return c.Conn.Send(commandName, args...)
+}
@@ -0,0 +1,82 @@
+package redisutil
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+// failingDials makes the first n dials of redisClient fail like a refused connection
+func failingDials(redisClient *RedisClient, n int32) *int32 {
+	var dials int32
+	redisClient.WithDialHook(func(conn redis.Conn) error {
+		if atomic.AddInt32(&dials, 1) <= n {
+			return &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+		return nil
+	})
+	return &dials
+}
+
+func TestRedisClient_SetRetryPolicy(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []byte("value")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	dials := failingDials(redisClient, 2)
+	redisClient.SetRetryPolicy(3, time.Millisecond)
+	val, err := redisClient.Get("dotweb:test:retry")
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+	test.Equal(t, int32(3), atomic.LoadInt32(dials))
+}
+
+func TestRedisClient_SetRetryPolicyExhausted(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []byte("value")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	dials := failingDials(redisClient, 3)
+	redisClient.SetRetryPolicy(1, time.Millisecond)
+	_, err := redisClient.Get("dotweb:test:retry")
+	test.Equal(t, true, isConnError(err))
+	test.Equal(t, int32(2), atomic.LoadInt32(dials))
+}
+
+func TestRedisClient_SetRetryPolicyWrites(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return "OK"
+	})
+	defer server.Close()
+
+	// commands modifying data are not retried
+	redisClient := GetRedisClient(server.URL())
+	dials := failingDials(redisClient, 1)
+	redisClient.SetRetryPolicy(3, time.Millisecond)
+	_, err := redisClient.Set("dotweb:test:retry", "value")
+	test.Equal(t, true, isConnError(err))
+	test.Equal(t, int32(1), atomic.LoadInt32(dials))
+}
+
+func TestRedisClient_SetRetryPolicyServerError(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	redisClient.SetRetryPolicy(3, time.Millisecond)
+	_, err := redisClient.Get("dotweb:test:retry")
+	test.Equal(t, true, strings.HasPrefix(err.Error(), "WRONGTYPE"))
+	test.Equal(t, 1, len(server.Commands()))
+}
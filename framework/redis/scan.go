@@ -0,0 +1,286 @@
+package redisutil
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// scanArgs returns the arguments of a SCAN family command
+func scanArgs(cursor uint64, match string, count int64) []interface{} {
+	args := []interface{}{cursor}
+	if match != "" {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	return args
+}
+
+// ScanAllProgress iterates the keys matching the pattern match with SCAN,
+// count is the hint of keys per batch. fn is called with each batch and the
+// cursor after it, which is 0 for the last batch, the iteration stops on the
+// first error returned by fn
+func (rc *RedisClient) ScanAllProgress(match string, count int64, fn func(keys []string, cursor uint64) error) error {
+	return rc.ScanAllProgressCtx(context.Background(), match, count, fn)
+}
+
+// ScanAllProgressCtx is like ScanAllProgress but honors the deadline and
+// cancellation of ctx, which is checked before each batch
+func (rc *RedisClient) ScanAllProgressCtx(ctx context.Context, match string, count int64, fn func(keys []string, cursor uint64) error) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, err := redis.Values(conn.Do("SCAN", scanArgs(cursor, match, count)...))
+		if err != nil {
+			return err
+		}
+		var keys []string
+		if _, err := redis.Scan(values, &cursor, &keys); err != nil {
+			return err
+		}
+		if err := fn(keys, cursor); err != nil {
+			return err
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// ScanEach iterates the keys matching the pattern match with SCAN and calls
+// fn with each key, count is the hint of keys per batch. the iteration stops
+// on the first error returned by fn. a key may be passed more than once if
+// the keyspace changes during the iteration
+func (rc *RedisClient) ScanEach(match string, count int64, fn func(key string) error) error {
+	return rc.ScanEachCtx(context.Background(), match, count, fn)
+}
+
+// ScanEachCtx is like ScanEach but honors the deadline and cancellation of
+// ctx, which is checked before each batch
+func (rc *RedisClient) ScanEachCtx(ctx context.Context, match string, count int64, fn func(key string) error) error {
+	return rc.ScanAllProgressCtx(ctx, match, count, func(keys []string, _ uint64) error {
+		for _, key := range keys {
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan returns the keys matching the pattern match using SCAN instead of
+// KEYS, so the server is not blocked. count is the hint of keys per batch,
+// each key is returned once
+func (rc *RedisClient) Scan(match string, count int64) ([]string, error) {
+	return rc.ScanCtx(context.Background(), match, count)
+}
+
+// ScanCtx is like Scan but honors the deadline and cancellation of ctx,
+// which is checked before each batch
+func (rc *RedisClient) ScanCtx(ctx context.Context, match string, count int64) ([]string, error) {
+	seen := make(map[string]bool)
+	keys := []string{}
+	err := rc.ScanEachCtx(ctx, match, count, func(key string) error {
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteByPattern deletes the keys matching the pattern match, iterating them
+// with SCAN and deleting each batch of about batchSize keys with UNLINK, or
+// DEL if the server does not support it. returns the count of keys deleted,
+// which can be lower than the count matched if keys expire meanwhile
+func (rc *RedisClient) DeleteByPattern(match string, batchSize int64) (int64, error) {
+	return rc.DeleteByPatternCtx(context.Background(), match, batchSize)
+}
+
+// DeleteByPatternCtx is like DeleteByPattern but stops between the batches
+// once ctx is done, returning the count of keys deleted so far and ctx.Err()
+func (rc *RedisClient) DeleteByPatternCtx(ctx context.Context, match string, batchSize int64) (int64, error) {
+	var deleted int64
+	del := rc.UnlinkCtx
+	err := rc.ScanAllProgressCtx(ctx, match, batchSize, func(keys []string, _ uint64) error {
+		n, err := del(ctx, keys...)
+		if err != nil && strings.Contains(err.Error(), "unknown command") {
+			del = rc.DelCtx
+			n, err = del(ctx, keys...)
+		}
+		deleted += n
+		return err
+	})
+	return deleted, err
+}
+
+// scanKeyEach iterates the elements of key with the SCAN family command cmd,
+// like SSCAN, and calls fn with each batch of elements until ctx is done
+func (rc *RedisClient) scanKeyEach(ctx context.Context, cmd, key, match string, count int64, fn func(elements []string) error) error {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	var cursor uint64
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		values, err := redis.Values(conn.Do(cmd, append([]interface{}{key}, scanArgs(cursor, match, count)...)...))
+		if err != nil {
+			return err
+		}
+		var elements []string
+		if _, err := redis.Scan(values, &cursor, &elements); err != nil {
+			return err
+		}
+		if err := fn(elements); err != nil {
+			return err
+		}
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// SScanEach iterates the members of the set key matching the pattern match
+// with SSCAN and calls fn with each member, count is the hint of members per
+// batch. the iteration stops on the first error returned by fn. a member may
+// be passed more than once if the set changes during the iteration
+func (rc *RedisClient) SScanEach(key, match string, count int64, fn func(member string) error) error {
+	return rc.SScanEachCtx(context.Background(), key, match, count, fn)
+}
+
+// SScanEachCtx is like SScanEach but honors the deadline and cancellation of
+// ctx, which is checked before each batch
+func (rc *RedisClient) SScanEachCtx(ctx context.Context, key, match string, count int64, fn func(member string) error) error {
+	return rc.scanKeyEach(ctx, "SSCAN", key, match, count, func(members []string) error {
+		for _, member := range members {
+			if err := fn(member); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SScan returns the members of the set key matching the pattern match using
+// SSCAN instead of SMEMBERS, so the server is not blocked by a large set.
+// count is the hint of members per batch, each member is returned once
+func (rc *RedisClient) SScan(key, match string, count int64) ([]string, error) {
+	return rc.SScanCtx(context.Background(), key, match, count)
+}
+
+// SScanCtx is like SScan but honors the deadline and cancellation of ctx,
+// which is checked before each batch
+func (rc *RedisClient) SScanCtx(ctx context.Context, key, match string, count int64) ([]string, error) {
+	seen := make(map[string]bool)
+	members := []string{}
+	err := rc.SScanEachCtx(ctx, key, match, count, func(member string) error {
+		if !seen[member] {
+			seen[member] = true
+			members = append(members, member)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// HScanEach iterates the fields of the hash key matching the pattern match
+// with HSCAN and calls fn with each field and its value, count is the hint of
+// fields per batch. the iteration stops on the first error returned by fn
+func (rc *RedisClient) HScanEach(key, match string, count int64, fn func(field, value string) error) error {
+	return rc.HScanEachCtx(context.Background(), key, match, count, fn)
+}
+
+// HScanEachCtx is like HScanEach but honors the deadline and cancellation of
+// ctx, which is checked before each batch
+func (rc *RedisClient) HScanEachCtx(ctx context.Context, key, match string, count int64, fn func(field, value string) error) error {
+	return rc.scanKeyEach(ctx, "HSCAN", key, match, count, func(pairs []string) error {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			if err := fn(pairs[i], pairs[i+1]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// HScan returns the fields and values of the hash key whose field matches the
+// pattern match using HSCAN instead of HGETALL, so the server is not blocked
+// by a large hash. count is the hint of fields per batch
+func (rc *RedisClient) HScan(key, match string, count int64) (map[string]string, error) {
+	return rc.HScanCtx(context.Background(), key, match, count)
+}
+
+// HScanCtx is like HScan but honors the deadline and cancellation of ctx,
+// which is checked before each batch
+func (rc *RedisClient) HScanCtx(ctx context.Context, key, match string, count int64) (map[string]string, error) {
+	fields := make(map[string]string)
+	err := rc.HScanEachCtx(ctx, key, match, count, func(field, value string) error {
+		fields[field] = value
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// ZScanEach iterates the members of the sorted set key matching the pattern
+// match with ZSCAN and calls fn with each member and its score, count is the
+// hint of members per batch. the iteration stops on the first error returned by fn
+func (rc *RedisClient) ZScanEach(key, match string, count int64, fn func(member string, score float64) error) error {
+	return rc.ZScanEachCtx(context.Background(), key, match, count, fn)
+}
+
+// ZScanEachCtx is like ZScanEach but honors the deadline and cancellation of
+// ctx, which is checked before each batch
+func (rc *RedisClient) ZScanEachCtx(ctx context.Context, key, match string, count int64, fn func(member string, score float64) error) error {
+	return rc.scanKeyEach(ctx, "ZSCAN", key, match, count, func(pairs []string) error {
+		for i := 0; i+1 < len(pairs); i += 2 {
+			score, err := strconv.ParseFloat(pairs[i+1], 64)
+			if err != nil {
+				return err
+			}
+			if err := fn(pairs[i], score); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ZScan returns the members and scores of the sorted set key whose member
+// matches the pattern match using ZSCAN instead of ZRANGE, so the server is
+// not blocked by a large sorted set. count is the hint of members per batch
+func (rc *RedisClient) ZScan(key, match string, count int64) (map[string]float64, error) {
+	return rc.ZScanCtx(context.Background(), key, match, count)
+}
+
+// ZScanCtx is like ZScan but honors the deadline and cancellation of ctx,
+// which is checked before each batch
+func (rc *RedisClient) ZScanCtx(ctx context.Context, key, match string, count int64) (map[string]float64, error) {
+	members := make(map[string]float64)
+	err := rc.ZScanEachCtx(ctx, key, match, count, func(member string, score float64) error {
+		members[member] = score
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
@@ -0,0 +1,338 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func seedKeys(t *testing.T, redisClient *RedisClient, prefix string, n int) []string {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("%s%d", prefix, i)
+		_, err := redisClient.Set(keys[i], i)
+		test.Nil(t, err)
+	}
+	return keys
+}
+
+func deleteKeys(redisClient *RedisClient, keys []string) {
+	for _, key := range keys {
+		redisClient.Del(key)
+	}
+}
+
+func TestRedisClient_ScanAllProgress(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := seedKeys(t, redisClient, "dotweb:test:scan:", 300)
+	defer deleteKeys(redisClient, keys)
+
+	seen := make(map[string]bool)
+	var cursors []uint64
+	err := redisClient.ScanAllProgress("dotweb:test:scan:*", 20, func(batch []string, cursor uint64) error {
+		for _, key := range batch {
+			seen[key] = true
+		}
+		cursors = append(cursors, cursor)
+		return nil
+	})
+	test.Nil(t, err)
+	test.Equal(t, len(keys), len(seen))
+	if len(cursors) < 2 {
+		t.Fatalf("expected several batches, got %d", len(cursors))
+	}
+	for _, cursor := range cursors[:len(cursors)-1] {
+		test.NotEqual(t, uint64(0), cursor)
+	}
+	test.Equal(t, uint64(0), cursors[len(cursors)-1])
+
+	stop := errors.New("stop")
+	calls := 0
+	err = redisClient.ScanAllProgress("dotweb:test:scan:*", 20, func([]string, uint64) error {
+		calls++
+		return stop
+	})
+	test.Equal(t, stop, err)
+	test.Equal(t, 1, calls)
+}
+
+func TestRedisClient_ScanAllProgress_Cursor(t *testing.T) {
+	// the server returns the keys in batches of two, the cursor being the
+	// index of the next batch
+	keys := []string{"k0", "k1", "k2", "k3", "k4"}
+	server := newFakeServer(t, func(args []string) interface{} {
+		var cursor int
+		fmt.Sscan(args[1], &cursor)
+		end, next := cursor+2, fmt.Sprint(cursor+2)
+		if end >= len(keys) {
+			end, next = len(keys), "0"
+		}
+		batch := []interface{}{}
+		for _, key := range keys[cursor:end] {
+			batch = append(batch, []byte(key))
+		}
+		return []interface{}{[]byte(next), batch}
+	})
+	defer server.Close()
+
+	var seen []string
+	var cursors []uint64
+	err := GetRedisClient(server.URL()).ScanAllProgress("k*", 2, func(batch []string, cursor uint64) error {
+		seen = append(seen, batch...)
+		cursors = append(cursors, cursor)
+		return nil
+	})
+	test.Nil(t, err)
+	test.Equal(t, keys, seen)
+	test.Equal(t, []uint64{2, 4, 0}, cursors)
+	test.Equal(t, []string{"SCAN", "2", "MATCH", "k*", "COUNT", "2"}, server.Commands()[1])
+}
+
+func TestRedisClient_Scan(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := seedKeys(t, redisClient, "dotweb:test:scankeys:", 50)
+	defer deleteKeys(redisClient, keys)
+	sort.Strings(keys)
+
+	for _, count := range []int64{0, 1, 7, 1000} {
+		result, err := redisClient.Scan("dotweb:test:scankeys:*", count)
+		test.Nil(t, err)
+		sort.Strings(result)
+		test.Equal(t, keys, result)
+	}
+
+	result, err := redisClient.Scan("dotweb:test:scankeys:missing*", 10)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(result))
+}
+
+func TestRedisClient_ScanEach(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := seedKeys(t, redisClient, "dotweb:test:scaneach:", 30)
+	defer deleteKeys(redisClient, keys)
+
+	seen := make(map[string]bool)
+	err := redisClient.ScanEach("dotweb:test:scaneach:*", 5, func(key string) error {
+		seen[key] = true
+		return nil
+	})
+	test.Nil(t, err)
+	test.Equal(t, len(keys), len(seen))
+
+	stop := errors.New("stop")
+	calls := 0
+	err = redisClient.ScanEach("dotweb:test:scaneach:*", 5, func(string) error {
+		calls++
+		return stop
+	})
+	test.Equal(t, stop, err)
+	test.Equal(t, 1, calls)
+}
+
+func TestRedisClient_ScanEachCtx(t *testing.T) {
+	// the server never ends the iteration
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []interface{}{[]byte("1"), []interface{}{[]byte("key")}}
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := GetRedisClient(server.URL()).ScanEachCtx(ctx, "*", 10, func(string) error {
+		calls++
+		cancel()
+		return nil
+	})
+	test.Equal(t, context.Canceled, err)
+	test.Equal(t, 1, calls)
+	test.Equal(t, 1, len(server.Commands()))
+}
+
+func TestRedisClient_DeleteByPattern(t *testing.T) {
+	redisClient := newTestClient(t)
+	evicted := seedKeys(t, redisClient, "dotweb:test:evict:session:", 40)
+	kept := seedKeys(t, redisClient, "dotweb:test:evict:user:", 10)
+	defer deleteKeys(redisClient, evicted)
+	defer deleteKeys(redisClient, kept)
+
+	deleted, err := redisClient.DeleteByPattern("dotweb:test:evict:session:*", 7)
+	test.Nil(t, err)
+	test.Equal(t, int64(40), deleted)
+	result, err := redisClient.Scan("dotweb:test:evict:*", 100)
+	test.Nil(t, err)
+	sort.Strings(result)
+	sort.Strings(kept)
+	test.Equal(t, kept, result)
+
+	deleted, err = redisClient.DeleteByPattern("dotweb:test:evict:session:*", 7)
+	test.Nil(t, err)
+	test.Equal(t, int64(0), deleted)
+}
+
+func TestRedisClient_DeleteByPatternDel(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch args[0] {
+		case "SCAN":
+			return []interface{}{[]byte("0"), []interface{}{[]byte("a"), []byte("b")}}
+		case "DEL":
+			return int64(1)
+		}
+		return errors.New("ERR unknown command '" + args[0] + "'")
+	})
+	defer server.Close()
+
+	deleted, err := GetRedisClient(server.URL()).DeleteByPattern("*", 10)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), deleted)
+	test.Equal(t, []string{"UNLINK", "a", "b"}, server.Commands()[1])
+	test.Equal(t, []string{"DEL", "a", "b"}, server.Commands()[2])
+}
+
+func TestRedisClient_DeleteByPatternCtx(t *testing.T) {
+	// the server never ends the iteration
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch args[0] {
+		case "SCAN":
+			return []interface{}{[]byte("1"), []interface{}{[]byte("a"), []byte("b")}}
+		case "UNLINK":
+			return int64(2)
+		}
+		return nil
+	})
+	defer server.Close()
+	redisClient := GetRedisClient(server.URL())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for len(server.Commands()) < 6 {
+			time.Sleep(time.Millisecond)
+		}
+		cancel()
+	}()
+	deleted, err := redisClient.DeleteByPatternCtx(ctx, "*", 2)
+	wg.Wait()
+	test.Equal(t, context.Canceled, err)
+	if deleted < 6 || deleted%2 != 0 {
+		t.Fatalf("deleted %d keys", deleted)
+	}
+}
+
+func TestRedisClient_HScan(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:hscan"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+	fields := make(map[string]interface{})
+	for i := 0; i < 300; i++ {
+		fields[fmt.Sprintf("field%d", i)] = i
+	}
+	test.Nil(t, redisClient.HMSet(key, fields))
+
+	result, err := redisClient.HScan(key, "", 20)
+	test.Nil(t, err)
+	test.Equal(t, len(fields), len(result))
+	for field, value := range fields {
+		test.Equal(t, fmt.Sprint(value), result[field])
+	}
+	result, err = redisClient.HScan(key, "field1?", 20)
+	test.Nil(t, err)
+	test.Equal(t, 10, len(result))
+
+	stop := errors.New("stop")
+	calls := 0
+	err = redisClient.HScanEach(key, "", 20, func(string, string) error {
+		calls++
+		return stop
+	})
+	test.Equal(t, stop, err)
+	test.Equal(t, 1, calls)
+}
+
+func TestRedisClient_SScanZScan(t *testing.T) {
+	redisClient := newTestClient(t)
+	setKey, zsetKey := "dotweb:test:sscan", "dotweb:test:zscan"
+	redisClient.Del(setKey)
+	redisClient.Del(zsetKey)
+	defer redisClient.Del(setKey)
+	defer redisClient.Del(zsetKey)
+	var members []string
+	for i := 0; i < 200; i++ {
+		member := fmt.Sprintf("member%d", i)
+		members = append(members, member)
+		_, err := redisClient.SAdd(setKey, member)
+		test.Nil(t, err)
+		_, err = redisClient.ZAdd(zsetKey, float64(i)/2, member)
+		test.Nil(t, err)
+	}
+	sort.Strings(members)
+
+	result, err := redisClient.SScan(setKey, "", 20)
+	test.Nil(t, err)
+	sort.Strings(result)
+	test.Equal(t, members, result)
+
+	scores, err := redisClient.ZScan(zsetKey, "member1*", 20)
+	test.Nil(t, err)
+	test.Equal(t, 111, len(scores))
+	test.Equal(t, 7.5, scores["member15"])
+}
+
+func TestRedisClient_HScan_Cursor(t *testing.T) {
+	// the server returns the field/value pairs in batches of two fields
+	pairs := []string{"f0", "v0", "f1", "v1", "f2", "v2"}
+	server := newFakeServer(t, func(args []string) interface{} {
+		var cursor int
+		fmt.Sscan(args[2], &cursor)
+		end, next := cursor+4, fmt.Sprint(cursor+4)
+		if end >= len(pairs) {
+			end, next = len(pairs), "0"
+		}
+		batch := []interface{}{}
+		for _, element := range pairs[cursor:end] {
+			batch = append(batch, []byte(element))
+		}
+		return []interface{}{[]byte(next), batch}
+	})
+	defer server.Close()
+
+	var seen []string
+	err := GetRedisClient(server.URL()).HScanEach("hash", "f*", 2, func(field, value string) error {
+		seen = append(seen, field+"="+value)
+		return nil
+	})
+	test.Nil(t, err)
+	test.Equal(t, []string{"f0=v0", "f1=v1", "f2=v2"}, seen)
+	test.Equal(t, []string{"HSCAN", "hash", "4", "MATCH", "f*", "COUNT", "2"}, server.Commands()[1])
+}
+
+func TestRedisClient_HScanCtx(t *testing.T) {
+	// the server never ends the iteration
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []interface{}{[]byte("1"), []interface{}{[]byte("field"), []byte("value")}}
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := GetRedisClient(server.URL()).HScanEachCtx(ctx, "hash", "*", 10, func(string, string) error {
+		calls++
+		cancel()
+		return nil
+	})
+	test.Equal(t, context.Canceled, err)
+	test.Equal(t, 1, calls)
+	test.Equal(t, 1, len(server.Commands()))
+
+	_, err = GetRedisClient(server.URL()).ZScanCtx(ctx, "zset", "*", 10)
+	test.Equal(t, context.Canceled, err)
+	test.Equal(t, 1, len(server.Commands()))
+}
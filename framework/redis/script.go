@@ -0,0 +1,80 @@
+package redisutil
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"sync"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// Eval runs the Lua script with keys as KEYS and args as ARGV
+func (rc *RedisClient) Eval(script string, keys []string, args []interface{}) (interface{}, error) {
+	return rc.EvalCtx(context.Background(), script, keys, args)
+}
+
+// EvalCtx is like Eval but honors the deadline and cancellation of ctx
+func (rc *RedisClient) EvalCtx(ctx context.Context, script string, keys []string, args []interface{}) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	return conn.Do("EVAL", evalArgs(script, keys, args)...)
+}
+
+// evalArgs returns the arguments of EVAL or EVALSHA for script, which is the
+// source or the SHA1 digest of the script
+func evalArgs(script string, keys []string, args []interface{}) []interface{} {
+	evalArgs := make([]interface{}, 0, 2+len(keys)+len(args))
+	evalArgs = append(evalArgs, script, len(keys))
+	for _, key := range keys {
+		evalArgs = append(evalArgs, key)
+	}
+	return append(evalArgs, args...)
+}
+
+// Script is a Lua script which is run by its SHA1 digest with EVALSHA,
+// so the source is only sent when the server does not know it yet
+type Script struct {
+	src string
+
+	once sync.Once
+	sha  string
+}
+
+// NewScript returns a new Script of the Lua source src
+func NewScript(src string) *Script {
+	return &Script{src: src}
+}
+
+// Hash returns the SHA1 digest of the script
+func (s *Script) Hash() string {
+	s.once.Do(func() {
+		sum := sha1.Sum([]byte(s.src))
+		s.sha = hex.EncodeToString(sum[:])
+	})
+	return s.sha
+}
+
+// Run runs the script on rc with keys as KEYS and args as ARGV, using EVALSHA
+// and falling back to EVAL if the server replies NOSCRIPT
+func (s *Script) Run(rc *RedisClient, keys []string, args ...interface{}) (interface{}, error) {
+	return s.RunCtx(context.Background(), rc, keys, args...)
+}
+
+// RunCtx is like Run but honors the deadline and cancellation of ctx
+func (s *Script) RunCtx(ctx context.Context, rc *RedisClient, keys []string, args ...interface{}) (interface{}, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	reply, err := conn.Do("EVALSHA", evalArgs(s.Hash(), keys, args)...)
+	if isNoScript(err) {
+		reply, err = conn.Do("EVAL", evalArgs(s.src, keys, args)...)
+	}
+	return reply, err
+}
+
+// isNoScript returns whether err is the NOSCRIPT error of an unknown script
+func isNoScript(err error) bool {
+	e, ok := err.(redis.Error)
+	return ok && strings.HasPrefix(strings.TrimSpace(string(e)), "NOSCRIPT")
+}
@@ -0,0 +1,66 @@
+package redisutil
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_Eval(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:eval"
+	defer redisClient.Del(key)
+
+	reply, err := redisClient.Eval("return redis.call('SET', KEYS[1], ARGV[1])", []string{key}, []interface{}{"dotweb"})
+	test.Nil(t, err)
+	test.Equal(t, "OK", reply)
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "dotweb", val)
+
+	script := NewScript("return {KEYS[1], ARGV[1], ARGV[2]}")
+	for i := 0; i < 2; i++ {
+		reply, err = script.Run(redisClient, []string{key}, "a", 1)
+		test.Nil(t, err)
+		test.Equal(t, []interface{}{[]byte(key), []byte("a"), []byte("1")}, reply)
+	}
+}
+
+func TestScript_Run(t *testing.T) {
+	var mu sync.Mutex
+	loaded := make(map[string]bool)
+	server := newFakeServer(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "EVALSHA":
+			if !loaded[args[1]] {
+				return errors.New("NOSCRIPT No matching script. Please use EVAL.")
+			}
+			return int64(1)
+		case "EVAL":
+			loaded[NewScript(args[1]).Hash()] = true
+			return int64(1)
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	redisClient := GetRedisClient(server.URL())
+	script := NewScript("return 1")
+	for i := 0; i < 2; i++ {
+		reply, err := script.Run(redisClient, []string{"key"}, "arg")
+		test.Nil(t, err)
+		test.Equal(t, int64(1), reply)
+	}
+
+	commands := server.Commands()
+	test.Equal(t, 3, len(commands))
+	test.Equal(t, []string{"EVALSHA", script.Hash(), "1", "key", "arg"}, commands[0])
+	test.Equal(t, []string{"EVAL", "return 1", "1", "key", "arg"}, commands[1])
+	// the second run uses the cached script
+	test.Equal(t, []string{"EVALSHA", script.Hash(), "1", "key", "arg"}, commands[2])
+}
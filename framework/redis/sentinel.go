@@ -0,0 +1,144 @@
+package redisutil
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// sentinelTimeout is the timeout of the connections to the sentinels
+const sentinelTimeout = 3 * time.Second
+
+// SentinelConfig configures the connections to the master of
+// GetRedisClientFromSentinelWithConfig
+type SentinelConfig struct {
+	Username string     // user of the master with redis 6 ACLs, empty for the default user
+	Password string     // password of the master, empty if none
+	DB       int        // database selected on the master
+	Pool     PoolConfig // pool and timeouts of the connections to the master
+}
+
+// GetRedisClientFromSentinel returns the RedisClient of the master named
+// masterName monitored by the sentinels at sentinelAddrs, like "10.0.1.11:26379".
+// the sentinels are asked for the address of the master each time a
+// connection is dialed, so a failover is followed once the connections to
+// the former master fail. returns an error if no sentinel knows the master.
+// the client is registered per sentinels and master name
+func GetRedisClientFromSentinel(sentinelAddrs []string, masterName string) (*RedisClient, error) {
+	return GetRedisClientFromSentinelWithConfig(sentinelAddrs, masterName, SentinelConfig{})
+}
+
+// GetRedisClientFromSentinelWithConfig is like GetRedisClientFromSentinel
+// with the credentials, database and pool of cfg. the connections are
+// checked to be connected to a master with ROLE when dialed, and at most
+// every second when borrowed, so the connections to a former master demoted
+// to replica by a failover are dropped
+func GetRedisClientFromSentinelWithConfig(sentinelAddrs []string, masterName string, cfg SentinelConfig) (*RedisClient, error) {
+	if len(sentinelAddrs) == 0 {
+		return nil, errors.New("redisutil: no sentinel address")
+	}
+	if _, err := masterAddr(sentinelAddrs, masterName); err != nil {
+		return nil, err
+	}
+	address := "sentinel://" + strings.Join(sentinelAddrs, ",") + "/" + masterName
+	key := address
+	if cfg != (SentinelConfig{}) {
+		key = fmt.Sprintf("%s#db=%d,%s", poolClientKey(address, cfg.Pool), cfg.DB, cfg.Username)
+	}
+	return getOrCreateClient(key, func() *RedisClient {
+		rc := newRedisClient(address, cfg.Pool, func() (redis.Conn, error) {
+			addr, err := masterAddr(sentinelAddrs, masterName)
+			if err != nil {
+				return nil, err
+			}
+			return dialMaster(addr, cfg)
+		})
+		rc.checkConn = checkMasterRole
+		return rc
+	}), nil
+}
+
+// dialMaster dials the master at addr with the credentials and database of cfg
+func dialMaster(addr string, cfg SentinelConfig) (redis.Conn, error) {
+	c, err := redis.Dial("tcp", addr, cfg.Pool.dialOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	var cmds [][]interface{}
+	if cfg.Username != "" {
+		cmds = append(cmds, []interface{}{"AUTH", cfg.Username, cfg.Password})
+	} else if cfg.Password != "" {
+		cmds = append(cmds, []interface{}{"AUTH", cfg.Password})
+	}
+	if cfg.DB != 0 {
+		cmds = append(cmds, []interface{}{"SELECT", cfg.DB})
+	}
+	for _, cmd := range cmds {
+		if _, err := c.Do(cmd[0].(string), cmd[1:]...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+// checkMasterRole returns an error unless conn is connected to a master
+func checkMasterRole(conn redis.Conn) error {
+	values, err := redis.Values(conn.Do("ROLE"))
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return errors.New("redisutil: empty ROLE reply")
+	}
+	role, err := redis.String(values[0], nil)
+	if err != nil {
+		return err
+	}
+	if role != "master" {
+		return fmt.Errorf("redisutil: connected to a %s instead of the master", role)
+	}
+	return nil
+}
+
+// masterAddr asks the sentinels in turn for the address of the master named
+// masterName, the errors of all sentinels are returned if none answers
+func masterAddr(sentinelAddrs []string, masterName string) (string, error) {
+	var errs []string
+	for _, sentinelAddr := range sentinelAddrs {
+		addr, err := querySentinel(sentinelAddr, masterName)
+		if err == nil {
+			return addr, nil
+		}
+		errs = append(errs, sentinelAddr+": "+err.Error())
+	}
+	return "", fmt.Errorf("redisutil: no sentinel returned master %s: %s", masterName, strings.Join(errs, "; "))
+}
+
+// querySentinel asks the sentinel at sentinelAddr for the address of the
+// master named masterName
+func querySentinel(sentinelAddr, masterName string) (string, error) {
+	conn, err := redis.Dial("tcp", sentinelAddr,
+		redis.DialConnectTimeout(sentinelTimeout),
+		redis.DialReadTimeout(sentinelTimeout),
+		redis.DialWriteTimeout(sentinelTimeout))
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	reply, err := redis.Strings(conn.Do("SENTINEL", "get-master-addr-by-name", masterName))
+	if err == ErrNil {
+		return "", errors.New("unknown master")
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(reply) != 2 {
+		return "", fmt.Errorf("unexpected reply %v", reply)
+	}
+	return net.JoinHostPort(reply[0], reply[1]), nil
+}
@@ -0,0 +1,141 @@
+package redisutil
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+// newSentinel returns a fake sentinel which returns the address of the
+// master named "mymaster" by *master
+func newSentinel(t *testing.T, mu *sync.Mutex, master *string) *fakeServer {
+	return newFakeServer(t, func(args []string) interface{} {
+		if len(args) != 3 || strings.ToUpper(args[0]) != "SENTINEL" || args[2] != "mymaster" {
+			return nil
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		host, port, _ := net.SplitHostPort(*master)
+		return []interface{}{[]byte(host), []byte(port)}
+	})
+}
+
+// newFakeMaster returns a fake redis replying *role to ROLE, PONG otherwise
+func newFakeMaster(t *testing.T, mu *sync.Mutex, role *string) *fakeServer {
+	return newFakeServer(t, func(args []string) interface{} {
+		if strings.ToUpper(args[0]) != "ROLE" {
+			return "PONG"
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		return []interface{}{[]byte(*role), int64(0), []interface{}{}}
+	})
+}
+
+func TestGetRedisClientFromSentinel(t *testing.T) {
+	var roleMu sync.Mutex
+	role := "master"
+	master1 := newFakeMaster(t, &roleMu, &role)
+	defer master1.Close()
+	master2 := newFakeMaster(t, &roleMu, &role)
+	defer master2.Close()
+
+	var mu sync.Mutex
+	master := master1.Addr()
+	sentinel := newSentinel(t, &mu, &master)
+	defer sentinel.Close()
+
+	// the first sentinel is down
+	down := newFakeServer(t, nil)
+	downAddr := down.Addr()
+	down.Close()
+
+	redisClient, err := GetRedisClientFromSentinel([]string{downAddr, sentinel.Addr()}, "mymaster")
+	test.Nil(t, err)
+	_, err = redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, 1, master1.Conns())
+
+	// after a failover, new connections are dialed to the new master
+	mu.Lock()
+	master = master2.Addr()
+	mu.Unlock()
+	conn1 := redisClient.GetConn()
+	conn2 := redisClient.GetConn()
+	_, err = conn1.Do("PING")
+	test.Nil(t, err)
+	_, err = conn2.Do("PING")
+	test.Nil(t, err)
+	conn1.Close()
+	conn2.Close()
+	test.Equal(t, 1, master1.Conns())
+	test.Equal(t, 1, master2.Conns())
+}
+
+func TestGetRedisClientFromSentinelWithConfig(t *testing.T) {
+	var mu sync.Mutex
+	role1, role2 := "master", "master"
+	master1 := newFakeMaster(t, &mu, &role1)
+	defer master1.Close()
+	master2 := newFakeMaster(t, &mu, &role2)
+	defer master2.Close()
+	master := master1.Addr()
+	sentinel := newSentinel(t, &mu, &master)
+	defer sentinel.Close()
+
+	redisClient, err := GetRedisClientFromSentinelWithConfig([]string{sentinel.Addr()}, "mymaster",
+		SentinelConfig{Password: "secret", DB: 3, Pool: PoolConfig{ConnectTimeout: time.Second}})
+	test.Nil(t, err)
+	defer redisClient.Close()
+	_, err = redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, [][]string{{"AUTH", "secret"}, {"SELECT", "3"}, {"ROLE"}, {"PING"}}, master1.Commands())
+
+	// after a failover, the pooled connection to the former master is dropped
+	mu.Lock()
+	role1 = "slave"
+	master = master2.Addr()
+	mu.Unlock()
+	time.Sleep(connCheckInterval)
+	_, err = redisClient.Ping()
+	test.Nil(t, err)
+	test.Equal(t, []string{"ROLE"}, master1.Commands()[4])
+	test.Equal(t, 1, master1.Conns())
+	test.Equal(t, 1, master2.Conns())
+	test.Equal(t, []string{"PING"}, master2.Commands()[3])
+
+	// a dialed connection to a replica is rejected
+	mu.Lock()
+	role2 = "slave"
+	mu.Unlock()
+	time.Sleep(connCheckInterval)
+	_, err = redisClient.Ping()
+	test.NotNil(t, err)
+	test.Contains(t, "instead of the master", err.Error())
+}
+
+func TestGetRedisClientFromSentinelUnavailable(t *testing.T) {
+	var mu sync.Mutex
+	master := "127.0.0.1:6379"
+	sentinel := newSentinel(t, &mu, &master)
+	defer sentinel.Close()
+
+	_, err := GetRedisClientFromSentinel([]string{sentinel.Addr()}, "unknown")
+	test.NotNil(t, err)
+	test.Contains(t, "unknown master", err.Error())
+
+	down := newFakeServer(t, nil)
+	downAddr := down.Addr()
+	down.Close()
+	_, err = GetRedisClientFromSentinel([]string{downAddr, sentinel.Addr()}, "unknown")
+	test.NotNil(t, err)
+	test.Contains(t, downAddr+": ", err.Error())
+	test.Contains(t, sentinel.Addr()+": unknown master", err.Error())
+
+	_, err = GetRedisClientFromSentinel(nil, "mymaster")
+	test.NotNil(t, err)
+}
@@ -0,0 +1,60 @@
+//go:build go1.21
+// +build go1.21
+
+package redisutil
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// SetLogger logs each command issued by rc to logger at level, with the
+// name of the command, its count of arguments, its duration and its error.
+// the values of the arguments are never logged, neither is the count of
+// arguments of AUTH and HELLO. a nil logger stops logging
+func (rc *RedisClient) SetLogger(logger *slog.Logger, level slog.Level) *RedisClient {
+	if rc.parent != nil {
+		rc.parent.SetLogger(logger, level)
+		return rc
+	}
+	rc.hookMutex.Lock()
+	rc.logHook = nil
+	if logger != nil {
+		rc.logHook = &slogHook{logger: logger, level: level}
+	}
+	rc.hookMutex.Unlock()
+	return rc
+}
+
+// slogHook logs the commands, see SetLogger
+type slogHook struct {
+	logger *slog.Logger
+	level  slog.Level
+}
+
+func (h *slogHook) Before(cmd string, args []interface{}) {}
+
+func (h *slogHook) After(cmd string, err error, dur time.Duration) {
+	h.afterArgs(cmd, -1, err, dur)
+}
+
+func (h *slogHook) afterArgs(cmd string, args int, err error, dur time.Duration) {
+	if !h.logger.Enabled(context.Background(), h.level) {
+		return
+	}
+	attrs := make([]slog.Attr, 0, 4)
+	attrs = append(attrs, slog.String("cmd", cmd))
+	if args >= 0 && !sensitiveCommands[strings.ToUpper(cmd)] {
+		attrs = append(attrs, slog.Int("args", args))
+	}
+	attrs = append(attrs, slog.Duration("duration", dur))
+	if err != nil {
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	h.logger.LogAttrs(context.Background(), h.level, "redis command", attrs...)
+}
+
+// sensitiveCommands are the commands whose arguments are secrets
+var sensitiveCommands = map[string]bool{"AUTH": true, "HELLO": true}
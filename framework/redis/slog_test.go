@@ -0,0 +1,66 @@
+//go:build go1.21
+// +build go1.21
+
+package redisutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_SetLogger(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		switch strings.ToUpper(args[0]) {
+		case "SET", "AUTH":
+			return "OK"
+		}
+		return errors.New("ERR unknown command")
+	})
+	defer server.Close()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	redisClient := GetRedisClient(server.URL()).SetLogger(logger, slog.LevelDebug)
+	_, err := redisClient.Set("key", "secret value")
+	test.Nil(t, err)
+	_, err = redisClient.Do("AUTH", "user", "password")
+	test.Nil(t, err)
+	_, err = redisClient.Do("UNKNOWN")
+	test.NotNil(t, err)
+	test.Equal(t, false, strings.Contains(buf.String(), "secret"))
+	test.Equal(t, false, strings.Contains(buf.String(), "password"))
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		test.Nil(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	test.Equal(t, 3, len(records))
+	test.Equal(t, "DEBUG", records[0]["level"])
+	test.Equal(t, "redis command", records[0]["msg"])
+	test.Equal(t, "SET", records[0]["cmd"])
+	test.Equal(t, float64(2), records[0]["args"])
+	test.NotNil(t, records[0]["duration"])
+	test.Equal(t, nil, records[0]["error"])
+	test.Equal(t, "AUTH", records[1]["cmd"])
+	test.Equal(t, nil, records[1]["args"])
+	test.Equal(t, "UNKNOWN", records[2]["cmd"])
+	test.Equal(t, "ERR unknown command", records[2]["error"])
+
+	// records below the level of the handler are not logged
+	buf.Reset()
+	redisClient.SetLogger(logger, slog.LevelDebug-1)
+	redisClient.Set("key", "value")
+	test.Equal(t, 0, buf.Len())
+
+	redisClient.SetLogger(nil, slog.LevelInfo)
+	redisClient.Set("key", "value")
+	test.Equal(t, 0, buf.Len())
+}
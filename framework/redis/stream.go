@@ -0,0 +1,252 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// StreamMessage is an entry of a stream
+type StreamMessage struct {
+	ID     string
+	Fields map[string]string
+}
+
+// XAdd appends an entry with fields to stream, id "*" lets the server
+// generate the id. returns the id of the entry
+func (rc *RedisClient) XAdd(stream string, id string, fields map[string]interface{}) (string, error) {
+	return rc.XAddCtx(context.Background(), stream, id, fields)
+}
+
+// XAddCtx is like XAdd but honors the deadline and cancellation of ctx
+func (rc *RedisClient) XAddCtx(ctx context.Context, stream string, id string, fields map[string]interface{}) (string, error) {
+	if len(fields) == 0 {
+		return "", errors.New("redisutil: XAdd requires at least one field")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.String(conn.Do("XADD", redis.Args{stream, id}.AddFlat(fields)...))
+	return val, err
+}
+
+// XLen returns the count of entries of stream, 0 if it does not exists
+func (rc *RedisClient) XLen(stream string) (int64, error) {
+	return rc.XLenCtx(context.Background(), stream)
+}
+
+// XLenCtx is like XLen but honors the deadline and cancellation of ctx
+func (rc *RedisClient) XLenCtx(ctx context.Context, stream string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("XLEN", stream))
+	return val, err
+}
+
+// XRead returns the entries of streams, which maps each stream to the id
+// after which its entries are read, "$" for the entries added from now on.
+// count limits the entries per stream if positive. if block is positive and
+// no entry is available, it waits at most block for one and returns an empty
+// map on timeout
+func (rc *RedisClient) XRead(streams map[string]string, count int64, block time.Duration) (map[string][]StreamMessage, error) {
+	return rc.XReadCtx(context.Background(), streams, count, block)
+}
+
+// XReadCtx is like XRead but honors the deadline and cancellation of ctx,
+// a cancellation aborts the wait of block
+func (rc *RedisClient) XReadCtx(ctx context.Context, streams map[string]string, count int64, block time.Duration) (map[string][]StreamMessage, error) {
+	return rc.blockingStreamRead(ctx, "XREAD", nil, streams, count, block)
+}
+
+// blockingStreamRead issues cmd, XREAD or XREADGROUP, with args followed by
+// the arguments of streamReadArgs. like blockingDoTimeout, if ctx can be
+// canceled and block is positive, the command is issued repeatedly with a
+// short BLOCK until it replies, ctx is done or block elapsed. the ids "$"
+// are resolved to the last id of their stream before, so no entry added
+// between two commands is missed
+func (rc *RedisClient) blockingStreamRead(ctx context.Context, cmd string, args []interface{}, streams map[string]string, count int64, block time.Duration) (map[string][]StreamMessage, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	if ctx.Done() == nil || block <= 0 {
+		return parseStreams(conn.Do(cmd, streamReadArgs(args, streams, count, block)...))
+	}
+	streams, err := resolveLastIDs(conn, streams)
+	if err != nil {
+		return nil, err
+	}
+	for waited := time.Duration(0); waited < block; {
+		poll := blockingPollTimeout * time.Second
+		if poll > block-waited {
+			poll = block - waited
+		}
+		result, err := parseStreams(conn.Do(cmd, streamReadArgs(args, streams, count, poll)...))
+		if err != nil || len(result) > 0 {
+			return result, err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		waited += poll
+	}
+	return make(map[string][]StreamMessage), nil
+}
+
+// resolveLastIDs returns streams with the ids "$" replaced by the id of the
+// last entry of their stream
+func resolveLastIDs(conn redis.Conn, streams map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(streams))
+	for name, id := range streams {
+		if id == "$" {
+			entries, err := redis.Values(conn.Do("XREVRANGE", name, "+", "-", "COUNT", 1))
+			if err != nil {
+				return nil, err
+			}
+			messages, err := parseStreamMessages(entries)
+			if err != nil {
+				return nil, err
+			}
+			id = "0-0"
+			if len(messages) > 0 {
+				id = messages[0].ID
+			}
+		}
+		resolved[name] = id
+	}
+	return resolved, nil
+}
+
+// streamReadArgs returns the arguments of XREAD or XREADGROUP following args
+func streamReadArgs(args []interface{}, streams map[string]string, count int64, block time.Duration) []interface{} {
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+	if block > 0 {
+		ms := int64(block / time.Millisecond)
+		if ms == 0 {
+			ms = 1
+		}
+		args = append(args, "BLOCK", ms)
+	}
+	names := make([]string, 0, len(streams))
+	for name := range streams {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	args = append(args, "STREAMS")
+	for _, name := range names {
+		args = append(args, name)
+	}
+	for _, name := range names {
+		args = append(args, streams[name])
+	}
+	return args
+}
+
+// parseStreams parses the reply of XREAD or XREADGROUP,
+// a nil reply of a timeout is returned as an empty map
+func parseStreams(reply interface{}, err error) (map[string][]StreamMessage, error) {
+	result := make(map[string][]StreamMessage)
+	if err == ErrNil || (err == nil && reply == nil) {
+		return result, nil
+	}
+	streams, err := redis.Values(reply, err)
+	if err != nil {
+		return nil, err
+	}
+	for _, stream := range streams {
+		var name string
+		var entries []interface{}
+		values, err := redis.Values(stream, nil)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := redis.Scan(values, &name, &entries); err != nil {
+			return nil, err
+		}
+		messages, err := parseStreamMessages(entries)
+		if err != nil {
+			return nil, err
+		}
+		result[name] = messages
+	}
+	return result, nil
+}
+
+// parseStreamMessages parses a list of stream entries, each one being an id
+// and its fields. the fields are nil for an entry deleted meanwhile
+func parseStreamMessages(entries []interface{}) ([]StreamMessage, error) {
+	messages := make([]StreamMessage, 0, len(entries))
+	for _, entry := range entries {
+		values, err := redis.Values(entry, nil)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 2 {
+			return nil, errors.New("redisutil: unexpected stream entry")
+		}
+		id, err := redis.String(values[0], nil)
+		if err != nil {
+			return nil, err
+		}
+		msg := StreamMessage{ID: id}
+		if values[1] != nil {
+			if msg.Fields, err = redis.StringMap(values[1], nil); err != nil {
+				return nil, err
+			}
+		}
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+// XGroupCreate creates the consumer group of stream, delivering the entries
+// after startID, "$" for the entries added from now on. mkstream creates the
+// stream if it does not exists. it is not an error if the group exists
+func (rc *RedisClient) XGroupCreate(stream string, group string, startID string, mkstream bool) error {
+	return rc.XGroupCreateCtx(context.Background(), stream, group, startID, mkstream)
+}
+
+// XGroupCreateCtx is like XGroupCreate but honors the deadline and cancellation of ctx
+func (rc *RedisClient) XGroupCreateCtx(ctx context.Context, stream string, group string, startID string, mkstream bool) error {
+	args := redis.Args{"CREATE", stream, group, startID}
+	if mkstream {
+		args = append(args, "MKSTREAM")
+	}
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	_, err := conn.Do("XGROUP", args...)
+	if err != nil && strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return nil
+	}
+	return err
+}
+
+// XReadGroup reads the entries of streams as consumer of group, like XRead.
+// the id ">" reads the entries never delivered to the group, other ids read
+// the pending entries of consumer. the entries read are pending until XAck
+func (rc *RedisClient) XReadGroup(group string, consumer string, streams map[string]string, count int64, block time.Duration) (map[string][]StreamMessage, error) {
+	return rc.XReadGroupCtx(context.Background(), group, consumer, streams, count, block)
+}
+
+// XReadGroupCtx is like XReadGroup but honors the deadline and cancellation
+// of ctx, a cancellation aborts the wait of block
+func (rc *RedisClient) XReadGroupCtx(ctx context.Context, group string, consumer string, streams map[string]string, count int64, block time.Duration) (map[string][]StreamMessage, error) {
+	return rc.blockingStreamRead(ctx, "XREADGROUP", []interface{}{"GROUP", group, consumer}, streams, count, block)
+}
+
+// XAck acknowledges the entries ids of stream read by group, removing them
+// from the pending entries. returns the count of entries acknowledged
+func (rc *RedisClient) XAck(stream string, group string, ids ...string) (int64, error) {
+	return rc.XAckCtx(context.Background(), stream, group, ids...)
+}
+
+// XAckCtx is like XAck but honors the deadline and cancellation of ctx
+func (rc *RedisClient) XAckCtx(ctx context.Context, stream string, group string, ids ...string) (int64, error) {
+	conn := rc.getConnContext(ctx)
+	defer conn.Close()
+	val, err := redis.Int64(conn.Do("XACK", redis.Args{stream, group}.AddFlat(ids)...))
+	return val, err
+}
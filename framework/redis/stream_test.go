@@ -0,0 +1,158 @@
+package redisutil
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRedisClient_XAddXRead(t *testing.T) {
+	redisClient := newTestClient(t)
+	stream := "dotweb:test:stream"
+	redisClient.Del(stream)
+	defer redisClient.Del(stream)
+
+	id1, err := redisClient.XAdd(stream, "*", map[string]interface{}{"event": "login", "user": "1"})
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	id2, err := redisClient.XAdd(stream, "*", map[string]interface{}{"event": "logout"})
+	test.Nil(t, err)
+	test.NotEqual(t, id1, id2)
+	length, err := redisClient.XLen(stream)
+	test.Nil(t, err)
+	test.Equal(t, int64(2), length)
+
+	result, err := redisClient.XRead(map[string]string{stream: "0"}, 0, 0)
+	test.Nil(t, err)
+	test.Equal(t, map[string][]StreamMessage{stream: {
+		{ID: id1, Fields: map[string]string{"event": "login", "user": "1"}},
+		{ID: id2, Fields: map[string]string{"event": "logout"}},
+	}}, result)
+
+	result, err = redisClient.XRead(map[string]string{stream: id1}, 1, 0)
+	test.Nil(t, err)
+	test.Equal(t, 1, len(result[stream]))
+	test.Equal(t, id2, result[stream][0].ID)
+
+	// a blocking read returns empty on timeout
+	start := time.Now()
+	result, err = redisClient.XRead(map[string]string{stream: id2}, 0, 100*time.Millisecond)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(result))
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond {
+		t.Errorf("blocking read returned after %s", elapsed)
+	}
+}
+
+func TestRedisClient_XReadArgs(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return nil
+	})
+	defer server.Close()
+
+	result, err := GetRedisClient(server.URL()).XRead(map[string]string{"b": "$", "a": "0-1"}, 10, 2*time.Second)
+	test.Nil(t, err)
+	test.Equal(t, 0, len(result))
+	test.Equal(t, []string{"XREAD", "COUNT", "10", "BLOCK", "2000", "STREAMS", "a", "b", "0-1", "$"}, server.Commands()[0])
+}
+
+func TestRedisClient_XReadGroup(t *testing.T) {
+	redisClient := newTestClient(t)
+	stream := "dotweb:test:stream:group"
+	redisClient.Del(stream)
+	defer redisClient.Del(stream)
+
+	err := redisClient.XGroupCreate(stream, "workers", "$", true)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	// creating the group again is not an error
+	test.Nil(t, redisClient.XGroupCreate(stream, "workers", "$", true))
+
+	id1, err := redisClient.XAdd(stream, "*", map[string]interface{}{"job": "1"})
+	test.Nil(t, err)
+	id2, err := redisClient.XAdd(stream, "*", map[string]interface{}{"job": "2"})
+	test.Nil(t, err)
+
+	result, err := redisClient.XReadGroup("workers", "worker1", map[string]string{stream: ">"}, 10, 0)
+	test.Nil(t, err)
+	test.Equal(t, map[string][]StreamMessage{stream: {
+		{ID: id1, Fields: map[string]string{"job": "1"}},
+		{ID: id2, Fields: map[string]string{"job": "2"}},
+	}}, result)
+	test.Equal(t, int64(2), pendingCount(t, redisClient, stream, "workers"))
+
+	acked, err := redisClient.XAck(stream, "workers", id1)
+	test.Nil(t, err)
+	test.Equal(t, int64(1), acked)
+	test.Equal(t, int64(1), pendingCount(t, redisClient, stream, "workers"))
+
+	// the unacknowledged entry is still pending for the consumer
+	result, err = redisClient.XReadGroup("workers", "worker1", map[string]string{stream: "0"}, 0, 0)
+	test.Nil(t, err)
+	test.Equal(t, 1, len(result[stream]))
+	test.Equal(t, id2, result[stream][0].ID)
+}
+
+func TestRedisClient_XReadCtx(t *testing.T) {
+	redisClient := newTestClient(t)
+	stream := "dotweb:test:stream:ctx"
+	redisClient.Del(stream)
+	defer redisClient.Del(stream)
+	_, err := redisClient.XAdd(stream, "*", map[string]interface{}{"event": "old"})
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+
+	// an entry added while polling is read, the entries before "$" are not
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(1500*time.Millisecond, func() {
+		redisClient.XAdd(stream, "*", map[string]interface{}{"event": "new"})
+	})
+	result, err := redisClient.XReadCtx(ctx, map[string]string{stream: "$"}, 0, 5*time.Second)
+	test.Nil(t, err)
+	test.Equal(t, 1, len(result[stream]))
+	test.Equal(t, map[string]string{"event": "new"}, result[stream][0].Fields)
+
+	// a cancellation aborts the wait
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = redisClient.XReadCtx(ctx, map[string]string{stream: "$"}, 0, time.Minute)
+	test.Equal(t, context.Canceled, err)
+	if elapsed := time.Since(start); elapsed > 3*blockingPollTimeout*time.Second {
+		t.Errorf("XReadCtx returned after %v", elapsed)
+	}
+}
+
+func TestRedisClient_XReadGroupCtxCancel(t *testing.T) {
+	redisClient := newTestClient(t)
+	stream := "dotweb:test:stream:group:ctx"
+	redisClient.Del(stream)
+	defer redisClient.Del(stream)
+	err := redisClient.XGroupCreateCtx(context.Background(), stream, "workers", "$", true)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = redisClient.XReadGroupCtx(ctx, "workers", "worker1", map[string]string{stream: ">"}, 0, time.Minute)
+	test.Equal(t, context.Canceled, err)
+	if elapsed := time.Since(start); elapsed > 3*blockingPollTimeout*time.Second {
+		t.Errorf("XReadGroupCtx returned after %v", elapsed)
+	}
+}
+
+// pendingCount returns the count of pending entries of group
+func pendingCount(t *testing.T, redisClient *RedisClient, stream string, group string) int64 {
+	conn := redisClient.GetConn()
+	defer conn.Close()
+	summary, err := redis.Values(conn.Do("XPENDING", stream, group))
+	test.Nil(t, err)
+	count, err := redis.Int64(summary[0], nil)
+	test.Nil(t, err)
+	return count
+}
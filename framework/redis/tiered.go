@@ -0,0 +1,129 @@
+package redisutil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// TieredClient caches the values of a RedisClient in memory, so reading a
+// hot key does not need a round trip. the local entries expire after their
+// own ttl, whatever the expire of the key in redis, and the least recently
+// used entry is evicted when the local cache is full. the writes of other
+// processes are seen once the local entry expired
+type TieredClient struct {
+	rc   *RedisClient
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+}
+
+type tieredEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+// NewTieredClient returns a TieredClient keeping at most localSize values of
+// rc in memory for localTTL
+func NewTieredClient(rc *RedisClient, localSize int, localTTL time.Duration) *TieredClient {
+	return &TieredClient{
+		rc:      rc,
+		size:    localSize,
+		ttl:     localTTL,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the value of key from memory, or from redis if it is not
+// cached, in which case it is cached. returns ErrNil if key does not exists
+func (c *TieredClient) Get(key string) (string, error) {
+	if value, ok := c.local(key); ok {
+		return value, nil
+	}
+	value, err := c.rc.Get(key)
+	if err != nil {
+		return "", err
+	}
+	c.store(key, value)
+	return value, nil
+}
+
+// Set sets the value of key in redis and in memory
+func (c *TieredClient) Set(key string, value string) error {
+	if _, err := c.rc.Set(key, value); err != nil {
+		c.Invalidate(key)
+		return err
+	}
+	c.store(key, value)
+	return nil
+}
+
+// Del deletes key in redis and in memory
+func (c *TieredClient) Del(key string) error {
+	c.Invalidate(key)
+	_, err := c.rc.Del(key)
+	return err
+}
+
+// Invalidate removes key from memory, e.g. after it was changed by another process
+func (c *TieredClient) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+}
+
+// Len returns the count of values cached in memory, including the expired
+// ones not evicted yet
+func (c *TieredClient) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lru.Len()
+}
+
+// local returns the value of key cached in memory unless it is expired
+func (c *TieredClient) local(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if !ok {
+		return "", false
+	}
+	entry := elem.Value.(*tieredEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return "", false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.value, true
+}
+
+// store caches value in memory, evicting the least recently used values
+func (c *TieredClient) store(key string, value string) {
+	if c.size <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &tieredEntry{key: key, value: value, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *TieredClient) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*tieredEntry).key)
+}
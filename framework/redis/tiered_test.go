@@ -0,0 +1,89 @@
+package redisutil
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestTieredClient(t *testing.T) {
+	var mu sync.Mutex
+	values := map[string]string{"a": "1", "b": "2", "c": "3"}
+	server := newFakeServer(t, func(args []string) interface{} {
+		mu.Lock()
+		defer mu.Unlock()
+		switch strings.ToUpper(args[0]) {
+		case "GET":
+			if value, ok := values[args[1]]; ok {
+				return []byte(value)
+			}
+			return nil
+		case "SET":
+			values[args[1]] = args[2]
+			return "OK"
+		case "DEL":
+			delete(values, args[1])
+			return int64(1)
+		}
+		return nil
+	})
+	defer server.Close()
+
+	c := NewTieredClient(GetRedisClient(server.URL()), 2, time.Minute)
+	val, err := c.Get("a")
+	test.Nil(t, err)
+	test.Equal(t, "1", val)
+	// the second Get is served from memory
+	gets := len(server.Commands())
+	val, err = c.Get("a")
+	test.Nil(t, err)
+	test.Equal(t, "1", val)
+	test.Equal(t, gets, len(server.Commands()))
+
+	// Set updates the local copy
+	test.Nil(t, c.Set("a", "one"))
+	val, err = c.Get("a")
+	test.Nil(t, err)
+	test.Equal(t, "one", val)
+	test.Equal(t, gets+1, len(server.Commands()))
+
+	// the least recently used value is evicted
+	c.Get("b")
+	c.Get("a")
+	c.Get("c")
+	test.Equal(t, 2, c.Len())
+	gets = len(server.Commands())
+	c.Get("a")
+	test.Equal(t, gets, len(server.Commands()))
+	c.Get("b")
+	test.Equal(t, gets+1, len(server.Commands()))
+
+	// missing keys are not cached
+	_, err = c.Get("missing")
+	test.Equal(t, ErrNil, err)
+	test.Nil(t, c.Del("a"))
+	_, err = c.Get("a")
+	test.Equal(t, ErrNil, err)
+}
+
+func TestTieredClient_LocalTTL(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return []byte("value")
+	})
+	defer server.Close()
+
+	c := NewTieredClient(GetRedisClient(server.URL()), 10, 50*time.Millisecond)
+	_, err := c.Get("key")
+	test.Nil(t, err)
+	_, err = c.Get("key")
+	test.Nil(t, err)
+	test.Equal(t, 1, len(server.Commands()))
+
+	time.Sleep(60 * time.Millisecond)
+	_, err = c.Get("key")
+	test.Nil(t, err)
+	test.Equal(t, 2, len(server.Commands()))
+}
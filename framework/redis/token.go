@@ -0,0 +1,42 @@
+package redisutil
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+)
+
+// newToken returns a random hex string of 32 characters
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// IssueToken generates a random one-time token valid for ttl, the token is
+// stored at key prefix+token. use ConsumeToken to verify it
+func (rc *RedisClient) IssueToken(prefix string, ttl time.Duration) (token string, err error) {
+	token, err = newToken()
+	if err != nil {
+		return "", err
+	}
+	conn := rc.getConn()
+	defer conn.Close()
+	_, err = conn.Do("PSETEX", prefix+token, int64(ttl/time.Millisecond), 1)
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// ConsumeToken atomically deletes the token issued by IssueToken with prefix,
+// returns true only for the first call before the token expires
+func (rc *RedisClient) ConsumeToken(prefix, token string) (bool, error) {
+	_, err := rc.GetDel(prefix + token)
+	if err == ErrNil {
+		return false, nil
+	}
+	return err == nil, err
+}
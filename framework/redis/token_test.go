@@ -0,0 +1,46 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_IssueConsumeToken(t *testing.T) {
+	redisClient := newTestClient(t)
+	prefix := "dotweb:test:token:"
+
+	token, err := redisClient.IssueToken(prefix, time.Minute)
+	test.Nil(t, err)
+	test.Equal(t, 32, len(token))
+	other, err := redisClient.IssueToken(prefix, time.Minute)
+	test.Nil(t, err)
+	test.NotEqual(t, token, other)
+
+	ok, err := redisClient.ConsumeToken(prefix, token)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, true, ok)
+	ok, err = redisClient.ConsumeToken(prefix, token)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+
+	ok, err = redisClient.ConsumeToken(prefix, "unknown")
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+	redisClient.Del(prefix + other)
+}
+
+func TestRedisClient_ConsumeToken_Expired(t *testing.T) {
+	redisClient := newTestClient(t)
+	prefix := "dotweb:test:token:"
+
+	token, err := redisClient.IssueToken(prefix, 50*time.Millisecond)
+	test.Nil(t, err)
+	time.Sleep(200 * time.Millisecond)
+	ok, err := redisClient.ConsumeToken(prefix, token)
+	skipIfUnsupported(t, err)
+	test.Nil(t, err)
+	test.Equal(t, false, ok)
+}
@@ -0,0 +1,106 @@
+package redisutil
+
+import (
+	"context"
+	"errors"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var (
+	// ErrTxAborted is returned by Transaction.Exec if a watched key was changed
+	ErrTxAborted = errors.New("redisutil: transaction aborted, a watched key was changed")
+
+	errTxDone = errors.New("redisutil: transaction has already been committed or discarded")
+)
+
+// Transaction executes commands atomically with MULTI/EXEC on a connection
+// pinned until Exec or Discard
+type Transaction struct {
+	conn  redis.Conn
+	multi bool
+	done  bool
+}
+
+// Begin returns a new Transaction holding a connection from the pool,
+// MULTI is sent with the first command, so keys can be watched before
+func (rc *RedisClient) Begin() (*Transaction, error) {
+	return rc.BeginCtx(context.Background())
+}
+
+// BeginCtx is like Begin but honors the deadline and cancellation of ctx
+func (rc *RedisClient) BeginCtx(ctx context.Context) (*Transaction, error) {
+	conn := rc.getConnContext(ctx)
+	if err := conn.Err(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Transaction{conn: conn}, nil
+}
+
+// Watch watches keys, so Exec returns ErrTxAborted if any of them is changed
+// before. it must be called before the first command of the transaction
+func (t *Transaction) Watch(keys ...string) error {
+	if t.done {
+		return errTxDone
+	}
+	if t.multi {
+		return errors.New("redisutil: Watch must be called before the first command of the transaction")
+	}
+	_, err := t.conn.Do("WATCH", redis.Args{}.AddFlat(keys)...)
+	return err
+}
+
+// Send queues the command cmd with args in the transaction
+func (t *Transaction) Send(cmd string, args ...interface{}) error {
+	if t.done {
+		return errTxDone
+	}
+	if !t.multi {
+		if err := t.conn.Send("MULTI"); err != nil {
+			return err
+		}
+		t.multi = true
+	}
+	return t.conn.Send(cmd, args...)
+}
+
+// Exec commits the transaction and returns the replies of the queued commands
+// in order, the reply of a command which failed is its redis.Error.
+// returns ErrTxAborted if a watched key was changed.
+// the connection is returned to the pool
+func (t *Transaction) Exec() ([]interface{}, error) {
+	if t.done {
+		return nil, errTxDone
+	}
+	t.done = true
+	defer t.conn.Close()
+	if !t.multi {
+		if err := t.conn.Send("MULTI"); err != nil {
+			return nil, err
+		}
+	}
+	reply, err := t.conn.Do("EXEC")
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrTxAborted
+	}
+	return redis.Values(reply, nil)
+}
+
+// Discard aborts the transaction and returns the connection to the pool
+func (t *Transaction) Discard() error {
+	if t.done {
+		return errTxDone
+	}
+	t.done = true
+	defer t.conn.Close()
+	if !t.multi {
+		_, err := t.conn.Do("UNWATCH")
+		return err
+	}
+	_, err := t.conn.Do("DISCARD")
+	return err
+}
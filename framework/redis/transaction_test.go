@@ -0,0 +1,62 @@
+package redisutil
+
+import (
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestTransaction(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:tx"
+	counter := "dotweb:test:tx:counter"
+	redisClient.Del(counter)
+	defer redisClient.Del(key)
+	defer redisClient.Del(counter)
+
+	tx, err := redisClient.Begin()
+	test.Nil(t, err)
+	test.Nil(t, tx.Watch(key))
+	test.Nil(t, tx.Send("SET", key, "dotweb"))
+	test.Nil(t, tx.Send("INCR", counter))
+	test.NotNil(t, tx.Watch(counter))
+	replies, err := tx.Exec()
+	test.Nil(t, err)
+	test.Equal(t, []interface{}{"OK", int64(1)}, replies)
+	_, err = tx.Exec()
+	test.NotNil(t, err)
+
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "dotweb", val)
+
+	tx, err = redisClient.Begin()
+	test.Nil(t, err)
+	test.Nil(t, tx.Send("INCR", counter))
+	test.Nil(t, tx.Discard())
+	val, err = redisClient.Get(counter)
+	test.Nil(t, err)
+	test.Equal(t, "1", val)
+}
+
+func TestTransaction_WatchAborted(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:tx:watch"
+	defer redisClient.Del(key)
+	_, err := redisClient.Set(key, "1")
+	test.Nil(t, err)
+
+	tx, err := redisClient.Begin()
+	test.Nil(t, err)
+	test.Nil(t, tx.Watch(key))
+	// another client changes the watched key
+	_, err = redisClient.Set(key, "2")
+	test.Nil(t, err)
+	test.Nil(t, tx.Send("SET", key, "3"))
+	_, err = tx.Exec()
+	test.Equal(t, ErrTxAborted, err)
+
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "2", val)
+}
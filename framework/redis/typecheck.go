@@ -0,0 +1,202 @@
+package redisutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// typeCacheTTL is how long the type of a key is cached in CheckType mode
+const typeCacheTTL = time.Second
+
+// commandTypes maps the commands to the type of value they expect at
+// their first argument
+var commandTypes = map[string]string{}
+
+func init() {
+	for typ, commands := range map[string][]string{
+		"string": {"GET", "GETSET", "GETDEL", "APPEND", "INCR", "INCRBY", "INCRBYFLOAT", "DECR", "DECRBY",
+			"STRLEN", "GETRANGE", "SETRANGE", "GETBIT", "SETBIT", "BITCOUNT", "BITPOS"},
+		"hash": {"HGET", "HSET", "HSETNX", "HMGET", "HMSET", "HGETALL", "HDEL", "HLEN", "HKEYS", "HVALS",
+			"HEXISTS", "HINCRBY", "HINCRBYFLOAT", "HSTRLEN", "HSCAN"},
+		"list": {"LPUSH", "LPUSHX", "RPUSH", "RPUSHX", "LPOP", "RPOP", "LLEN", "LRANGE", "LINDEX", "LINSERT",
+			"LREM", "LSET", "LTRIM", "LPOS", "RPOPLPUSH"},
+		"set": {"SADD", "SREM", "SCARD", "SMEMBERS", "SISMEMBER", "SPOP", "SRANDMEMBER", "SMOVE", "SSCAN"},
+		"zset": {"ZADD", "ZREM", "ZCARD", "ZSCORE", "ZINCRBY", "ZRANGE", "ZREVRANGE", "ZRANGEBYSCORE",
+			"ZREVRANGEBYSCORE", "ZRANK", "ZREVRANK", "ZCOUNT", "ZREMRANGEBYSCORE", "ZREMRANGEBYRANK", "ZSCAN"},
+	} {
+		for _, command := range commands {
+			commandTypes[command] = typ
+		}
+	}
+}
+
+// ErrWrongType is returned in CheckType mode when a command is issued against
+// a key holding another type of value
+type ErrWrongType struct {
+	Key      string
+	Command  string
+	Expected string // type expected by the command
+	Actual   string // type of the value held by the key
+}
+
+func (e *ErrWrongType) Error() string {
+	return fmt.Sprintf("redisutil: %s expects a %s but key %q holds a %s", e.Command, e.Expected, e.Key, e.Actual)
+}
+
+// typeCache caches the type of keys of each database for a short time
+type typeCache struct {
+	mu    sync.Mutex
+	types map[typeCacheKey]cachedType
+}
+
+// typeCacheKey is a key of the database db, -1 for the one of the address
+type typeCacheKey struct {
+	db  int32
+	key string
+}
+
+type cachedType struct {
+	typ     string
+	expires time.Time
+}
+
+func (c *typeCache) get(key typeCacheKey) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.types[key]
+	if !ok || time.Now().After(entry.expires) {
+		return "", false
+	}
+	return entry.typ, true
+}
+
+func (c *typeCache) set(key typeCacheKey, typ string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.types == nil {
+		c.types = make(map[typeCacheKey]cachedType)
+	}
+	c.types[key] = cachedType{typ: typ, expires: time.Now().Add(typeCacheTTL)}
+}
+
+func (c *typeCache) remove(key typeCacheKey) {
+	c.mu.Lock()
+	delete(c.types, key)
+	c.mu.Unlock()
+}
+
+func (c *typeCache) clear() {
+	c.mu.Lock()
+	c.types = nil
+	c.mu.Unlock()
+}
+
+// SetCheckType enables or disables the CheckType mode, disabled by default.
+// in CheckType mode, commands expecting a type of value first check the
+// cached TYPE of their key and return an *ErrWrongType instead of the
+// WRONGTYPE error of the server. it costs extra round trips, so it is meant
+// for development. commands queued with Send are not checked
+func (rc *RedisClient) SetCheckType(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	if rc.parent != nil {
+		rc.parent.SetCheckType(enabled)
+		return
+	}
+	atomic.StoreInt32(&rc.checkType, v)
+}
+
+// typeCheckedConn wraps conn to check the value types when CheckType mode is
+// enabled, returns conn otherwise
+func (rc *RedisClient) typeCheckedConn(conn redis.Conn) redis.Conn {
+	if atomic.LoadInt32(&rc.checkType) == 1 {
+		return &typeCheckConn{Conn: conn, types: &rc.types, db: atomic.LoadInt32(&rc.db)}
+	}
+	return conn
+}
+
+// typeCheckConn checks the type of the key before issuing a command
+type typeCheckConn struct {
+	redis.Conn
+	types *typeCache
+	db    int32 // database of the connection, -1 for the one of the address
+}
+
+func (c *typeCheckConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if commandName == "" {
+		return c.Conn.Do(commandName, args...)
+	}
+	command := strings.ToUpper(commandName)
+	expected, ok := commandTypes[command]
+	key, isString := "", false
+	if len(args) > 0 {
+		key, isString = args[0].(string)
+	}
+	if !ok || !isString {
+		// the command may change or remove the values of its keys
+		reply, err := c.Conn.Do(commandName, args...)
+		c.invalidate(command, args, err)
+		return reply, err
+	}
+
+	cacheKey := typeCacheKey{db: c.db, key: key}
+	actual, ok := c.types.get(cacheKey)
+	if !ok {
+		typ, err := redis.String(c.Conn.Do("TYPE", key))
+		if err != nil {
+			return nil, err
+		}
+		actual = typ
+		c.types.set(cacheKey, actual)
+	}
+	if actual != "none" && actual != expected {
+		return nil, &ErrWrongType{Key: key, Command: command, Expected: expected, Actual: actual}
+	}
+	reply, err := c.Conn.Do(commandName, args...)
+	// other keys like the destination of SMOVE may be written too
+	c.invalidate(command, args, err)
+	if err == nil {
+		c.types.set(cacheKey, expected)
+	}
+	return reply, err
+}
+
+// invalidate forgets the cached types of the keys command was issued with,
+// all cached types if the keys of command are not known, like for a script
+func (c *typeCheckConn) invalidate(command string, args []interface{}, err error) {
+	if command == "SELECT" {
+		if err == nil && len(args) > 0 {
+			if db, err := strconv.Atoi(keyString(args[0])); err == nil {
+				c.db = int32(db)
+			}
+		}
+		return
+	}
+	pos, ok := keyPositionsOf(command)
+	if !ok {
+		c.types.clear()
+		return
+	}
+	for i, n := pos.first, 0; i < len(args)-pos.skipLast && (pos.max == 0 || n < pos.max); i, n = i+pos.step, n+1 {
+		c.types.remove(typeCacheKey{db: c.db, key: keyString(args[i])})
+	}
+}
+
+// keyString returns the key argument key as sent to the server
+func keyString(key interface{}) string {
+	switch k := key.(type) {
+	case string:
+		return k
+	case []byte:
+		return string(k)
+	}
+	return fmt.Sprint(key)
+}
@@ -0,0 +1,96 @@
+package redisutil
+
+import (
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+	"github.com/garyburd/redigo/redis"
+)
+
+func TestRedisClient_CheckType(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:checktype"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+	_, err := redisClient.Set(key, "value")
+	test.Nil(t, err)
+
+	redisClient.SetCheckType(true)
+	defer redisClient.SetCheckType(false)
+
+	_, err = redisClient.HGet(key, "field")
+	wrongType, ok := err.(*ErrWrongType)
+	if !ok {
+		t.Fatalf("expected *ErrWrongType, got %#v", err)
+	}
+	test.Equal(t, &ErrWrongType{Key: key, Command: "HGET", Expected: "hash", Actual: "string"}, wrongType)
+	test.Contains(t, "holds a string", err.Error())
+
+	// the value of a matching type goes through
+	val, err := redisClient.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, "value", val)
+
+	// deleting the key forgets its cached type
+	_, err = redisClient.Del(key)
+	test.Nil(t, err)
+	test.Nil(t, redisClient.HSet(key, "field", "value"))
+	_, err = redisClient.Get(key)
+	test.Equal(t, &ErrWrongType{Key: key, Command: "GET", Expected: "string", Actual: "hash"}, err)
+}
+
+func TestRedisClient_CheckTypeInvalidate(t *testing.T) {
+	redisClient := newTestClient(t)
+	keys := []string{"dotweb:test:checktype:a", "dotweb:test:checktype:b"}
+	for _, key := range keys {
+		redisClient.Del(key)
+		defer redisClient.Del(key)
+	}
+	redisClient.SetCheckType(true)
+	defer redisClient.SetCheckType(false)
+
+	// every key deleted by a DEL forgets its cached type
+	test.Nil(t, redisClient.HSet(keys[1], "field", "value"))
+	_, err := redisClient.HGet(keys[1], "field")
+	test.Nil(t, err)
+	_, err = redisClient.Del(keys[0], keys[1])
+	test.Nil(t, err)
+	_, err = redisClient.RPush(keys[1], "value")
+	test.Nil(t, err)
+
+	// the destination of RENAME too
+	test.Nil(t, redisClient.HSet(keys[0], "field", "value"))
+	test.Nil(t, redisClient.Rename(keys[0], keys[1]))
+	_, err = redisClient.HGet(keys[1], "field")
+	test.Nil(t, err)
+}
+
+func TestTypeCheckConn_DB(t *testing.T) {
+	types := &typeCache{}
+	types.set(typeCacheKey{db: 0, key: "key"}, "hash")
+	server := newFakeServer(t, func(args []string) interface{} {
+		if args[0] == "TYPE" {
+			return "string"
+		}
+		return "OK"
+	})
+	defer server.Close()
+	conn, err := redis.DialURL(server.URL())
+	test.Nil(t, err)
+	c := &typeCheckConn{Conn: conn, types: types, db: 0}
+	defer c.Close()
+
+	// the types cached for another database are not used
+	_, err = c.Do("SELECT", 1)
+	test.Nil(t, err)
+	_, err = c.Do("GET", "key")
+	test.Nil(t, err)
+	typ, _ := types.get(typeCacheKey{db: 0, key: "key"})
+	test.Equal(t, "hash", typ)
+
+	// a script may write any key
+	_, err = c.Do("EVAL", "return 1", 0)
+	test.Nil(t, err)
+	_, ok := types.get(typeCacheKey{db: 0, key: "key"})
+	test.Equal(t, false, ok)
+}
@@ -0,0 +1,38 @@
+//go:build go1.18
+// +build go1.18
+
+package redisutil
+
+// Cache stores values of type T encoded as JSON, with an expire of ttl seconds
+type Cache[T any] struct {
+	rc  *RedisClient
+	ttl int64
+}
+
+// NewCache returns a Cache storing its values in rc with an expire of ttl
+// seconds, no expire if ttl is not positive
+func NewCache[T any](rc *RedisClient, ttl int64) *Cache[T] {
+	return &Cache[T]{rc: rc, ttl: ttl}
+}
+
+// Set stores v at key
+func (c *Cache[T]) Set(key string, v T) error {
+	if c.ttl > 0 {
+		return c.rc.SetJSONWithExpire(key, v, c.ttl)
+	}
+	return c.rc.SetJSON(key, v)
+}
+
+// Get returns the value stored at key, found is false with the zero value
+// of T if key does not exists
+func (c *Cache[T]) Get(key string) (v T, found bool, err error) {
+	err = c.rc.GetJSON(key, &v)
+	if err == ErrNil {
+		return v, false, nil
+	}
+	if err != nil {
+		var zero T
+		return zero, false, err
+	}
+	return v, true, nil
+}
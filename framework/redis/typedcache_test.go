@@ -0,0 +1,52 @@
+//go:build go1.18
+// +build go1.18
+
+package redisutil
+
+import (
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestCache(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:typedcache"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	users := NewCache[jsonTestUser](redisClient, 100)
+	user, found, err := users.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, false, found)
+	test.Equal(t, "", user.Name)
+	test.Equal(t, 0, len(user.Tags))
+
+	stored := jsonTestUser{Name: "dotweb", Tags: []string{"go", "web"}}
+	stored.Address.City = "Hangzhou"
+	test.Nil(t, users.Set(key, stored))
+	user, found, err = users.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, true, found)
+	test.Equal(t, stored, user)
+	ttl, err := redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, true, ttl > 90 && ttl <= 100)
+
+	// a value which is not a T is an error
+	_, err = redisClient.Set(key, "not json")
+	test.Nil(t, err)
+	_, found, err = users.Get(key)
+	test.NotNil(t, err)
+	test.Equal(t, false, found)
+
+	counts := NewCache[int](redisClient, 0)
+	test.Nil(t, counts.Set(key, 42))
+	count, found, err := counts.Get(key)
+	test.Nil(t, err)
+	test.Equal(t, true, found)
+	test.Equal(t, 42, count)
+	ttl, err = redisClient.TTL(key)
+	test.Nil(t, err)
+	test.Equal(t, int64(-1), ttl)
+}
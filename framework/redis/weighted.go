@@ -0,0 +1,48 @@
+package redisutil
+
+import (
+	"math/rand"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// weightedRandomScript picks a member of the sorted set KEYS[1] with a
+// probability proportional to its score, ARGV[1] is a random number in [0, 1)
+// supplied by the client because math.random is not random in redis scripts
+var weightedRandomScript = redis.NewScript(1, `
+local items = redis.call('ZRANGE', KEYS[1], 0, -1, 'WITHSCORES')
+local total = 0
+for i = 2, #items, 2 do
+	local weight = tonumber(items[i])
+	if weight > 0 then
+		total = total + weight
+	end
+end
+if total <= 0 then
+	return false
+end
+local threshold = tonumber(ARGV[1]) * total
+local last = false
+for i = 1, #items, 2 do
+	local weight = tonumber(items[i + 1])
+	if weight > 0 then
+		last = items[i]
+		threshold = threshold - weight
+		if threshold < 0 then
+			return items[i]
+		end
+	end
+end
+return last
+`)
+
+// ZWeightedRandom returns a random member of the sorted set specified by key,
+// the probability of each member is proportional to its score.
+// members with a score <= 0 are never picked, returns redis.ErrNil if the
+// key does not exists or there is no member with a positive score
+func (rc *RedisClient) ZWeightedRandom(key string) (string, error) {
+	conn := rc.getConn()
+	defer conn.Close()
+	val, err := redis.String(weightedRandomScript.Do(conn, key, rand.Float64()))
+	return val, err
+}
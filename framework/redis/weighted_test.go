@@ -0,0 +1,46 @@
+package redisutil
+
+import (
+	"math"
+	"testing"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestRedisClient_ZWeightedRandom(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:weighted"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	weights := map[string]float64{"a": 1, "b": 3, "c": 6, "never": 0}
+	conn := redisClient.GetConn()
+	for member, weight := range weights {
+		_, err := conn.Do("ZADD", key, weight, member)
+		test.Nil(t, err)
+	}
+	conn.Close()
+
+	const draws = 5000
+	counts := make(map[string]int)
+	for i := 0; i < draws; i++ {
+		member, err := redisClient.ZWeightedRandom(key)
+		test.Nil(t, err)
+		counts[member]++
+	}
+
+	test.Equal(t, 0, counts["never"])
+	for _, member := range []string{"a", "b", "c"} {
+		expected := weights[member] / 10
+		actual := float64(counts[member]) / draws
+		if math.Abs(expected-actual) > 0.05 {
+			t.Errorf("member %s picked with ratio %.3f, expected about %.3f", member, actual, expected)
+		}
+	}
+}
+
+func TestRedisClient_ZWeightedRandom_Missing(t *testing.T) {
+	redisClient := newTestClient(t)
+	_, err := redisClient.ZWeightedRandom("dotweb:test:weighted:missing")
+	test.NotNil(t, err)
+}
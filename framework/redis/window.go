@@ -0,0 +1,51 @@
+package redisutil
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+// slidingWindowScript removes the events older than the window from the sorted
+// set KEYS[1], records the current event and returns the count in the window.
+// ARGV: window start, current time, event member, expire in milliseconds
+var slidingWindowScript = redis.NewScript(1, `
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[3])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+return redis.call('ZCARD', KEYS[1])
+`)
+
+// SlidingWindow counts events in a sliding time window,
+// the event timestamps are stored in a sorted set
+type SlidingWindow struct {
+	rc     *RedisClient
+	key    string
+	window time.Duration
+}
+
+// SlidingWindow returns a SlidingWindow stored at key
+func (rc *RedisClient) SlidingWindow(key string, window time.Duration) *SlidingWindow {
+	return &SlidingWindow{rc: rc, key: key, window: window}
+}
+
+// Record records an event at now and returns the count of events within
+// the window ending at now, events out of the window are removed atomically.
+// the key expires after the window if no more event is recorded.
+// the window must be at least 1ms
+func (w *SlidingWindow) Record(now time.Time) (count int64, err error) {
+	if w.window < time.Millisecond {
+		return 0, errors.New("redisutil: SlidingWindow window must be at least 1ms")
+	}
+	nowMillis := unixMillis(now)
+	windowMillis := int64(w.window / time.Millisecond)
+	member := strconv.FormatInt(now.UnixNano(), 10) + "-" + strconv.FormatInt(rand.Int63(), 36)
+
+	conn := w.rc.getConn()
+	defer conn.Close()
+	count, err = redis.Int64(slidingWindowScript.Do(conn, w.key, nowMillis-windowMillis, nowMillis, member, windowMillis))
+	return count, err
+}
@@ -0,0 +1,54 @@
+package redisutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devfeel/dotweb/test"
+)
+
+func TestSlidingWindow_Record(t *testing.T) {
+	redisClient := newTestClient(t)
+	key := "dotweb:test:window"
+	redisClient.Del(key)
+	defer redisClient.Del(key)
+
+	window := redisClient.SlidingWindow(key, 1500*time.Millisecond)
+	start := time.Now()
+	steps := []struct {
+		offset time.Duration
+		count  int64
+	}{
+		{0, 1},
+		{0, 2},
+		{time.Second, 3},
+		// the two events at start are out of the window
+		{2 * time.Second, 2},
+		{5 * time.Second, 1},
+	}
+	for _, step := range steps {
+		count, err := window.Record(start.Add(step.offset))
+		test.Nil(t, err)
+		test.Equal(t, step.count, count)
+	}
+
+	conn := redisClient.GetConn()
+	defer conn.Close()
+	ttl, err := conn.Do("PTTL", key)
+	test.Nil(t, err)
+	if ttl.(int64) <= 0 || ttl.(int64) > 1500 {
+		t.Errorf("unexpected ttl %v", ttl)
+	}
+}
+
+func TestSlidingWindow_RecordShortWindow(t *testing.T) {
+	server := newFakeServer(t, func(args []string) interface{} {
+		return int64(1)
+	})
+	defer server.Close()
+
+	_, err := GetRedisClient(server.URL()).SlidingWindow("key", time.Microsecond).Record(time.Now())
+	test.NotNil(t, err)
+	test.Contains(t, "at least 1ms", err.Error())
+	test.Equal(t, 0, len(server.Commands()))
+}